@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+	"znc-operator/pkg/controller/znc"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// importedZNC is the ZNC resource printed by the import subcommand. Unlike zncv1.ZNC, it omits the status and all
+// metadata that is set by the API server.
+type importedZNC struct {
+	metav1.TypeMeta `json:",inline"`
+	Metadata        importedZNCMetadata `json:"metadata"`
+	Spec            *zncv1.ZNCSpec      `json:"spec"`
+}
+
+type importedZNCMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// runImport implements the "import" subcommand, which converts an existing znc.conf into a ZNC resource and prints it
+// as YAML. Settings that cannot be represented in the resource and validation errors are reported on stderr.
+func runImport(args []string) error {
+	flags := pflag.NewFlagSet("import", pflag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s import [flags] <znc.conf|->\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	name := flags.String("name", "znc", "Name of the ZNC resource.")
+	namespace := flags.String("namespace", "", "Namespace of the ZNC resource.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var in io.Reader = os.Stdin
+	if path := flags.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	spec, unmapped, err := znc.ParseConfiguration(in)
+	if err != nil {
+		return err
+	}
+	for _, setting := range unmapped {
+		fmt.Fprintf(os.Stderr, "warning: %s cannot be represented in the ZNC resource and has been dropped\n", setting)
+	}
+
+	for _, err := range zncv1.ValidateZNCSpec(spec, field.NewPath("spec")) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	data, err := yaml.Marshal(&importedZNC{
+		TypeMeta: metav1.TypeMeta{APIVersion: zncv1.SchemeGroupVersion.String(), Kind: "ZNC"},
+		Metadata: importedZNCMetadata{Name: *name, Namespace: *namespace},
+		Spec:     spec,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 
@@ -14,6 +15,7 @@ import (
 	"k8s.io/client-go/rest"
 
 	"znc-operator/pkg/apis"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
 	"znc-operator/pkg/controller"
 	"znc-operator/version"
 
@@ -38,6 +40,8 @@ var (
 	metricsHost               = "0.0.0.0"
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
+	webhookPort               = 9443
+	webhookCertDir            = filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
 )
 var log = logf.Log.WithName("cmd")
 
@@ -49,6 +53,14 @@ func printVersion() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Add the zap logger flag set to the CLI. The flag set must
 	// be added before calling pflag.Parse().
 	pflag.CommandLine.AddFlagSet(zap.FlagSet())
@@ -57,6 +69,9 @@ func main() {
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
+	enableWebhooks := pflag.Bool("enable-webhooks", false, "Serve the admission webhooks for ZNC resources, if a "+
+		"serving certificate has been installed into the webhook certificate directory "+webhookCertDir+".")
+
 	pflag.Parse()
 
 	// Use a zap logr.Logger implementation. If none of the zap
@@ -96,6 +111,8 @@ func main() {
 	options := manager.Options{
 		Namespace:          namespace,
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		Port:               webhookPort,
+		CertDir:            webhookCertDir,
 	}
 
 	// Add support for MultiNamespace set in WATCH_NAMESPACE (e.g ns1,ns2)
@@ -128,6 +145,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup the admission webhooks, unless their serving certificate, e.g. issued by cert-manager, is missing
+	if *enableWebhooks {
+		if _, err := os.Stat(filepath.Join(webhookCertDir, "tls.crt")); err != nil {
+			log.Info("Not serving the admission webhooks, as their serving certificate is unavailable", "Reason", err.Error())
+		} else if err := (&zncv1.ZNC{}).SetupWebhookWithManager(mgr); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	// Add the Metrics Service
 	addMetrics(ctx, cfg)
 
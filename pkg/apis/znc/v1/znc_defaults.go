@@ -10,6 +10,125 @@ var (
 	// IdentDefault specifies the default 'ident' value.
 	IdentDefault = "znc"
 
+	// ChanModesDefault specifies the default modes ZNC sets when joining an empty channel.
+	ChanModesDefault = "+stn"
+
+	// ClientEncodingDefault specifies the default client encoding.
+	ClientEncodingDefault = "UTF-8"
+
 	// PassHashMethodDefault specifies the default password hashing mechanism.
 	PassHashMethodDefault = "sha256"
+
+	// ServerPortDefault specifies the port of IRC servers, if nothing has been specified.
+	ServerPortDefault int32 = 6667
+
+	// ServerTLSPortDefault specifies the port of IRC servers with TLS enabled, if nothing has been specified.
+	ServerTLSPortDefault int32 = 6697
+
+	// SASLMechanismDefault specifies the default SASL mechanism.
+	SASLMechanismDefault = SASLMechanismPlain
+
+	// NickServNameDefault specifies the default nick of the NickServ service.
+	NickServNameDefault = "NickServ"
+
+	// NickServIdentifyCmdDefault specifies the default command used to identify with NickServ.
+	NickServIdentifyCmdDefault = "NICKSERV IDENTIFY {password}"
+
+	// IRCListenerPortDefault specifies the port of the default IRC listener.
+	IRCListenerPortDefault int32 = 6667
+
+	// WebListenerPortDefault specifies the port of the default web listener.
+	WebListenerPortDefault int32 = 8080
+
+	// StorageSizeDefault specifies the default size of the PersistentVolumeClaim holding the ZNC data directory.
+	StorageSizeDefault = "1Gi"
+
+	// IssuerKindDefault specifies the default kind of cert-manager issuers.
+	IssuerKindDefault = "Issuer"
+
+	// IssuerGroupDefault specifies the default API group of cert-manager issuers.
+	IssuerGroupDefault = "cert-manager.io"
+
+	// RestartNoticeDefault specifies the notice broadcast to all clients before ZNC is shut down, if nothing has been
+	// specified.
+	RestartNoticeDefault = "ZNC is restarting for maintenance and will be back shortly."
+
+	// RestartGracePeriodSecondsDefault specifies the time between the broadcast and the shutdown of ZNC, if nothing has
+	// been specified.
+	RestartGracePeriodSecondsDefault int32 = 30
+
+	// TrustedProxiesDefault specifies the reverse proxies trusted when exposing the web interface, if nothing has been
+	// specified. Ingress controllers and gateways usually run inside the cluster and connect from private addresses.
+	TrustedProxiesDefault = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
 )
+
+// SetDefaults sets all fields of the spec that have not been set explicitly to their default values, so that the
+// stored object reflects the configuration ZNC receives. The defaults are the same the getters fall back to.
+func (in *ZNCSpec) SetDefaults() {
+	in.Version = in.GetVersion()
+	in.Workload = in.GetWorkload()
+	in.ConfigUpdateStrategy = in.GetConfigUpdateStrategy()
+	in.Config.SetDefaults()
+	in.Service.Type = in.Service.GetType()
+	in.Restart.Notice = in.Restart.GetNotice()
+	gracePeriodSeconds := in.Restart.GetGracePeriodSeconds()
+	in.Restart.GracePeriodSeconds = &gracePeriodSeconds
+	if in.Storage != nil && len(in.Storage.ExistingClaim) == 0 {
+		size := in.Storage.GetSize()
+		in.Storage.Size = &size
+		in.Storage.AccessModes = in.Storage.GetAccessModes()
+		in.Storage.ReclaimPolicy = in.Storage.GetReclaimPolicy()
+	}
+	if in.TLS != nil && in.TLS.IssuerRef != nil {
+		in.TLS.IssuerRef.Kind = in.TLS.IssuerRef.GetKind()
+		in.TLS.IssuerRef.Group = in.TLS.IssuerRef.GetGroup()
+	}
+	if in.Web != nil {
+		if in.Web.Ingress != nil && len(in.Web.Ingress.Path) == 0 {
+			in.Web.Ingress.Path = "/"
+		}
+		if in.Web.HTTPRoute != nil && len(in.Web.HTTPRoute.Path) == 0 {
+			in.Web.HTTPRoute.Path = "/"
+		}
+	}
+}
+
+// SetDefaults sets all fields of the configuration that have not been set explicitly to their default values.
+func (in *ZNCSpecConfig) SetDefaults() {
+	in.StatusPrefix = in.GetStatusPrefix()
+	in.Listeners = in.GetListeners()
+	for i := range in.Listeners {
+		listener := &in.Listeners[i]
+		allowIRC, allowWeb, ipv4 := listener.GetAllowIRC(), listener.GetAllowWeb(), listener.GetIPv4()
+		listener.AllowIRC, listener.AllowWeb, listener.IPv4 = &allowIRC, &allowWeb, &ipv4
+	}
+	for i := range in.Users {
+		in.Users[i].SetDefaults()
+	}
+}
+
+// SetDefaults sets all fields of the user that have not been set explicitly to their default values.
+func (in *ZNCSpecConfigUser) SetDefaults() {
+	in.ChanModes = in.GetChanModes()
+	in.ClientEncoding = in.GetClientEncoding()
+	in.Ident = in.GetIdent()
+	in.StatusPrefix = in.GetStatusPrefix()
+	if in.Password != nil {
+		in.Password.Method = in.Password.GetMethod()
+	}
+	for i := range in.Networks {
+		network := &in.Networks[i]
+		ircConnectEnabled := network.GetIRCConnectEnabled()
+		network.IRCConnectEnabled = &ircConnectEnabled
+		for j := range network.Servers {
+			network.Servers[j].Port = network.Servers[j].GetPort()
+		}
+		if network.SASL != nil {
+			network.SASL.Mechanism = network.SASL.GetMechanism()
+		}
+		if network.NickServ != nil {
+			network.NickServ.Name = network.NickServ.GetName()
+			network.NickServ.IdentifyCmd = network.NickServ.GetIdentifyCmd()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package v1
+
+import "testing"
+
+func TestSetDefaults(t *testing.T) {
+	znc := &ZNC{
+		Spec: ZNCSpec{
+			Config: ZNCSpecConfig{
+				Users: []ZNCSpecConfigUser{
+					{
+						Name:     "johndoe",
+						Password: &ZNCSpecConfigUserPass{},
+						Networks: []ZNCSpecConfigUserNetwork{{Name: "libera"}},
+					},
+				},
+			},
+			Storage: &ZNCSpecStorage{},
+			TLS:     &ZNCSpecTLS{IssuerRef: &ZNCSpecTLSIssuerRef{Name: "letsencrypt"}},
+		},
+	}
+	znc.Default()
+
+	spec := znc.Spec
+	if spec.Version != VersionDefault || spec.Workload != ZNCWorkloadKindStatefulSet || spec.Service.Type != "ClusterIP" {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+	if spec.Config.StatusPrefix != StatusPrefixDefault || len(spec.Config.Listeners) != 2 || spec.Config.Listeners[0].IPv4 == nil {
+		t.Errorf("unexpected configuration %+v", spec.Config)
+	}
+	user := spec.Config.Users[0]
+	if user.ChanModes != ChanModesDefault || user.ClientEncoding != ClientEncodingDefault || user.Ident != IdentDefault || user.Password.Method != PassHashMethodDefault {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if network := user.Networks[0]; network.IRCConnectEnabled == nil || !*network.IRCConnectEnabled {
+		t.Error("expected networks to connect to IRC by default")
+	}
+	if spec.Storage.Size == nil || spec.Storage.Size.String() != StorageSizeDefault || spec.Storage.ReclaimPolicy != ZNCStorageReclaimPolicyDelete {
+		t.Errorf("unexpected storage %+v", spec.Storage)
+	}
+	if spec.Restart.Notice != RestartNoticeDefault || spec.Restart.GracePeriodSeconds == nil || *spec.Restart.GracePeriodSeconds != RestartGracePeriodSecondsDefault {
+		t.Errorf("unexpected restart %+v", spec.Restart)
+	}
+	if spec.TLS.IssuerRef.Kind != IssuerKindDefault || spec.TLS.IssuerRef.Group != IssuerGroupDefault {
+		t.Errorf("unexpected issuer %+v", spec.TLS.IssuerRef)
+	}
+
+	// Explicit values are retained.
+	disabled := false
+	spec.Config.Users[0].Networks[0].IRCConnectEnabled = &disabled
+	spec.Version = "1.8.2"
+	spec.SetDefaults()
+	if spec.Version != "1.8.2" || *spec.Config.Users[0].Networks[0].IRCConnectEnabled {
+		t.Errorf("expected explicit values to be retained, got %+v", spec)
+	}
+}
@@ -0,0 +1,249 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ModuleScope is the level of the configuration a module is loaded at.
+type ModuleScope string
+
+const (
+	// ModuleScopeGlobal modules are loaded once for the whole ZNC instance.
+	ModuleScopeGlobal ModuleScope = "global"
+	// ModuleScopeUser modules are loaded for a single user.
+	ModuleScopeUser ModuleScope = "user"
+	// ModuleScopeNetwork modules are loaded for a single network of a user.
+	ModuleScopeNetwork ModuleScope = "network"
+)
+
+// moduleInfo describes a module shipped with ZNC.
+type moduleInfo struct {
+	// scopes are the scopes the module can be loaded at.
+	scopes []ModuleScope
+	// since is the first ZNC version shipping the module. Empty for modules shipped by all supported versions.
+	since string
+}
+
+var (
+	scopesGlobal        = []ModuleScope{ModuleScopeGlobal}
+	scopesUser          = []ModuleScope{ModuleScopeUser}
+	scopesNetwork       = []ModuleScope{ModuleScopeNetwork}
+	scopesUserOrNetwork = []ModuleScope{ModuleScopeUser, ModuleScopeNetwork}
+	scopesGlobalOrUser  = []ModuleScope{ModuleScopeGlobal, ModuleScopeUser}
+	scopesAny           = []ModuleScope{ModuleScopeGlobal, ModuleScopeUser, ModuleScopeNetwork}
+)
+
+// moduleCatalog lists the modules shipped with ZNC. Modules that are not listed, e.g. third-party modules placed in
+// the data directory, are not validated.
+var moduleCatalog = map[string]moduleInfo{
+	"admindebug":       {scopes: scopesGlobal, since: "1.7.0"},
+	"adminlog":         {scopes: scopesGlobal},
+	"alias":            {scopes: scopesUserOrNetwork},
+	"autoattach":       {scopes: scopesUserOrNetwork},
+	"autocycle":        {scopes: scopesUserOrNetwork},
+	"autoop":           {scopes: scopesUserOrNetwork},
+	"autoreply":        {scopes: scopesUserOrNetwork},
+	"autovoice":        {scopes: scopesUserOrNetwork},
+	"awaystore":        {scopes: scopesUserOrNetwork},
+	"block_motd":       {scopes: scopesAny},
+	"blockuser":        {scopes: scopesGlobal},
+	"bouncedcc":        {scopes: scopesUser},
+	"buffextras":       {scopes: scopesUserOrNetwork},
+	"cert":             {scopes: scopesUserOrNetwork},
+	"certauth":         {scopes: scopesGlobal},
+	"chansaver":        {scopes: scopesUserOrNetwork},
+	"clearbufferonmsg": {scopes: scopesUserOrNetwork},
+	"clientnotify":     {scopes: scopesUser},
+	"controlpanel":     {scopes: scopesUser},
+	"crypt":            {scopes: scopesUserOrNetwork},
+	"ctcpflood":        {scopes: scopesUserOrNetwork},
+	"cyrusauth":        {scopes: scopesGlobal},
+	"dcc":              {scopes: scopesUser},
+	"disconkick":       {scopes: scopesUserOrNetwork},
+	"fail2ban":         {scopes: scopesGlobal},
+	"flooddetach":      {scopes: scopesUserOrNetwork},
+	"identfile":        {scopes: scopesGlobal},
+	"imapauth":         {scopes: scopesGlobal},
+	"keepnick":         {scopes: scopesUserOrNetwork},
+	"kickrejoin":       {scopes: scopesUserOrNetwork},
+	"lastseen":         {scopes: scopesGlobal},
+	"listsockets":      {scopes: scopesGlobalOrUser},
+	"log":              {scopes: scopesAny},
+	"missingmotd":      {scopes: scopesUser},
+	"modperl":          {scopes: scopesGlobal},
+	"modpython":        {scopes: scopesGlobal},
+	"modtcl":           {scopes: scopesUser},
+	"modules_online":   {scopes: scopesUserOrNetwork},
+	"nickserv":         {scopes: scopesNetwork},
+	"notes":            {scopes: scopesUserOrNetwork},
+	"notify_connect":   {scopes: scopesGlobal},
+	"perform":          {scopes: scopesUserOrNetwork},
+	"q":                {scopes: scopesUserOrNetwork},
+	"raw":              {scopes: scopesUserOrNetwork},
+	"route_replies":    {scopes: scopesUserOrNetwork},
+	"samplewebapi":     {scopes: scopesGlobal, since: "1.7.0"},
+	"sasl":             {scopes: scopesNetwork},
+	"savebuff":         {scopes: scopesUserOrNetwork},
+	"schat":            {scopes: scopesUser},
+	"send_raw":         {scopes: scopesUser},
+	"shell":            {scopes: scopesUser},
+	"simple_away":      {scopes: scopesUserOrNetwork},
+	"stickychan":       {scopes: scopesUserOrNetwork},
+	"stripcontrols":    {scopes: scopesUserOrNetwork},
+	"watch":            {scopes: scopesUserOrNetwork},
+	"webadmin":         {scopes: scopesGlobal},
+}
+
+// moduleNameRegexp matches module names, which are used as file names by ZNC.
+var moduleNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ParseModule parses a module given as its name optionally followed by its arguments, e.g. "simple_away -timer 30".
+func ParseModule(value string) ZNCSpecModule {
+	value = strings.TrimSpace(value)
+	i := strings.IndexAny(value, " \t")
+	if i < 0 {
+		return ZNCSpecModule{Name: value}
+	}
+	return ZNCSpecModule{Name: value[:i], Args: strings.TrimSpace(value[i:])}
+}
+
+// String returns the module in the format of the LoadModule setting, i.e. its name followed by its arguments.
+func (in ZNCSpecModule) String() string {
+	if len(in.Args) == 0 {
+		return in.Name
+	}
+	return in.Name + " " + in.Args
+}
+
+// UnmarshalJSON accepts modules specified as objects as well as strings in the format understood by ParseModule.
+func (in *ZNCSpecModule) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*in = ParseModule(value)
+		return nil
+	}
+	// The alias type prevents infinite recursion.
+	type module ZNCSpecModule
+	return json.Unmarshal(data, (*module)(in))
+}
+
+// validateModules validates modules loaded at the given scope by the given ZNC version.
+func validateModules(modules []ZNCSpecModule, scope ModuleScope, version string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, module := range modules {
+		idxPath := fldPath.Index(i)
+		namePath := idxPath.Child("name")
+		if len(module.Name) == 0 {
+			allErrs = append(allErrs, field.Required(namePath, ""))
+			continue
+		}
+		if !moduleNameRegexp.MatchString(module.Name) {
+			allErrs = append(allErrs, field.Invalid(namePath, module.Name, "must consist of alphanumeric characters, '_' or '-'"))
+			continue
+		}
+		if names[module.Name] {
+			allErrs = append(allErrs, field.Duplicate(namePath, module.Name))
+		}
+		names[module.Name] = true
+		allErrs = append(allErrs, validateText(module.Args, idxPath.Child("args"))...)
+
+		info, ok := moduleCatalog[module.Name]
+		if !ok {
+			continue
+		}
+		if !info.supports(scope) {
+			allErrs = append(allErrs, field.Invalid(namePath, module.Name, fmt.Sprintf("cannot be loaded as a %s module, only as %s module", scope, info.describeScopes())))
+		}
+		if len(info.since) > 0 && compareVersions(version, info.since) < 0 {
+			allErrs = append(allErrs, field.Invalid(namePath, module.Name, fmt.Sprintf("requires ZNC %s or later, but version %s is used", info.since, version)))
+		}
+	}
+	return allErrs
+}
+
+// validateModuleData validates the module settings written to the registry files of the given modules.
+func validateModuleData(moduleData map[string]map[string]string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	// Iterating in order keeps the reported errors stable.
+	names := make([]string, 0, len(moduleData))
+	for name := range moduleData {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !moduleNameRegexp.MatchString(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(name), name, "must be a module name consisting of alphanumeric characters, '_' or '-'"))
+			continue
+		}
+		for key := range moduleData[name] {
+			if len(key) == 0 {
+				allErrs = append(allErrs, field.Invalid(fldPath.Key(name), key, "setting names must not be empty"))
+			}
+		}
+	}
+	return allErrs
+}
+
+func (in moduleInfo) supports(scope ModuleScope) bool {
+	for _, s := range in.scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (in moduleInfo) describeScopes() string {
+	scopes := make([]string, len(in.scopes))
+	for i, scope := range in.scopes {
+		scopes[i] = string(scope)
+	}
+	return "a " + strings.Join(scopes, " or ")
+}
+
+// compareVersions compares two dotted ZNC versions, e.g. 1.7.5, and returns -1, 0 or 1. Suffixes such as "-slim" are
+// ignored. Versions that cannot be parsed compare as equal, so that they do not cause spurious validation errors.
+func compareVersions(a string, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	if !okA || !okB {
+		return 0
+	}
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var na, nb int
+		if i < len(va) {
+			na = va[i]
+		}
+		if i < len(vb) {
+			nb = vb[i]
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseVersion(version string) ([]int, bool) {
+	version = strings.SplitN(version, "-", 2)[0]
+	var numbers []int
+	for _, part := range strings.Split(version, ".") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, true
+}
@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestZNCSpecModuleUnmarshalJSON(t *testing.T) {
+	var config ZNCSpecConfig
+	data := `{"loadModules": ["webadmin", "log -sanitize", {"name": "simple_away", "args": "-timer 30"}, {"name": "sasl"}]}`
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	expected := []ZNCSpecModule{
+		{Name: "webadmin"},
+		{Name: "log", Args: "-sanitize"},
+		{Name: "simple_away", Args: "-timer 30"},
+		{Name: "sasl"},
+	}
+	if !reflect.DeepEqual(config.LoadModules, expected) {
+		t.Errorf("expected %v, got %v", expected, config.LoadModules)
+	}
+
+	if err := json.Unmarshal([]byte(`{"loadModules": [42]}`), &config); err == nil {
+		t.Error("expected modules that are neither strings nor objects to be rejected")
+	}
+}
+
+func TestValidateModules(t *testing.T) {
+	modules := []ZNCSpecModule{
+		{Name: "log", Args: "-sanitize"},
+		{Name: "webadmin"},
+		{Name: "sasl"},
+		{Name: "log"},
+		{Name: "admindebug"},
+		{Name: "third_party"},
+		{Name: "../shell"},
+		{Name: "perform", Args: "\nLoadModule = shell"},
+		{},
+	}
+	expected := []string{
+		"loadModules[1].name",
+		"loadModules[2].name",
+		"loadModules[3].name",
+		"loadModules[4].name",
+		"loadModules[4].name",
+		"loadModules[6].name",
+		"loadModules[7].args",
+		"loadModules[8].name",
+	}
+	allErrs := validateModules(modules, ModuleScopeUser, "1.6.6", field.NewPath("loadModules"))
+	if len(allErrs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), allErrs)
+	}
+	for i, err := range allErrs {
+		if err.Field != expected[i] {
+			t.Errorf("expected error for %s, got %v", expected[i], err)
+		}
+	}
+
+	// admindebug is only shipped since ZNC 1.7.0.
+	if allErrs := validateModules([]ZNCSpecModule{{Name: "admindebug"}}, ModuleScopeGlobal, "1.7.5", field.NewPath("loadModules")); len(allErrs) > 0 {
+		t.Errorf("expected valid modules, got %v", allErrs)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, test := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.7.5", "1.7.0", 1},
+		{"1.6.6", "1.7.0", -1},
+		{"1.7", "1.7.0", 0},
+		{"1.10.0", "1.9.1", 1},
+		{"1.8.2-slim", "1.8.2", 0},
+		{"latest", "1.7.0", 0},
+	} {
+		if actual := compareVersions(test.a, test.b); actual != test.expected {
+			t.Errorf("compareVersions(%q, %q): expected %d, got %d", test.a, test.b, test.expected, actual)
+		}
+	}
+}
+
+func TestValidateModuleData(t *testing.T) {
+	moduleData := map[string]map[string]string{
+		"perform":  {"Perform": "JOIN #znc\nMODE johndoe +i"},
+		"../shell": {"cmd": "rm -rf /"},
+		"watch":    {"": "empty"},
+	}
+	expected := []string{
+		"moduleData[../shell]",
+		"moduleData[watch]",
+	}
+	allErrs := validateModuleData(moduleData, field.NewPath("moduleData"))
+	if len(allErrs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), allErrs)
+	}
+	for i, err := range allErrs {
+		if err.Field != expected[i] {
+			t.Errorf("expected error for %s, got %v", expected[i], err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package v1
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// UnmarshalJSON accepts commands specified as objects as well as plain strings.
+func (in *ZNCSpecConfigUserNetworkPerform) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*in = ZNCSpecConfigUserNetworkPerform{Command: value}
+		return nil
+	}
+	// The alias type prevents infinite recursion.
+	type perform ZNCSpecConfigUserNetworkPerform
+	return json.Unmarshal(data, (*perform)(in))
+}
+
+// validatePerform validates the commands sent to a network after connecting.
+func validatePerform(perform []ZNCSpecConfigUserNetworkPerform, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, command := range perform {
+		idxPath := fldPath.Index(i)
+		switch {
+		case len(command.Command) > 0 && command.CommandSecretRef != nil:
+			allErrs = append(allErrs, field.Forbidden(idxPath.Child("command"), "must not be set together with commandSecretRef"))
+		case len(command.Command) == 0 && command.CommandSecretRef == nil:
+			allErrs = append(allErrs, field.Required(idxPath.Child("command"), "either command or commandSecretRef is required"))
+		default:
+			// Commands are stored one per line, so a command must not span multiple lines.
+			allErrs = append(allErrs, validateText(command.Command, idxPath.Child("command"))...)
+		}
+	}
+	return allErrs
+}
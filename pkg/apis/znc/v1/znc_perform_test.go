@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestZNCSpecConfigUserNetworkPerformUnmarshalJSON(t *testing.T) {
+	var network ZNCSpecConfigUserNetwork
+	data := `{"perform": ["/mode johndoe +i", {"commandSecretRef": {"name": "irc", "key": "perform"}}]}`
+	if err := json.Unmarshal([]byte(data), &network); err != nil {
+		t.Fatal(err)
+	}
+	expected := []ZNCSpecConfigUserNetworkPerform{
+		{Command: "/mode johndoe +i"},
+		{CommandSecretRef: &SecretKeyRef{Name: "irc", Key: "perform"}},
+	}
+	if !reflect.DeepEqual(network.Perform, expected) {
+		t.Errorf("expected %+v, got %+v", expected, network.Perform)
+	}
+	if modules := network.GetLoadModules(); len(modules) != 1 || modules[0].Name != "perform" {
+		t.Errorf("expected the perform module to be loaded, got %v", modules)
+	}
+}
+
+func TestValidatePerform(t *testing.T) {
+	perform := []ZNCSpecConfigUserNetworkPerform{
+		{Command: "/join #znc key"},
+		{CommandSecretRef: &SecretKeyRef{Name: "irc", Key: "perform"}},
+		{},
+		{Command: "/join #znc", CommandSecretRef: &SecretKeyRef{Name: "irc", Key: "perform"}},
+		{Command: "/join #znc\n/join #evil"},
+	}
+	expected := []string{
+		"perform[2].command",
+		"perform[3].command",
+		"perform[4].command",
+	}
+	allErrs := validatePerform(perform, field.NewPath("perform"))
+	if len(allErrs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), allErrs)
+	}
+	for i, err := range allErrs {
+		if err.Field != expected[i] {
+			t.Errorf("expected error for %s, got %v", expected[i], err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package v1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validateProbes validates the overrides of the probes of the ZNC container.
+func validateProbes(probes *ZNCSpecProbes, fldPath *field.Path) field.ErrorList {
+	allErrs := validateProbe(probes.Liveness, fldPath.Child("liveness"))
+	allErrs = append(allErrs, validateProbe(probes.Readiness, fldPath.Child("readiness"))...)
+	if probes.Liveness != nil && probes.Liveness.SuccessThreshold != nil && *probes.Liveness.SuccessThreshold != 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("liveness", "successThreshold"), *probes.Liveness.SuccessThreshold, "must be 1"))
+	}
+	return allErrs
+}
+
+// validateProbe validates the thresholds of a single probe, which may be nil.
+func validateProbe(probe *ZNCSpecProbe, fldPath *field.Path) field.ErrorList {
+	if probe == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	minimums := []struct {
+		name    string
+		value   *int32
+		minimum int32
+	}{
+		{name: "initialDelaySeconds", value: probe.InitialDelaySeconds, minimum: 0},
+		{name: "periodSeconds", value: probe.PeriodSeconds, minimum: 1},
+		{name: "timeoutSeconds", value: probe.TimeoutSeconds, minimum: 1},
+		{name: "successThreshold", value: probe.SuccessThreshold, minimum: 1},
+		{name: "failureThreshold", value: probe.FailureThreshold, minimum: 1},
+	}
+	for _, threshold := range minimums {
+		if threshold.value != nil && *threshold.value < threshold.minimum {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(threshold.name), *threshold.value, fmt.Sprintf("must be greater than or equal to %d", threshold.minimum)))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,38 @@
+package v1
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateProbes(t *testing.T) {
+	zero, one, two, negative := int32(0), int32(1), int32(2), int32(-1)
+	valid := &ZNCSpecProbes{
+		Liveness:  &ZNCSpecProbe{InitialDelaySeconds: &zero, SuccessThreshold: &one, FailureThreshold: &two},
+		Readiness: &ZNCSpecProbe{Disabled: true},
+	}
+	if allErrs := validateProbes(valid, field.NewPath("probes")); len(allErrs) > 0 {
+		t.Errorf("expected probes to be valid, got %v", allErrs)
+	}
+
+	invalid := &ZNCSpecProbes{
+		Liveness:  &ZNCSpecProbe{InitialDelaySeconds: &negative, SuccessThreshold: &two},
+		Readiness: &ZNCSpecProbe{PeriodSeconds: &zero, TimeoutSeconds: &zero, SuccessThreshold: &two, FailureThreshold: &zero},
+	}
+	expected := []string{
+		"probes.liveness.initialDelaySeconds",
+		"probes.readiness.periodSeconds",
+		"probes.readiness.timeoutSeconds",
+		"probes.readiness.failureThreshold",
+		"probes.liveness.successThreshold",
+	}
+	var actual []string
+	for _, err := range validateProbes(invalid, field.NewPath("probes")) {
+		actual = append(actual, err.Field)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected errors for %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"encoding/json"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// serverRegexp matches the server syntax of ZNC: <host> [[+]port] [password].
+var serverRegexp = regexp.MustCompile(`^\s*(\S+)(?:\s+(\+?)(\S+)(?:\s+(.*?))?)?\s*$`)
+
+// ParseServer parses a server given in the syntax of ZNC: <host> [[+]port] [password].
+// Values that do not match the syntax are kept as the host, so that they are reported by the validation instead of
+// failing to decode.
+func ParseServer(value string) ZNCSpecConfigUserNetworkServer {
+	matches := serverRegexp.FindStringSubmatch(value)
+	if matches == nil {
+		return ZNCSpecConfigUserNetworkServer{Host: value}
+	}
+	server := ZNCSpecConfigUserNetworkServer{Host: matches[1], TLS: len(matches[2]) > 0, Password: matches[4]}
+	if len(matches[3]) > 0 {
+		port, err := strconv.ParseUint(matches[3], 10, 16)
+		if err != nil || port < 1 {
+			return ZNCSpecConfigUserNetworkServer{Host: value}
+		}
+		server.Port = int32(port)
+	}
+	return server
+}
+
+// String returns the server in the syntax of ZNC. Passwords referenced by PasswordSecretRef must have been resolved
+// into Password before.
+func (in ZNCSpecConfigUserNetworkServer) String() string {
+	port := strconv.Itoa(int(in.GetPort()))
+	if in.TLS {
+		port = "+" + port
+	}
+	fields := []string{in.Host, port}
+	if len(in.Password) > 0 {
+		fields = append(fields, in.Password)
+	}
+	return strings.Join(fields, " ")
+}
+
+func (in ZNCSpecConfigUserNetworkServer) GetPort() int32 {
+	if in.Port != 0 {
+		return in.Port
+	}
+	if in.TLS {
+		return ServerTLSPortDefault
+	}
+	return ServerPortDefault
+}
+
+// UnmarshalJSON accepts servers specified as objects as well as strings in the syntax understood by ParseServer.
+func (in *ZNCSpecConfigUserNetworkServer) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*in = ParseServer(value)
+		return nil
+	}
+	// The alias type prevents infinite recursion.
+	type server ZNCSpecConfigUserNetworkServer
+	return json.Unmarshal(data, (*server)(in))
+}
+
+// validateServer validates the given server definition.
+func validateServer(server *ZNCSpecConfigUserNetworkServer, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(server.Host) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("host"), ""))
+	} else if net.ParseIP(server.Host) == nil && len(validation.IsDNS1123Subdomain(strings.ToLower(server.Host))) > 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("host"), server.Host, "must be a valid host name or IP address"))
+	}
+	if server.Port < 0 || server.Port > 65535 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), server.Port, "must be a number between 1 and 65535"))
+	}
+	allErrs = append(allErrs, validateText(server.Password, fldPath.Child("password"))...)
+	if len(server.Password) > 0 && server.PasswordSecretRef != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("password"), "must not be set together with passwordSecretRef"))
+	}
+	return allErrs
+}
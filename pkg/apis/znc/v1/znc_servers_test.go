@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestParseServer(t *testing.T) {
+	for value, expected := range map[string]ZNCSpecConfigUserNetworkServer{
+		"irc.libera.chat":                {Host: "irc.libera.chat"},
+		"irc.libera.chat 6667":           {Host: "irc.libera.chat", Port: 6667},
+		"irc.libera.chat +6697":          {Host: "irc.libera.chat", Port: 6697, TLS: true},
+		"irc.libera.chat +6697 a b":      {Host: "irc.libera.chat", Port: 6697, TLS: true, Password: "a b"},
+		"irc.libera.chat 0":              {Host: "irc.libera.chat 0"},
+		"irc.libera.chat 65536":          {Host: "irc.libera.chat 65536"},
+		"irc.libera.chat ++6697":         {Host: "irc.libera.chat ++6697"},
+		"irc.libera.chat sixsixninenine": {Host: "irc.libera.chat sixsixninenine"},
+	} {
+		if actual := ParseServer(value); actual != expected {
+			t.Errorf("unexpected result for %q: %+v", value, actual)
+		}
+	}
+}
+
+func TestZNCSpecConfigUserNetworkServerString(t *testing.T) {
+	for expected, server := range map[string]ZNCSpecConfigUserNetworkServer{
+		"irc.libera.chat 6667":         {Host: "irc.libera.chat"},
+		"irc.libera.chat +6697":        {Host: "irc.libera.chat", TLS: true},
+		"irc.libera.chat +7000 secret": {Host: "irc.libera.chat", Port: 7000, TLS: true, Password: "secret"},
+	} {
+		if actual := server.String(); actual != expected {
+			t.Errorf("expected %q, got %q", expected, actual)
+		}
+	}
+}
+
+func TestZNCSpecConfigUserNetworkServerUnmarshalJSON(t *testing.T) {
+	var network ZNCSpecConfigUserNetwork
+	data := `{"servers": ["irc.libera.chat +6697", {"host": "irc.eu.libera.chat", "tls": true, "passwordSecretRef": {"name": "irc", "key": "libera"}}]}`
+	if err := json.Unmarshal([]byte(data), &network); err != nil {
+		t.Fatal(err)
+	}
+	expected := []ZNCSpecConfigUserNetworkServer{
+		{Host: "irc.libera.chat", Port: 6697, TLS: true},
+		{Host: "irc.eu.libera.chat", TLS: true, PasswordSecretRef: &SecretKeyRef{Name: "irc", Key: "libera"}},
+	}
+	if !reflect.DeepEqual(network.Servers, expected) {
+		t.Errorf("expected %+v, got %+v", expected, network.Servers)
+	}
+}
+
+func TestValidateServer(t *testing.T) {
+	for value, valid := range map[string]bool{
+		"irc.libera.chat":                true,
+		"irc.libera.chat 6667":           true,
+		"irc.libera.chat +6697":          true,
+		"irc.libera.chat +6697 secret":   true,
+		"192.0.2.1 6667":                 true,
+		"2001:db8::1 +6697":              true,
+		"":                               false,
+		"irc.libera.chat 0":              false,
+		"irc.libera.chat 65536":          false,
+		"irc.libera.chat ++6697":         false,
+		"irc.libera.chat sixsixninenine": false,
+		"irc_libera.chat 6667":           false,
+	} {
+		server := ParseServer(value)
+		if allErrs := validateServer(&server, field.NewPath("server")); (len(allErrs) == 0) != valid {
+			t.Errorf("unexpected result for %q: %v", value, allErrs)
+		}
+	}
+
+	server := ZNCSpecConfigUserNetworkServer{Host: "irc.libera.chat", Password: "secret", PasswordSecretRef: &SecretKeyRef{Name: "irc", Key: "libera"}}
+	if allErrs := validateServer(&server, field.NewPath("server")); len(allErrs) != 1 || allErrs[0].Field != "server.password" {
+		t.Errorf("expected password and passwordSecretRef to be mutually exclusive, got %v", allErrs)
+	}
+}
@@ -1,6 +1,8 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -18,6 +20,46 @@ type ZNCSpec struct {
 
 	// ZNSSpecConfig is the configuration used by the ZNC instance.
 	Config ZNCSpecConfig `json:"config,omitempty"`
+
+	// ConfigUpdateStrategy controls how configuration changes are applied to a running ZNC instance. Live applies
+	// them through the controlpanel module without disconnecting anyone and only restarts ZNC for changes that cannot
+	// be applied at runtime, e.g. to listeners. Restart always restarts ZNC.
+	// +optional
+	// +kubebuilder:validation:Enum=Live;Restart
+	// +kubebuilder:validation:Default=Live
+	ConfigUpdateStrategy ZNCConfigUpdateStrategy `json:"configUpdateStrategy,omitempty"`
+
+	// Probes overrides the liveness and readiness probes of the ZNC container.
+	// +optional
+	Probes *ZNCSpecProbes `json:"probes,omitempty"`
+
+	// Restart controls how ZNC is shut down before its pod is replaced, e.g. due to a configuration change or a node
+	// drain.
+	// +optional
+	Restart ZNCSpecRestart `json:"restart,omitempty"`
+
+	// Service controls the Service that exposes the listeners of the ZNC instance.
+	// +optional
+	Service ZNCSpecService `json:"service,omitempty"`
+
+	// Storage controls the persistent storage of the ZNC data directory.
+	// If omitted, the data directory is ephemeral and lost whenever the ZNC pod is recreated.
+	// +optional
+	Storage *ZNCSpecStorage `json:"storage,omitempty"`
+
+	// TLS controls the certificate used by SSL listeners.
+	// +optional
+	TLS *ZNCSpecTLS `json:"tls,omitempty"`
+
+	// Web controls how the web interface is exposed outside of the cluster.
+	// +optional
+	Web *ZNCSpecWeb `json:"web,omitempty"`
+
+	// Workload specifies the kind of workload resource used to run the ZNC pod.
+	// +optional
+	// +kubebuilder:validation:Enum=StatefulSet;Deployment
+	// +kubebuilder:validation:Default=StatefulSet
+	Workload ZNCWorkloadKind `json:"workload,omitempty"`
 }
 
 func (in *ZNCSpec) GetVersion() string {
@@ -32,6 +74,48 @@ func (in *ZNCSpec) GetConfig() ZNCSpecConfig {
 	return in.Config
 }
 
+func (in *ZNCSpec) GetConfigUpdateStrategy() ZNCConfigUpdateStrategy {
+	strategy := in.ConfigUpdateStrategy
+	if len(strategy) == 0 {
+		strategy = ZNCConfigUpdateStrategyLive
+	}
+	return strategy
+}
+
+func (in *ZNCSpec) GetWorkload() ZNCWorkloadKind {
+	workload := in.Workload
+	if len(workload) == 0 {
+		workload = ZNCWorkloadKindStatefulSet
+	}
+	return workload
+}
+
+// ZNCWorkloadKind is the kind of workload resource used to run the ZNC pod.
+type ZNCWorkloadKind string
+
+const (
+	// ZNCWorkloadKindStatefulSet runs the ZNC pod as part of a single-replica StatefulSet, which is governed by the
+	// headless Service "<name>-headless".
+	ZNCWorkloadKindStatefulSet ZNCWorkloadKind = "StatefulSet"
+	// ZNCWorkloadKindDeployment runs the ZNC pod as part of a single-replica Deployment.
+	ZNCWorkloadKindDeployment ZNCWorkloadKind = "Deployment"
+)
+
+// ZNCConfigUpdateStrategy is the way configuration changes are applied to a running ZNC instance.
+type ZNCConfigUpdateStrategy string
+
+const (
+	// ZNCConfigUpdateStrategyLive applies configuration changes at runtime, where possible.
+	ZNCConfigUpdateStrategyLive ZNCConfigUpdateStrategy = "Live"
+	// ZNCConfigUpdateStrategyRestart restarts ZNC on every configuration change.
+	ZNCConfigUpdateStrategyRestart ZNCConfigUpdateStrategy = "Restart"
+)
+
+// OperatorUserName is the name of the admin user the operator adds to ZNC instances, in order to apply configuration
+// changes through the controlpanel module and to announce restarts. It may only log in from loopback and the addresses
+// of the operator.
+const OperatorUserName = "znc-operator"
+
 type ZNCSpecConfig struct {
 
 	// AnonIPLimit is the limit of anonymous unidentified connections per IP.
@@ -48,10 +132,16 @@ type ZNCSpecConfig struct {
 	// +optional
 	HideVersion bool `json:"hideVersion,omitempty"`
 
-	// LoadModules controls which modules shall be loaded.
+	// Listeners specifies the ports ZNC listens on for IRC clients and the web interface.
+	// If omitted, ZNC listens for IRC clients on port 6667 ("irc") and for web clients on port 8080 ("web").
 	// +optional
 	// +kubebuilder:validation:MinItems=0
-	LoadModules []string `json:"loadModules,omitempty"`
+	Listeners []ZNCSpecConfigListener `json:"listeners,omitempty"`
+
+	// LoadModules controls which global modules shall be loaded.
+	// +optional
+	// +kubebuilder:validation:MinItems=0
+	LoadModules []ZNCSpecModule `json:"loadModules,omitempty"`
 
 	// MaxBufferSize controls the maximum playback buffer size. Only admin users can exceed the limit.
 	// +optional
@@ -74,7 +164,15 @@ type ZNCSpecConfig struct {
 	// +kubebuilder:validation:Default=*
 	StatusPrefix string `json:"statusPrefix,omitempty"`
 
-	// Users specifies the users that are allowed to interact with this ZNC instance.
+	// TrustedProxies specifies the addresses or CIDR ranges of reverse proxies, whose X-Forwarded-For headers are
+	// trusted to contain the address of web clients. If omitted and the web interface is exposed through an Ingress or
+	// HTTPRoute, the private address ranges are trusted.
+	// +optional
+	// +kubebuilder:validation:MinItems=0
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+
+	// Users specifies the users that are allowed to interact with this ZNC instance. The operator adds the admin user
+	// znc-operator, which may only log in from loopback and the addresses of the operator.
 	// +optional
 	// +kubebuilder:validation:MinItems=0
 	Users []ZNCSpecConfigUser `json:"users,omitempty"`
@@ -88,6 +186,78 @@ func (in ZNCSpecConfig) GetStatusPrefix() string {
 	return statusPrefix
 }
 
+// GetListeners returns the configured listeners or the default listeners, if none have been configured.
+func (in ZNCSpecConfig) GetListeners() []ZNCSpecConfigListener {
+	if len(in.Listeners) > 0 {
+		return in.Listeners
+	}
+	allow := true
+	deny := false
+	return []ZNCSpecConfigListener{
+		{Name: "irc", AllowIRC: &allow, AllowWeb: &deny, Port: IRCListenerPortDefault},
+		{Name: "web", AllowIRC: &deny, AllowWeb: &allow, Port: WebListenerPortDefault},
+	}
+}
+
+// ZNCSpecConfigListener defines a port ZNC listens on.
+type ZNCSpecConfigListener struct {
+
+	// Name specifies the name of the listener. It is also used to name the container and Service ports.
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:Pattern=^[a-z0-9]([a-z0-9-]*[a-z0-9])?$
+	Name string `json:"name"`
+
+	// AllowIRC controls whether IRC clients are allowed to connect to the listener.
+	// +optional
+	// +kubebuilder:validation:Default=true
+	AllowIRC *bool `json:"allowIRC,omitempty"`
+
+	// AllowWeb controls whether web clients are allowed to connect to the listener.
+	// +optional
+	// +kubebuilder:validation:Default=true
+	AllowWeb *bool `json:"allowWeb,omitempty"`
+
+	// Port specifies the port number the listener listens on.
+	// Ports below 1024 cannot be used, as ZNC does not run as root.
+	// +kubebuilder:validation:Minimum=1024
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// Host specifies the address the listener binds to. If omitted, the listener binds to all addresses.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// IPv4 controls whether the listener accepts IPv4 connections.
+	// +optional
+	// +kubebuilder:validation:Default=true
+	IPv4 *bool `json:"ipv4,omitempty"`
+
+	// IPv6 controls whether the listener accepts IPv6 connections.
+	// +optional
+	// +kubebuilder:validation:Default=false
+	IPv6 bool `json:"ipv6,omitempty"`
+
+	// SSL controls whether the listener only accepts SSL/TLS encrypted connections. It requires TLS to be configured.
+	// +optional
+	SSL bool `json:"ssl,omitempty"`
+
+	// URIPrefix specifies the path prefix the web interface is served under, e.g. when running behind a reverse proxy.
+	// +optional
+	URIPrefix string `json:"uriPrefix,omitempty"`
+}
+
+func (in ZNCSpecConfigListener) GetAllowIRC() bool {
+	return in.AllowIRC == nil || *in.AllowIRC
+}
+
+func (in ZNCSpecConfigListener) GetAllowWeb() bool {
+	return in.AllowWeb == nil || *in.AllowWeb
+}
+
+func (in ZNCSpecConfigListener) GetIPv4() bool {
+	return in.IPv4 == nil || *in.IPv4
+}
+
 type ZNCSpecConfigUser struct {
 
 	// Name specifies the user's name.
@@ -157,7 +327,13 @@ type ZNCSpecConfigUser struct {
 	// LoadModules controls the list of user modules loaded on ZNC startup.
 	// +optional
 	// +kubebuilder:validation:MinItems=0
-	LoadModules []string `json:"loadModules,omitempty"`
+	LoadModules []ZNCSpecModule `json:"loadModules,omitempty"`
+
+	// ModuleData specifies the settings of user modules, keyed by module name and setting. The settings are written
+	// to the registry files in the data directory of the modules whenever ZNC starts, so that they replace settings
+	// changed at runtime, e.g. in the web interface.
+	// +optional
+	ModuleData map[string]map[string]string `json:"moduleData,omitempty"`
 
 	// MaxJoins controls the maximum number of channels ZNC joins at once. Lower the value in case getting disconnected
 	// for 'Excess flood'.
@@ -220,8 +396,22 @@ type ZNCSpecConfigUser struct {
 	// +optional
 	Timezone string `json:"timezone,omitempty"`
 
-	// Passwords represents the definition of a password, used by clients to connect to ZNC.
-	Pass string `json:"pass"`
+	// Pass represents the definition of a password, used by clients to connect to ZNC.
+	// Syntax: <method>#<hash>#<salt>#. Prefer PasswordSecretRef to keep password hashes out of the resource.
+	// +optional
+	Pass string `json:"pass,omitempty"`
+
+	// Password is the structured definition of the password used by clients to connect to ZNC.
+	// Takes precedence over Pass. In combination with PasswordSecretRef, only Method and Salt are used to hash the
+	// plaintext password read from the Secret.
+	// +optional
+	Password *ZNCSpecConfigUserPass `json:"password,omitempty"`
+
+	// PasswordSecretRef references a Secret key containing the password used by clients to connect to ZNC.
+	// The value is either a pre-hashed password (<method>#<hash>#<salt>#) or a plaintext password that is hashed by
+	// the operator. Takes precedence over Pass and Password.
+	// +optional
+	PasswordSecretRef *SecretKeyRef `json:"passwordSecretRef,omitempty"`
 
 	// Networks specifies a list of IRC networks to connect to.
 	Networks []ZNCSpecConfigUserNetwork `json:"networks"`
@@ -230,7 +420,7 @@ type ZNCSpecConfigUser struct {
 func (in ZNCSpecConfigUser) GetChanModes() string {
 	chanModes := in.ChanModes
 	if len(chanModes) == 0 {
-		return "+stn"
+		return ChanModesDefault
 	}
 	return chanModes
 }
@@ -238,7 +428,7 @@ func (in ZNCSpecConfigUser) GetChanModes() string {
 func (in ZNCSpecConfigUser) GetClientEncoding() string {
 	clientEncoding := in.ClientEncoding
 	if len(clientEncoding) == 0 {
-		return "UTF-8"
+		return ClientEncodingDefault
 	}
 	return clientEncoding
 }
@@ -259,18 +449,25 @@ func (in ZNCSpecConfigUser) GetStatusPrefix() string {
 	return statusPrefix
 }
 
-// TODO Altough the ZNC documentation states, that separate <Password> blocks should work, this doesn't. :-(
+// ZNCSpecConfigUserPass is the structured definition of a user's password.
+// NOTE: Although the ZNC documentation states that separate <Pass password> blocks should work, they don't, so the
+// operator always renders the password as a single "Pass = <method>#<hash>#<salt>#" line.
 type ZNCSpecConfigUserPass struct {
 
-	// Hash is the hash of a salted password.
-	Hash string `json:"hash"`
+	// Hash is the hash of a salted password. If omitted, the password is read in plaintext from the user's
+	// PasswordSecretRef and hashed by the operator.
+	// +optional
+	Hash string `json:"hash,omitempty"`
 
 	// Method is the password hashing method.
 	// +optional
+	// +kubebuilder:validation:Enum=sha256;md5
 	// +kubebuilder:validation:Default=sha256
 	Method string `json:"method,omitempty"`
 
 	// Salt is a random set of 20 characters for salting the password.
+	// If omitted while hashing a plaintext password, the operator generates one.
+	// +optional
 	Salt string `json:"salt,omitempty"`
 }
 
@@ -303,7 +500,7 @@ type ZNCSpecConfigUserNetwork struct {
 	// IRCConnectEnabled specifies whether the network is enabled ie. connects to IRC.
 	// +optional
 	// +kubebuilder:validation:Default=true
-	IRCConnectEnabled bool `json:"ircConnectEnabled,omitempty"`
+	IRCConnectEnabled *bool `json:"ircConnectEnabled,omitempty"`
 
 	// JoinDelay specifies the delay in seconds, until channels are joined after getting connected.
 	// +optional
@@ -312,7 +509,14 @@ type ZNCSpecConfigUserNetwork struct {
 	// LoadModules controls the list of network modules loaded on ZNC startup.
 	// +optional
 	// +kubebuilder:validation:MinItems=0
-	LoadModules []string `json:"loadModules,omitempty"`
+	LoadModules []ZNCSpecModule `json:"loadModules,omitempty"`
+
+	// ModuleData specifies the settings of network modules, keyed by module name and setting. The settings are
+	// written to the registry files in the data directory of the modules whenever ZNC starts, so that they replace
+	// settings changed at runtime, e.g. in the web interface. Settings derived from SASL, NickServ and Perform take
+	// precedence.
+	// +optional
+	ModuleData map[string]map[string]string `json:"moduleData,omitempty"`
 
 	// Nick specifies an optional network specific primary nick.
 	// +optional
@@ -328,12 +532,181 @@ type ZNCSpecConfigUserNetwork struct {
 	RealName string `json:"realName,omitempty"`
 
 	// Servers specifies the list of IRC servers.
-	// Prefix the port number with a '+' to enable SSL. Syntax: <host> [[+]port] [password].
-	Servers []string `json:"servers"`
+	Servers []ZNCSpecConfigUserNetworkServer `json:"servers"`
 
 	// Channels specifies the channels to be joined.
 	// +optional
 	Channels []ZNCSpecConfigUserNetworkChan `json:"channels,omitempty"`
+
+	// SASL configures authentication with the network using SASL. The sasl module is loaded automatically.
+	// +optional
+	SASL *ZNCSpecConfigUserNetworkSASL `json:"sasl,omitempty"`
+
+	// NickServ configures identification with the NickServ service of the network. The nickserv module is loaded
+	// automatically.
+	// +optional
+	NickServ *ZNCSpecConfigUserNetworkNickServ `json:"nickserv,omitempty"`
+
+	// Perform specifies the commands sent to the network after connecting, e.g. "/msg NickServ ..." or "/mode". The
+	// perform module is loaded automatically.
+	// +optional
+	// +kubebuilder:validation:MinItems=0
+	Perform []ZNCSpecConfigUserNetworkPerform `json:"perform,omitempty"`
+}
+
+func (in ZNCSpecConfigUserNetwork) GetIRCConnectEnabled() bool {
+	return in.IRCConnectEnabled == nil || *in.IRCConnectEnabled
+}
+
+// GetLoadModules returns the modules loaded for the network, including the modules required by its SASL, NickServ and
+// Perform settings, unless they are loaded explicitly.
+func (in ZNCSpecConfigUserNetwork) GetLoadModules() []ZNCSpecModule {
+	modules := append([]ZNCSpecModule{}, in.LoadModules...)
+	var required []string
+	if in.SASL != nil {
+		required = append(required, "sasl")
+		if in.SASL.GetMechanism() == SASLMechanismExternal {
+			required = append(required, "cert")
+		}
+	}
+	if in.NickServ != nil {
+		required = append(required, "nickserv")
+	}
+	if len(in.Perform) > 0 {
+		required = append(required, "perform")
+	}
+	for _, name := range required {
+		loaded := false
+		for _, module := range in.LoadModules {
+			loaded = loaded || module.Name == name
+		}
+		if !loaded {
+			modules = append(modules, ZNCSpecModule{Name: name})
+		}
+	}
+	return modules
+}
+
+// ZNCSpecConfigUserNetworkServer defines an IRC server of a network.
+// For compatibility, a server can also be specified as a string using the syntax of ZNC: <host> [[+]port] [password],
+// where a '+' in front of the port enables TLS.
+// +kubebuilder:validation:Type=""
+// +kubebuilder:validation:XPreserveUnknownFields
+type ZNCSpecConfigUserNetworkServer struct {
+
+	// Host specifies the host name or IP address of the server.
+	Host string `json:"host"`
+
+	// Port specifies the port of the server. If omitted, ZNC connects to port 6667, or 6697 if TLS is enabled.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// TLS controls whether the connection to the server is encrypted.
+	// +optional
+	TLS bool `json:"tls,omitempty"`
+
+	// Password specifies the server password. Prefer PasswordSecretRef to keep the password out of the resource.
+	// +optional
+	Password string `json:"password,omitempty"`
+
+	// PasswordSecretRef references a Secret key containing the server password.
+	// +optional
+	PasswordSecretRef *SecretKeyRef `json:"passwordSecretRef,omitempty"`
+}
+
+// SASLMechanism is a SASL mechanism supported by the sasl module.
+type SASLMechanism string
+
+const (
+	// SASLMechanismPlain authenticates with a username and password.
+	SASLMechanismPlain SASLMechanism = "PLAIN"
+	// SASLMechanismExternal authenticates with a client certificate (CertFP).
+	SASLMechanismExternal SASLMechanism = "EXTERNAL"
+)
+
+// ZNCSpecConfigUserNetworkSASL configures SASL authentication with a network.
+type ZNCSpecConfigUserNetworkSASL struct {
+
+	// Mechanism specifies the SASL mechanism.
+	// +optional
+	// +kubebuilder:validation:Enum=PLAIN;EXTERNAL
+	// +kubebuilder:validation:Default=PLAIN
+	Mechanism SASLMechanism `json:"mechanism,omitempty"`
+
+	// Username specifies the account name. Required for the PLAIN mechanism.
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// PasswordSecretRef references a Secret key containing the account password. Required for the PLAIN mechanism.
+	// +optional
+	PasswordSecretRef *SecretKeyRef `json:"passwordSecretRef,omitempty"`
+
+	// CertificateSecretName specifies the name of a Secret of type kubernetes.io/tls containing the client
+	// certificate and its private key. Required for the EXTERNAL mechanism. The cert module is loaded automatically.
+	// +optional
+	CertificateSecretName string `json:"certificateSecretName,omitempty"`
+
+	// RequireAuth controls whether ZNC disconnects from the network if SASL authentication fails.
+	// +optional
+	RequireAuth bool `json:"requireAuth,omitempty"`
+}
+
+func (in ZNCSpecConfigUserNetworkSASL) GetMechanism() SASLMechanism {
+	mechanism := in.Mechanism
+	if len(mechanism) == 0 {
+		mechanism = SASLMechanismDefault
+	}
+	return mechanism
+}
+
+// ZNCSpecConfigUserNetworkNickServ configures identification with the NickServ service of a network.
+type ZNCSpecConfigUserNetworkNickServ struct {
+
+	// PasswordSecretRef references a Secret key containing the password used to identify with NickServ.
+	PasswordSecretRef *SecretKeyRef `json:"passwordSecretRef"`
+
+	// Name specifies the nick of the NickServ service.
+	// +optional
+	// +kubebuilder:validation:Default=NickServ
+	Name string `json:"name,omitempty"`
+
+	// IdentifyCmd specifies the command sent to identify, in which {password} is replaced by the password.
+	// +optional
+	// +kubebuilder:validation:Default=NICKSERV IDENTIFY {password}
+	IdentifyCmd string `json:"identifyCmd,omitempty"`
+}
+
+func (in ZNCSpecConfigUserNetworkNickServ) GetName() string {
+	name := in.Name
+	if len(name) == 0 {
+		name = NickServNameDefault
+	}
+	return name
+}
+
+func (in ZNCSpecConfigUserNetworkNickServ) GetIdentifyCmd() string {
+	identifyCmd := in.IdentifyCmd
+	if len(identifyCmd) == 0 {
+		identifyCmd = NickServIdentifyCmdDefault
+	}
+	return identifyCmd
+}
+
+// ZNCSpecConfigUserNetworkPerform defines a command sent to a network after connecting. For compatibility, a command
+// can also be specified as a string.
+// +kubebuilder:validation:Type=""
+// +kubebuilder:validation:XPreserveUnknownFields
+type ZNCSpecConfigUserNetworkPerform struct {
+
+	// Command specifies the command in the syntax of the perform module, e.g. "/join #channel key".
+	// +optional
+	Command string `json:"command,omitempty"`
+
+	// CommandSecretRef references a Secret key containing sensitive commands, one per line.
+	// +optional
+	CommandSecretRef *SecretKeyRef `json:"commandSecretRef,omitempty"`
 }
 
 type ZNCSpecConfigUserNetworkChan struct {
@@ -368,11 +741,545 @@ type ZNCSpecConfigUserNetworkChan struct {
 	Modes string `json:"modes,omitempty"`
 }
 
+// ZNCSpecModule is a module loaded by ZNC.
+// For compatibility, a module can also be specified as a string containing its name optionally followed by its
+// arguments, e.g. "simple_away -timer 30".
+// +kubebuilder:validation:Type=""
+// +kubebuilder:validation:XPreserveUnknownFields
+type ZNCSpecModule struct {
+
+	// Name specifies the name of the module.
+	// +kubebuilder:validation:Pattern=^[A-Za-z0-9_-]+$
+	Name string `json:"name"`
+
+	// Args specifies the arguments passed to the module, e.g. "-sanitize" for the log module.
+	// +optional
+	Args string `json:"args,omitempty"`
+}
+
+// ZNCSpecService controls the Service that exposes the listeners of a ZNC instance.
+type ZNCSpecService struct {
+
+	// Type specifies the type of the Service.
+	// +optional
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +kubebuilder:validation:Default=ClusterIP
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Annotations specifies additional annotations to be added to the Service.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// ExternalTrafficPolicy controls whether external traffic is routed to node-local or cluster-wide endpoints.
+	// Only used for Services of type NodePort and LoadBalancer.
+	// +optional
+	// +kubebuilder:validation:Enum=Cluster;Local
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+
+	// LoadBalancerSourceRanges restricts traffic through a load balancer to the specified client IP ranges.
+	// Only used for Services of type LoadBalancer.
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// Ports overrides the Service port numbers of individual listeners. By default, every listener is exposed on the
+	// port it listens on.
+	// +optional
+	Ports []ZNCSpecServicePort `json:"ports,omitempty"`
+}
+
+func (in ZNCSpecService) GetType() corev1.ServiceType {
+	serviceType := in.Type
+	if len(serviceType) == 0 {
+		serviceType = corev1.ServiceTypeClusterIP
+	}
+	return serviceType
+}
+
+// ZNCSpecServicePort overrides the Service port numbers of a listener.
+type ZNCSpecServicePort struct {
+
+	// Name specifies the name of the listener.
+	Name string `json:"name"`
+
+	// Port specifies the port number the listener is exposed on by the Service.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// NodePort specifies the port number the listener is exposed on each node, if the Service is of type NodePort or
+	// LoadBalancer. Allocated automatically if omitted.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	NodePort int32 `json:"nodePort,omitempty"`
+}
+
+// ZNCSpecTLS controls the certificate used by the SSL listeners of a ZNC instance.
+type ZNCSpecTLS struct {
+
+	// SecretName specifies the name of a Secret of type kubernetes.io/tls containing the certificate and its private
+	// key. If IssuerRef is set, the Secret is managed by cert-manager and defaults to "<name>-tls".
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// IssuerRef references the cert-manager Issuer or ClusterIssuer that issues the certificate.
+	// If set, the operator creates a cert-manager Certificate for the ZNC instance, which is deleted once unset.
+	// +optional
+	IssuerRef *ZNCSpecTLSIssuerRef `json:"issuerRef,omitempty"`
+
+	// DNSNames specifies additional DNS names the certificate issued by cert-manager is valid for.
+	// The DNS names of the Service are always included.
+	// +optional
+	DNSNames []string `json:"dnsNames,omitempty"`
+}
+
+// ZNCSpecTLSIssuerRef references a cert-manager Issuer or ClusterIssuer.
+type ZNCSpecTLSIssuerRef struct {
+
+	// Name specifies the name of the issuer.
+	Name string `json:"name"`
+
+	// Kind specifies the kind of the issuer.
+	// +optional
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +kubebuilder:validation:Default=Issuer
+	Kind string `json:"kind,omitempty"`
+
+	// Group specifies the API group of the issuer.
+	// +optional
+	// +kubebuilder:validation:Default=cert-manager.io
+	Group string `json:"group,omitempty"`
+}
+
+func (in ZNCSpecTLSIssuerRef) GetKind() string {
+	kind := in.Kind
+	if len(kind) == 0 {
+		kind = IssuerKindDefault
+	}
+	return kind
+}
+
+func (in ZNCSpecTLSIssuerRef) GetGroup() string {
+	group := in.Group
+	if len(group) == 0 {
+		group = IssuerGroupDefault
+	}
+	return group
+}
+
+// ZNCSpecWeb controls how the web interface of a ZNC instance is exposed outside of the cluster.
+type ZNCSpecWeb struct {
+
+	// Listener specifies the name of the listener serving the web interface.
+	// If omitted, the first listener that allows web clients is used.
+	// +optional
+	Listener string `json:"listener,omitempty"`
+
+	// Ingress controls the Ingress exposing the web interface.
+	// +optional
+	Ingress *ZNCSpecWebIngress `json:"ingress,omitempty"`
+
+	// HTTPRoute controls the Gateway API HTTPRoute exposing the web interface.
+	// +optional
+	HTTPRoute *ZNCSpecWebHTTPRoute `json:"httpRoute,omitempty"`
+}
+
+// GetPath returns the path the web interface is exposed under.
+func (in ZNCSpecWeb) GetPath() string {
+	path := ""
+	if in.Ingress != nil {
+		path = in.Ingress.Path
+	} else if in.HTTPRoute != nil {
+		path = in.HTTPRoute.Path
+	}
+	if len(path) == 0 {
+		path = "/"
+	}
+	return path
+}
+
+// ZNCSpecWebIngress controls the Ingress exposing the web interface of a ZNC instance.
+type ZNCSpecWebIngress struct {
+
+	// Host specifies the host name the web interface is served under.
+	Host string `json:"host"`
+
+	// Path specifies the path the web interface is served under. The URIPrefix of the web listener is derived from it.
+	// +optional
+	// +kubebuilder:validation:Pattern=^/
+	// +kubebuilder:validation:Default=/
+	Path string `json:"path,omitempty"`
+
+	// ClassName specifies the class of the Ingress controller that is supposed to serve the Ingress.
+	// +optional
+	ClassName string `json:"className,omitempty"`
+
+	// Annotations specifies additional annotations of the Ingress.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// TLSSecret specifies the name of the Secret holding the certificate used by the Ingress controller.
+	// If omitted, the web interface is served without TLS.
+	// +optional
+	TLSSecret string `json:"tlsSecret,omitempty"`
+}
+
+// ZNCSpecWebHTTPRoute controls the Gateway API HTTPRoute exposing the web interface of a ZNC instance.
+type ZNCSpecWebHTTPRoute struct {
+
+	// ParentRefs references the Gateways the HTTPRoute attaches to.
+	// +kubebuilder:validation:MinItems=1
+	ParentRefs []ZNCSpecWebHTTPRouteParentRef `json:"parentRefs"`
+
+	// Hostnames specifies the host names the web interface is served under.
+	// +optional
+	Hostnames []string `json:"hostnames,omitempty"`
+
+	// Path specifies the path the web interface is served under. The URIPrefix of the web listener is derived from it.
+	// +optional
+	// +kubebuilder:validation:Pattern=^/
+	// +kubebuilder:validation:Default=/
+	Path string `json:"path,omitempty"`
+}
+
+// ZNCSpecWebHTTPRouteParentRef references a Gateway.
+type ZNCSpecWebHTTPRouteParentRef struct {
+
+	// Name specifies the name of the Gateway.
+	Name string `json:"name"`
+
+	// Namespace specifies the namespace of the Gateway. If omitted, the namespace of the ZNC instance is used.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName specifies the name of the Gateway listener to attach to.
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// ZNCStorageReclaimPolicy controls what happens to a PersistentVolumeClaim once its ZNC resource is deleted.
+type ZNCStorageReclaimPolicy string
+
+const (
+	// ZNCStorageReclaimPolicyDelete deletes the PersistentVolumeClaim together with the ZNC resource.
+	ZNCStorageReclaimPolicyDelete ZNCStorageReclaimPolicy = "Delete"
+	// ZNCStorageReclaimPolicyRetain keeps the PersistentVolumeClaim after the ZNC resource has been deleted.
+	ZNCStorageReclaimPolicyRetain ZNCStorageReclaimPolicy = "Retain"
+)
+
+// ZNCSpecStorage controls the persistent storage of the ZNC data directory.
+type ZNCSpecStorage struct {
+
+	// ExistingClaim specifies the name of an existing PersistentVolumeClaim to be used. If set, the operator does not
+	// create a PersistentVolumeClaim on its own and all other settings are ignored.
+	// +optional
+	ExistingClaim string `json:"existingClaim,omitempty"`
+
+	// StorageClassName specifies the name of the StorageClass of the PersistentVolumeClaim.
+	// If omitted, the default StorageClass of the cluster is used.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// Size specifies the requested size of the PersistentVolumeClaim.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// AccessModes specifies the access modes of the PersistentVolumeClaim.
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+
+	// ReclaimPolicy controls whether the PersistentVolumeClaim is deleted together with the ZNC resource.
+	// +optional
+	// +kubebuilder:validation:Enum=Delete;Retain
+	// +kubebuilder:validation:Default=Delete
+	ReclaimPolicy ZNCStorageReclaimPolicy `json:"reclaimPolicy,omitempty"`
+}
+
+func (in ZNCSpecStorage) GetSize() resource.Quantity {
+	if in.Size == nil {
+		return resource.MustParse(StorageSizeDefault)
+	}
+	return *in.Size
+}
+
+func (in ZNCSpecStorage) GetAccessModes() []corev1.PersistentVolumeAccessMode {
+	accessModes := in.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	return accessModes
+}
+
+func (in ZNCSpecStorage) GetReclaimPolicy() ZNCStorageReclaimPolicy {
+	reclaimPolicy := in.ReclaimPolicy
+	if len(reclaimPolicy) == 0 {
+		reclaimPolicy = ZNCStorageReclaimPolicyDelete
+	}
+	return reclaimPolicy
+}
+
+// ZNCSpecProbes overrides the probes of the ZNC container. By default, the liveness probe connects to the first
+// listener allowing IRC clients and requests the web interface, if a listener allows web clients. The readiness probe
+// connects to the first listener allowing IRC clients.
+type ZNCSpecProbes struct {
+
+	// Liveness overrides the liveness probe, which restarts ZNC if it stops responding.
+	// +optional
+	Liveness *ZNCSpecProbe `json:"liveness,omitempty"`
+
+	// Readiness overrides the readiness probe, which keeps ZNC out of the Service until it accepts connections.
+	// +optional
+	Readiness *ZNCSpecProbe `json:"readiness,omitempty"`
+}
+
+// ZNCSpecProbe overrides the thresholds of a probe. Omitted fields keep their defaults.
+type ZNCSpecProbe struct {
+
+	// Disabled removes the probe.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// InitialDelaySeconds is the number of seconds after the container has started before the probe is run.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+
+	// PeriodSeconds is the interval in seconds between two probes.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+
+	// TimeoutSeconds is the number of seconds after which a probe times out.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// SuccessThreshold is the number of consecutive successful probes after a failure for the probe to succeed. It
+	// must be 1 for the liveness probe.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	SuccessThreshold *int32 `json:"successThreshold,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed probes for the probe to fail.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
+// ZNCSpecRestart controls the graceful shutdown of ZNC. Before the pod is replaced, a notice is broadcast to all
+// connected clients and, once the grace period has passed, ZNC is shut down, so that it sends QUIT messages to all
+// networks.
+type ZNCSpecRestart struct {
+
+	// Notice is the message broadcast to all connected clients before ZNC is shut down.
+	// +optional
+	Notice string `json:"notice,omitempty"`
+
+	// GracePeriodSeconds is the number of seconds between the broadcast and the shutdown of ZNC.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	// +kubebuilder:validation:Default=30
+	GracePeriodSeconds *int32 `json:"gracePeriodSeconds,omitempty"`
+}
+
+func (in ZNCSpecRestart) GetNotice() string {
+	notice := in.Notice
+	if len(notice) == 0 {
+		notice = RestartNoticeDefault
+	}
+	return notice
+}
+
+func (in ZNCSpecRestart) GetGracePeriodSeconds() int32 {
+	if in.GracePeriodSeconds == nil {
+		return RestartGracePeriodSecondsDefault
+	}
+	return *in.GracePeriodSeconds
+}
+
+// SecretKeyRef references a key of a Secret in the namespace of the ZNC resource.
+type SecretKeyRef struct {
+
+	// Name is the name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the key within the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+}
+
+// ZNCPhase is a simple, high-level summary of where a ZNC instance is in its lifecycle.
+type ZNCPhase string
+
+const (
+	// ZNCPhasePending means that the ZNC instance has been accepted, but its pod is not running yet.
+	ZNCPhasePending ZNCPhase = "Pending"
+	// ZNCPhaseRunning means that the ZNC pod is running and all of its containers are ready.
+	ZNCPhaseRunning ZNCPhase = "Running"
+	// ZNCPhaseRestarting means that the ZNC pod is being replaced, e.g. due to a configuration change.
+	ZNCPhaseRestarting ZNCPhase = "Restarting"
+	// ZNCPhaseFailed means that the ZNC instance cannot be run, e.g. because its configuration cannot be rendered.
+	ZNCPhaseFailed ZNCPhase = "Failed"
+)
+
+// ZNCConditionType is the type of a condition reported in the status of a ZNC instance.
+type ZNCConditionType string
+
+const (
+	// ZNCConditionReady indicates whether the ZNC instance is fully functional.
+	ZNCConditionReady ZNCConditionType = "Ready"
+	// ZNCConditionConfigRendered indicates whether the ZNC configuration could be rendered from the spec.
+	ZNCConditionConfigRendered ZNCConditionType = "ConfigRendered"
+	// ZNCConditionPodHealthy indicates whether the ZNC pod is running and ready.
+	ZNCConditionPodHealthy ZNCConditionType = "PodHealthy"
+	// ZNCConditionNetworksConnected indicates whether all enabled networks are connected to IRC, or have not been
+	// disconnected for long.
+	ZNCConditionNetworksConnected ZNCConditionType = "NetworksConnected"
+)
+
+// ZNCCondition describes the state of a ZNC instance at a certain point.
+type ZNCCondition struct {
+
+	// Type of the condition.
+	Type ZNCConditionType `json:"type"`
+
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+
+	// LastTransitionTime is the last time the condition transitioned from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Reason is a unique, one-word, CamelCase reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human readable message indicating details about the last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ZNCEndpoint describes an address a listener of a ZNC instance can be reached at.
+type ZNCEndpoint struct {
+
+	// Name is the name of the listener.
+	Name string `json:"name"`
+
+	// Host is the host name or IP address of the endpoint.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Port is the port number of the endpoint.
+	Port int32 `json:"port"`
+
+	// NodePort is the port number the listener is exposed on each node, if any.
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+}
+
+// ZNCNetworkStatus describes the observed connection state of a network of a user.
+type ZNCNetworkStatus struct {
+
+	// User is the name of the user the network belongs to.
+	User string `json:"user"`
+
+	// Network is the name of the network.
+	Network string `json:"network"`
+
+	// Connected indicates whether ZNC is connected to an IRC server of the network.
+	Connected bool `json:"connected"`
+
+	// Server is the name of the IRC server ZNC is connected to.
+	// +optional
+	Server string `json:"server,omitempty"`
+
+	// Nick is the nick ZNC uses on the network.
+	// +optional
+	Nick string `json:"nick,omitempty"`
+
+	// Channels is the number of channels of the network, as reported by ZNC while it is connected.
+	// +optional
+	Channels int32 `json:"channels,omitempty"`
+
+	// LastTransitionTime is the last time the network connected or disconnected.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
 // ZNCStatus defines the observed state of ZNC
 type ZNCStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
-	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+
+	// Phase is a simple, high-level summary of where the ZNC instance is in its lifecycle.
+	// +optional
+	Phase ZNCPhase `json:"phase,omitempty"`
+
+	// Conditions represent the latest available observations of the ZNC instance's state.
+	// +optional
+	Conditions []ZNCCondition `json:"conditions,omitempty"`
+
+	// ObservedGeneration is the most recent generation observed by the operator.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ConfigChecksum is the checksum of the currently rendered ZNC configuration.
+	// +optional
+	ConfigChecksum string `json:"configChecksum,omitempty"`
+
+	// PodName is the name of the pod running the ZNC instance.
+	// +optional
+	PodName string `json:"podName,omitempty"`
+
+	// ServiceName is the name of the Service exposing the ZNC instance.
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// Endpoints lists the addresses the listeners of the ZNC instance can be reached at.
+	// +optional
+	Endpoints []ZNCEndpoint `json:"endpoints,omitempty"`
+
+	// Networks lists the connection state of the networks of all users, as last observed by querying ZNC.
+	// +optional
+	Networks []ZNCNetworkStatus `json:"networks,omitempty"`
+}
+
+// GetCondition returns the condition with the given type or nil, if no such condition exists.
+func (in *ZNCStatus) GetCondition(conditionType ZNCConditionType) *ZNCCondition {
+	for i := range in.Conditions {
+		if in.Conditions[i].Type == conditionType {
+			return &in.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition with the given type.
+// The last transition time is only updated if the status of the condition actually changes.
+func (in *ZNCStatus) SetCondition(conditionType ZNCConditionType, status corev1.ConditionStatus, reason, message string) {
+	if existing := in.GetCondition(conditionType); existing != nil {
+		if existing.Status != status {
+			existing.Status = status
+			existing.LastTransitionTime = metav1.Now()
+		}
+		existing.Reason = reason
+		existing.Message = message
+		return
+	}
+	in.Conditions = append(in.Conditions, ZNCCondition{
+		Type:               conditionType,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// IsConditionTrue returns whether the condition with the given type exists and has the status True.
+func (in *ZNCStatus) IsConditionTrue(conditionType ZNCConditionType) bool {
+	condition := in.GetCondition(conditionType)
+	return condition != nil && condition.Status == corev1.ConditionTrue
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -381,6 +1288,11 @@ type ZNCStatus struct {
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=zncs,scope=Namespaced
 // +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".spec.version",description="Version of this ZNC instance"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Lifecycle phase of this ZNC instance"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description="Whether this ZNC instance is ready"
+// +kubebuilder:printcolumn:name="Pod",type="string",JSONPath=".status.podName",description="Pod running this ZNC instance"
+// +kubebuilder:printcolumn:name="Checksum",type="string",JSONPath=".status.configChecksum",description="Checksum of the rendered configuration",priority=1
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type ZNC struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
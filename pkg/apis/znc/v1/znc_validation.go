@@ -0,0 +1,347 @@
+package v1
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// channelPrefixes are the characters IRC channel names may start with.
+const channelPrefixes = "#&+!"
+
+// The character sets of values written to znc.conf. Every value must fit on a single line, so that it cannot inject
+// settings or sections into the configuration.
+var (
+	// userNameRegexp matches the user names accepted by ZNC.
+	userNameRegexp = regexp.MustCompile(`^[A-Za-z0-9@._-]+$`)
+	// networkNameRegexp matches the network names accepted by ZNC.
+	networkNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// channelNameRegexp matches IRC channel names, which must not contain spaces, commas or control characters.
+	channelNameRegexp = regexp.MustCompile(`^[` + regexp.QuoteMeta(channelPrefixes) + `][^\x00-\x20\x7f,>]*$`)
+	// tokenRegexp matches single words of printable characters, e.g. nicks, idents or encodings.
+	tokenRegexp = regexp.MustCompile(`^[^\x00-\x20\x7f]+$`)
+	// textRegexp matches single lines of text, which may contain IRC formatting codes, e.g. quit messages.
+	textRegexp = regexp.MustCompile(`^[^\x00\r\n]*$`)
+)
+
+// Validate returns all errors found in the spec of the ZNC instance, which would otherwise only be discovered once
+// ZNC refuses to start.
+func (in *ZNC) Validate() field.ErrorList {
+	fldPath := field.NewPath("spec")
+	allErrs := ValidateZNCSpec(&in.Spec, fldPath)
+	// The reserved name is checked here rather than in ValidateZNCSpec, as the resolved spec validated by the renderer
+	// contains the operator user.
+	for i, user := range in.Spec.Config.Users {
+		if user.Name == OperatorUserName {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("config", "users").Index(i).Child("name"), user.Name, "is reserved for the operator"))
+		}
+	}
+	return allErrs
+}
+
+// ValidateZNCSpec validates the given spec.
+func ValidateZNCSpec(spec *ZNCSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := validateZNCSpecConfig(&spec.Config, spec.GetVersion(), spec.TLS != nil, fldPath.Child("config"))
+	allErrs = append(allErrs, validateText(spec.Restart.Notice, fldPath.Child("restart", "notice"))...)
+	if gracePeriodSeconds := spec.Restart.GetGracePeriodSeconds(); gracePeriodSeconds < 0 || gracePeriodSeconds > 3600 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("restart", "gracePeriodSeconds"), gracePeriodSeconds, "must be between 0 and 3600"))
+	}
+	if spec.Probes != nil {
+		allErrs = append(allErrs, validateProbes(spec.Probes, fldPath.Child("probes"))...)
+	}
+	if spec.Web != nil {
+		allErrs = append(allErrs, validateZNCSpecWeb(spec.Web, spec.Config.GetListeners(), fldPath.Child("web"))...)
+	}
+	return allErrs
+}
+
+func validateZNCSpecWeb(web *ZNCSpecWeb, listeners []ZNCSpecConfigListener, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(web.Listener) > 0 {
+		var listener *ZNCSpecConfigListener
+		for i := range listeners {
+			if listeners[i].Name == web.Listener {
+				listener = &listeners[i]
+			}
+		}
+		if listener == nil {
+			allErrs = append(allErrs, field.NotFound(fldPath.Child("listener"), web.Listener))
+		} else if !listener.GetAllowWeb() {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("listener"), web.Listener, "must allow web clients"))
+		}
+	} else if web.Ingress != nil || web.HTTPRoute != nil {
+		found := false
+		for _, listener := range listeners {
+			found = found || listener.GetAllowWeb()
+		}
+		if !found {
+			allErrs = append(allErrs, field.Required(fldPath.Child("listener"), "no listener allows web clients"))
+		}
+	}
+
+	if web.Ingress != nil {
+		hostPath := fldPath.Child("ingress", "host")
+		host := web.Ingress.Host
+		if len(host) == 0 {
+			allErrs = append(allErrs, field.Required(hostPath, ""))
+		} else if strings.HasPrefix(host, "*.") {
+			for _, msg := range validation.IsWildcardDNS1123Subdomain(host) {
+				allErrs = append(allErrs, field.Invalid(hostPath, host, msg))
+			}
+		} else {
+			for _, msg := range validation.IsDNS1123Subdomain(host) {
+				allErrs = append(allErrs, field.Invalid(hostPath, host, msg))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func validateZNCSpecConfig(config *ZNCSpecConfig, version string, hasTLS bool, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	listenerNames := map[string]bool{}
+	listenerPorts := map[int32]bool{}
+	for i, listener := range config.Listeners {
+		idxPath := fldPath.Child("listeners").Index(i)
+		// The names of listeners are used as names of container and Service ports.
+		if msgs := validation.IsValidPortName(listener.Name); len(msgs) > 0 {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), listener.Name, strings.Join(msgs, "; ")))
+		} else if listenerNames[listener.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), listener.Name))
+		}
+		listenerNames[listener.Name] = true
+		if listener.SSL && !hasTLS {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("ssl"), listener.SSL, "requires spec.tls to provide a certificate"))
+		}
+		if listenerPorts[listener.Port] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("port"), listener.Port))
+		}
+		listenerPorts[listener.Port] = true
+	}
+
+	for i, listener := range config.Listeners {
+		idxPath := fldPath.Child("listeners").Index(i)
+		allErrs = append(allErrs, validateToken(listener.Host, idxPath.Child("host"))...)
+		allErrs = append(allErrs, validateToken(listener.URIPrefix, idxPath.Child("uriPrefix"))...)
+	}
+	allErrs = append(allErrs, validateModules(config.LoadModules, ModuleScopeGlobal, version, fldPath.Child("loadModules"))...)
+	allErrs = append(allErrs, validateTexts(config.Motd, fldPath.Child("motd"))...)
+	allErrs = append(allErrs, validateToken(config.StatusPrefix, fldPath.Child("statusPrefix"))...)
+	for i, trustedProxy := range config.TrustedProxies {
+		allErrs = append(allErrs, validateToken(trustedProxy, fldPath.Child("trustedProxies").Index(i))...)
+	}
+
+	userNames := map[string]bool{}
+	for i := range config.Users {
+		idxPath := fldPath.Child("users").Index(i)
+		user := &config.Users[i]
+		if len(user.Name) == 0 {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), ""))
+		} else if !userNameRegexp.MatchString(user.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), user.Name, "must consist of alphanumeric characters, '@', '.', '_' or '-'"))
+		} else if userNames[user.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), user.Name))
+		}
+		userNames[user.Name] = true
+		allErrs = append(allErrs, validateZNCSpecConfigUser(user, config.MaxBufferSize, version, idxPath)...)
+	}
+
+	return allErrs
+}
+
+func validateZNCSpecConfigUser(user *ZNCSpecConfigUser, maxBufferSize int32, version string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	// Only admin users can exceed the maximum buffer size specified in the global section.
+	if !user.Admin && maxBufferSize > 0 {
+		for _, buffer := range []struct {
+			name string
+			size int32
+		}{
+			{"buffer", user.Buffer},
+			{"chanBufferSize", user.ChanBufferSize},
+			{"queryBufferSize", user.QueryBufferSize},
+		} {
+			if buffer.size > maxBufferSize {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child(buffer.name), buffer.size, fmt.Sprintf("must not exceed the global maxBufferSize of %d for non-admin users", maxBufferSize)))
+			}
+		}
+	}
+
+	for _, token := range []struct {
+		name  string
+		value string
+	}{
+		{"altNick", user.AltNick},
+		{"chanModes", user.ChanModes},
+		{"clientEncoding", user.ClientEncoding},
+		{"ident", user.Ident},
+		{"nick", user.Nick},
+		{"pass", user.Pass},
+		{"statusPrefix", user.StatusPrefix},
+		{"timezone", user.Timezone},
+	} {
+		allErrs = append(allErrs, validateToken(token.value, fldPath.Child(token.name))...)
+	}
+	for _, text := range []struct {
+		name  string
+		value string
+	}{
+		{"quitMsg", user.QuitMsg},
+		{"realName", user.RealName},
+		{"timestampFormat", user.TimestampFormat},
+	} {
+		allErrs = append(allErrs, validateText(text.value, fldPath.Child(text.name))...)
+	}
+	allErrs = append(allErrs, validateModules(user.LoadModules, ModuleScopeUser, version, fldPath.Child("loadModules"))...)
+	allErrs = append(allErrs, validateModuleData(user.ModuleData, fldPath.Child("moduleData"))...)
+	if len(user.Pass) == 0 && user.Password == nil && user.PasswordSecretRef == nil {
+		allErrs = append(allErrs, field.Required(fldPath.Child("passwordSecretRef"), "one of pass, password or passwordSecretRef is required"))
+	}
+	if user.Password != nil {
+		allErrs = append(allErrs, validateToken(user.Password.Hash, fldPath.Child("password", "hash"))...)
+		allErrs = append(allErrs, validateToken(user.Password.Salt, fldPath.Child("password", "salt"))...)
+	}
+
+	networkNames := map[string]bool{}
+	for i := range user.Networks {
+		idxPath := fldPath.Child("networks").Index(i)
+		network := &user.Networks[i]
+		if len(network.Name) == 0 {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), ""))
+		} else if !networkNameRegexp.MatchString(network.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), network.Name, "must consist of alphanumeric characters, '_' or '-'"))
+		} else if networkNames[network.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), network.Name))
+		}
+		networkNames[network.Name] = true
+		allErrs = append(allErrs, validateZNCSpecConfigUserNetwork(network, version, idxPath)...)
+	}
+
+	return allErrs
+}
+
+func validateZNCSpecConfigUserNetwork(network *ZNCSpecConfigUserNetwork, version string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(network.Servers) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("servers"), "at least one server is required"))
+	}
+	for i := range network.Servers {
+		allErrs = append(allErrs, validateServer(&network.Servers[i], fldPath.Child("servers").Index(i))...)
+	}
+
+	for _, token := range []struct {
+		name  string
+		value string
+	}{
+		{"altNick", network.AltNick},
+		{"encoding", network.Encoding},
+		{"ident", network.Ident},
+		{"nick", network.Nick},
+	} {
+		allErrs = append(allErrs, validateToken(token.value, fldPath.Child(token.name))...)
+	}
+	allErrs = append(allErrs, validateText(network.QuitMsg, fldPath.Child("quitMsg"))...)
+	allErrs = append(allErrs, validateText(network.RealName, fldPath.Child("realName"))...)
+	allErrs = append(allErrs, validateModules(network.LoadModules, ModuleScopeNetwork, version, fldPath.Child("loadModules"))...)
+	allErrs = append(allErrs, validateModuleData(network.ModuleData, fldPath.Child("moduleData"))...)
+	if network.SASL != nil {
+		allErrs = append(allErrs, validateZNCSpecConfigUserNetworkSASL(network.SASL, fldPath.Child("sasl"))...)
+	}
+	if network.NickServ != nil {
+		allErrs = append(allErrs, validateZNCSpecConfigUserNetworkNickServ(network.NickServ, fldPath.Child("nickserv"))...)
+	}
+	allErrs = append(allErrs, validatePerform(network.Perform, fldPath.Child("perform"))...)
+
+	channelNames := map[string]bool{}
+	for i, channel := range network.Channels {
+		idxPath := fldPath.Child("channels").Index(i)
+		namePath := idxPath.Child("name")
+		if !channelNameRegexp.MatchString(channel.Name) {
+			allErrs = append(allErrs, field.Invalid(namePath, channel.Name, fmt.Sprintf("must start with one of %q and must not contain whitespace, ',' or control characters", channelPrefixes)))
+		} else if channelNames[strings.ToLower(channel.Name)] {
+			allErrs = append(allErrs, field.Duplicate(namePath, channel.Name))
+		}
+		channelNames[strings.ToLower(channel.Name)] = true
+		if strings.Contains(channel.Key, ",") {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("key"), channel.Key, "must not contain ','"))
+		} else {
+			allErrs = append(allErrs, validateToken(channel.Key, idxPath.Child("key"))...)
+		}
+		allErrs = append(allErrs, validateText(channel.Modes, idxPath.Child("modes"))...)
+	}
+
+	return allErrs
+}
+
+func validateZNCSpecConfigUserNetworkSASL(sasl *ZNCSpecConfigUserNetworkSASL, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, validateToken(sasl.Username, fldPath.Child("username"))...)
+	switch sasl.GetMechanism() {
+	case SASLMechanismPlain:
+		if len(sasl.Username) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("username"), "required for the PLAIN mechanism"))
+		}
+		if sasl.PasswordSecretRef == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Child("passwordSecretRef"), "required for the PLAIN mechanism"))
+		}
+	case SASLMechanismExternal:
+		if len(sasl.CertificateSecretName) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("certificateSecretName"), "required for the EXTERNAL mechanism"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("mechanism"), sasl.Mechanism, []string{string(SASLMechanismPlain), string(SASLMechanismExternal)}))
+	}
+	return allErrs
+}
+
+func validateZNCSpecConfigUserNetworkNickServ(nickServ *ZNCSpecConfigUserNetworkNickServ, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if nickServ.PasswordSecretRef == nil {
+		allErrs = append(allErrs, field.Required(fldPath.Child("passwordSecretRef"), ""))
+	}
+	allErrs = append(allErrs, validateToken(nickServ.Name, fldPath.Child("name"))...)
+	allErrs = append(allErrs, validateText(nickServ.IdentifyCmd, fldPath.Child("identifyCmd"))...)
+	if len(nickServ.IdentifyCmd) > 0 && !strings.Contains(nickServ.IdentifyCmd, "{password}") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("identifyCmd"), nickServ.IdentifyCmd, "must contain {password}"))
+	}
+	return allErrs
+}
+
+// validateToken validates a value that must be a single word of printable characters.
+func validateToken(value string, fldPath *field.Path) field.ErrorList {
+	if len(value) > 0 && !tokenRegexp.MatchString(value) {
+		return field.ErrorList{field.Invalid(fldPath, value, "must not contain whitespace or control characters")}
+	}
+	return nil
+}
+
+// validateText validates a value that must be a single line of text.
+func validateText(value string, fldPath *field.Path) field.ErrorList {
+	if !textRegexp.MatchString(value) {
+		return field.ErrorList{field.Invalid(fldPath, value, "must not contain line breaks or NUL characters")}
+	}
+	if strings.TrimSpace(value) != value {
+		return field.ErrorList{field.Invalid(fldPath, value, "must not start or end with whitespace")}
+	}
+	return nil
+}
+
+// validateTexts validates a list of values that must be single lines of text.
+func validateTexts(values []string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, value := range values {
+		if len(value) == 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), value, "must not be empty"))
+		}
+		allErrs = append(allErrs, validateText(value, fldPath.Index(i))...)
+	}
+	return allErrs
+}
@@ -0,0 +1,238 @@
+package v1
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateZNCSpec(t *testing.T) {
+	spec := &ZNCSpec{
+		Config: ZNCSpecConfig{
+			MaxBufferSize: 500,
+			Users: []ZNCSpecConfigUser{
+				{
+					Name:   "johndoe",
+					Pass:   "sha256#0123abcd#salt#",
+					Buffer: 1000,
+					Networks: []ZNCSpecConfigUserNetwork{
+						{Name: "libera", Servers: []ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", TLS: true}}, Channels: []ZNCSpecConfigUserNetworkChan{{Name: "#znc"}, {Name: "znc"}}},
+						{Name: "libera", Servers: []ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", Port: 66667}}},
+						{Name: "oftc"},
+					},
+				},
+				{Name: "johndoe", Admin: true, Buffer: 1000},
+				{Name: "janedoe"},
+			},
+		},
+	}
+	expected := []string{
+		"spec.config.users[0].buffer",
+		"spec.config.users[0].networks[0].channels[1].name",
+		"spec.config.users[0].networks[1].name",
+		"spec.config.users[0].networks[1].servers[0].port",
+		"spec.config.users[0].networks[2].servers",
+		"spec.config.users[1].name",
+		"spec.config.users[1].passwordSecretRef",
+		"spec.config.users[2].passwordSecretRef",
+	}
+	allErrs := ValidateZNCSpec(spec, field.NewPath("spec"))
+	if len(allErrs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), allErrs)
+	}
+	for i, err := range allErrs {
+		if err.Field != expected[i] {
+			t.Errorf("expected error for %s, got %v", expected[i], err)
+		}
+	}
+
+	spec.Config.MaxBufferSize = 0
+	spec.Config.Users = spec.Config.Users[:1]
+	spec.Config.Users[0].Networks = spec.Config.Users[0].Networks[:1]
+	spec.Config.Users[0].Networks[0].Channels = spec.Config.Users[0].Networks[0].Channels[:1]
+	if allErrs := ValidateZNCSpec(spec, field.NewPath("spec")); len(allErrs) > 0 {
+		t.Errorf("expected valid spec, got %v", allErrs)
+	}
+}
+
+func TestValidateZNCSpecCharacterSets(t *testing.T) {
+	spec := &ZNCSpec{
+		Config: ZNCSpecConfig{
+			Motd: []string{"Welcome", "</User>\n<User evil>"},
+			Users: []ZNCSpecConfigUser{
+				{
+					Name:     "john doe",
+					Pass:     "sha256#0123abcd#salt#",
+					Nick:     "john\tdoe",
+					QuitMsg:  " bye",
+					RealName: "John Doe",
+					Networks: []ZNCSpecConfigUserNetwork{
+						{
+							Name:     "libera>",
+							Servers:  []ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", Password: "secret\x00"}},
+							Channels: []ZNCSpecConfigUserNetworkChan{{Name: "#znc>", Key: "a,b", Modes: "+s\r"}},
+						},
+					},
+				},
+			},
+		}, Restart: ZNCSpecRestart{Notice: "Restarting\r\nQUIT"},
+	}
+	expected := []string{
+		"spec.config.motd[1]",
+		"spec.config.users[0].name",
+		"spec.config.users[0].nick",
+		"spec.config.users[0].quitMsg",
+		"spec.config.users[0].networks[0].name",
+		"spec.config.users[0].networks[0].servers[0].password",
+		"spec.config.users[0].networks[0].channels[0].name",
+		"spec.config.users[0].networks[0].channels[0].key",
+		"spec.config.users[0].networks[0].channels[0].modes",
+		"spec.restart.notice",
+	}
+	allErrs := ValidateZNCSpec(spec, field.NewPath("spec"))
+	if len(allErrs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), allErrs)
+	}
+	for i, err := range allErrs {
+		if err.Field != expected[i] {
+			t.Errorf("expected error for %s, got %v", expected[i], err)
+		}
+	}
+}
+
+func TestValidateZNCSpecConfigListeners(t *testing.T) {
+	spec := &ZNCSpec{
+		Config: ZNCSpecConfig{
+			Listeners: []ZNCSpecConfigListener{
+				{Name: "6697", Port: 6697, SSL: true},
+				{Name: "irc-with-a-long-name", Port: 6667},
+				{Name: "web", Port: 8080},
+				{Name: "web", Port: 8443},
+			},
+		},
+	}
+	expected := []string{
+		"spec.config.listeners[0].name",
+		"spec.config.listeners[0].ssl",
+		"spec.config.listeners[1].name",
+		"spec.config.listeners[3].name",
+	}
+	allErrs := ValidateZNCSpec(spec, field.NewPath("spec"))
+	if len(allErrs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), allErrs)
+	}
+	for i, err := range allErrs {
+		if err.Field != expected[i] {
+			t.Errorf("expected error for %s, got %v", expected[i], err)
+		}
+	}
+
+	spec.TLS = &ZNCSpecTLS{SecretName: "znc-tls"}
+	spec.Config.Listeners = []ZNCSpecConfigListener{{Name: "ircs", Port: 6697, SSL: true}, {Name: "web", Port: 8080}}
+	if allErrs := ValidateZNCSpec(spec, field.NewPath("spec")); len(allErrs) > 0 {
+		t.Errorf("expected valid listeners, got %v", allErrs)
+	}
+}
+
+func TestValidateZNCSpecWeb(t *testing.T) {
+	tests := []struct {
+		name     string
+		web      ZNCSpecWeb
+		expected []string
+	}{
+		{
+			name: "valid",
+			web:  ZNCSpecWeb{Listener: "web", Ingress: &ZNCSpecWebIngress{Host: "znc.example.com"}},
+		},
+		{
+			name: "wildcard host",
+			web:  ZNCSpecWeb{Ingress: &ZNCSpecWebIngress{Host: "*.example.com"}},
+		},
+		{
+			name:     "missing host",
+			web:      ZNCSpecWeb{Ingress: &ZNCSpecWebIngress{}},
+			expected: []string{"spec.web.ingress.host"},
+		},
+		{
+			name:     "invalid host",
+			web:      ZNCSpecWeb{Ingress: &ZNCSpecWebIngress{Host: "ZNC example"}},
+			expected: []string{"spec.web.ingress.host"},
+		},
+		{
+			name:     "unknown listener",
+			web:      ZNCSpecWeb{Listener: "webs", HTTPRoute: &ZNCSpecWebHTTPRoute{}},
+			expected: []string{"spec.web.listener"},
+		},
+		{
+			name:     "IRC listener",
+			web:      ZNCSpecWeb{Listener: "irc", HTTPRoute: &ZNCSpecWebHTTPRoute{}},
+			expected: []string{"spec.web.listener"},
+		},
+	}
+	for _, test := range tests {
+		spec := &ZNCSpec{Web: &test.web}
+		allErrs := ValidateZNCSpec(spec, field.NewPath("spec"))
+		if len(allErrs) != len(test.expected) {
+			t.Errorf("%s: expected %d errors, got %v", test.name, len(test.expected), allErrs)
+			continue
+		}
+		for i, err := range allErrs {
+			if err.Field != test.expected[i] {
+				t.Errorf("%s: expected error for %s, got %v", test.name, test.expected[i], err)
+			}
+		}
+	}
+
+	deny := false
+	spec := &ZNCSpec{
+		Config: ZNCSpecConfig{Listeners: []ZNCSpecConfigListener{{Name: "irc", Port: 6667, AllowWeb: &deny}}},
+		Web:    &ZNCSpecWeb{HTTPRoute: &ZNCSpecWebHTTPRoute{}},
+	}
+	if allErrs := ValidateZNCSpec(spec, field.NewPath("spec")); len(allErrs) != 1 || allErrs[0].Field != "spec.web.listener" {
+		t.Errorf("expected a listener allowing web clients to be required, got %v", allErrs)
+	}
+}
+
+func TestValidateZNCSpecConfigUserNetworkAuthentication(t *testing.T) {
+	secretRef := &SecretKeyRef{Name: "irc-accounts", Key: "libera"}
+	network := &ZNCSpecConfigUserNetwork{
+		Name:    "libera",
+		Servers: []ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", TLS: true}},
+		SASL:    &ZNCSpecConfigUserNetworkSASL{Username: "john doe"},
+		NickServ: &ZNCSpecConfigUserNetworkNickServ{
+			PasswordSecretRef: secretRef,
+			IdentifyCmd:       "PRIVMSG NickServ :IDENTIFY",
+		},
+	}
+	expected := []string{
+		"network.sasl.username",
+		"network.sasl.passwordSecretRef",
+		"network.nickserv.identifyCmd",
+	}
+	allErrs := validateZNCSpecConfigUserNetwork(network, VersionDefault, field.NewPath("network"))
+	if len(allErrs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), allErrs)
+	}
+	for i, err := range allErrs {
+		if err.Field != expected[i] {
+			t.Errorf("expected error for %s, got %v", expected[i], err)
+		}
+	}
+
+	network.SASL = &ZNCSpecConfigUserNetworkSASL{Mechanism: SASLMechanismExternal, CertificateSecretName: "libera-cert"}
+	network.NickServ.IdentifyCmd = ""
+	if allErrs := validateZNCSpecConfigUserNetwork(network, VersionDefault, field.NewPath("network")); len(allErrs) > 0 {
+		t.Errorf("expected valid network, got %v", allErrs)
+	}
+}
+
+func TestValidateReservedUserName(t *testing.T) {
+	znc := &ZNC{Spec: ZNCSpec{Config: ZNCSpecConfig{Users: []ZNCSpecConfigUser{{Name: "johndoe", Pass: "sha256#0123abcd#salt#"}, {Name: OperatorUserName, Password: &ZNCSpecConfigUserPass{Hash: "0123abcd", Salt: "salt"}}}}}}
+	allErrs := znc.Validate()
+	if len(allErrs) != 1 || allErrs[0].Field != "spec.config.users[1].name" {
+		t.Errorf("expected the operator user name to be reserved, got %v", allErrs)
+	}
+	if allErrs := ValidateZNCSpec(&znc.Spec, field.NewPath("spec")); len(allErrs) > 0 {
+		t.Errorf("expected resolved specs to allow the operator user, got %v", allErrs)
+	}
+}
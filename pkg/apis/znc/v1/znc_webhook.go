@@ -0,0 +1,48 @@
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:path=/mutate-znc-in-v1-znc,mutating=true,failurePolicy=fail,groups=znc.in,resources=zncs,verbs=create;update,versions=v1,name=mznc.znc.in
+// +kubebuilder:webhook:path=/validate-znc-in-v1-znc,mutating=false,failurePolicy=fail,groups=znc.in,resources=zncs,verbs=create;update,versions=v1,name=vznc.znc.in
+
+// blank assignments to verify that ZNC implements admission.Defaulter and admission.Validator
+var _ admission.Defaulter = &ZNC{}
+var _ admission.Validator = &ZNC{}
+
+// SetupWebhookWithManager registers the admission webhooks for ZNC resources with the webhook server of mgr.
+func (in *ZNC) SetupWebhookWithManager(mgr manager.Manager) error {
+	return builder.WebhookManagedBy(mgr).For(in).Complete()
+}
+
+// Default implements admission.Defaulter.
+func (in *ZNC) Default() {
+	in.Spec.SetDefaults()
+}
+
+// ValidateCreate implements admission.Validator.
+func (in *ZNC) ValidateCreate() error {
+	return in.validate()
+}
+
+// ValidateUpdate implements admission.Validator.
+func (in *ZNC) ValidateUpdate(old runtime.Object) error {
+	return in.validate()
+}
+
+// ValidateDelete implements admission.Validator.
+func (in *ZNC) ValidateDelete() error {
+	return nil
+}
+
+func (in *ZNC) validate() error {
+	if allErrs := in.Validate(); len(allErrs) > 0 {
+		return apierrors.NewInvalid(SchemeGroupVersion.WithKind("ZNC").GroupKind(), in.Name, allErrs)
+	}
+	return nil
+}
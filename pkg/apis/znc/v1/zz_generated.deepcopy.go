@@ -5,16 +5,33 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZNC) DeepCopyInto(out *ZNC) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -36,6 +53,39 @@ func (in *ZNC) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCCondition) DeepCopyInto(out *ZNCCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCCondition.
+func (in *ZNCCondition) DeepCopy() *ZNCCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCEndpoint) DeepCopyInto(out *ZNCEndpoint) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCEndpoint.
+func (in *ZNCEndpoint) DeepCopy() *ZNCEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZNCList) DeepCopyInto(out *ZNCList) {
 	*out = *in
@@ -69,10 +119,49 @@ func (in *ZNCList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCNetworkStatus) DeepCopyInto(out *ZNCNetworkStatus) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCNetworkStatus.
+func (in *ZNCNetworkStatus) DeepCopy() *ZNCNetworkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCNetworkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZNCSpec) DeepCopyInto(out *ZNCSpec) {
 	*out = *in
 	in.Config.DeepCopyInto(&out.Config)
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(ZNCSpecProbes)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Restart.DeepCopyInto(&out.Restart)
+	in.Service.DeepCopyInto(&out.Service)
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(ZNCSpecStorage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(ZNCSpecTLS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Web != nil {
+		in, out := &in.Web, &out.Web
+		*out = new(ZNCSpecWeb)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -89,9 +178,16 @@ func (in *ZNCSpec) DeepCopy() *ZNCSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZNCSpecConfig) DeepCopyInto(out *ZNCSpecConfig) {
 	*out = *in
+	if in.Listeners != nil {
+		in, out := &in.Listeners, &out.Listeners
+		*out = make([]ZNCSpecConfigListener, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.LoadModules != nil {
 		in, out := &in.LoadModules, &out.LoadModules
-		*out = make([]string, len(*in))
+		*out = make([]ZNCSpecModule, len(*in))
 		copy(*out, *in)
 	}
 	if in.Motd != nil {
@@ -99,6 +195,11 @@ func (in *ZNCSpecConfig) DeepCopyInto(out *ZNCSpecConfig) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.TrustedProxies != nil {
+		in, out := &in.TrustedProxies, &out.TrustedProxies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Users != nil {
 		in, out := &in.Users, &out.Users
 		*out = make([]ZNCSpecConfigUser, len(*in))
@@ -119,14 +220,72 @@ func (in *ZNCSpecConfig) DeepCopy() *ZNCSpecConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecConfigListener) DeepCopyInto(out *ZNCSpecConfigListener) {
+	*out = *in
+	if in.AllowIRC != nil {
+		in, out := &in.AllowIRC, &out.AllowIRC
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowWeb != nil {
+		in, out := &in.AllowWeb, &out.AllowWeb
+		*out = new(bool)
+		**out = **in
+	}
+	if in.IPv4 != nil {
+		in, out := &in.IPv4, &out.IPv4
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecConfigListener.
+func (in *ZNCSpecConfigListener) DeepCopy() *ZNCSpecConfigListener {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecConfigListener)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZNCSpecConfigUser) DeepCopyInto(out *ZNCSpecConfigUser) {
 	*out = *in
 	if in.LoadModules != nil {
 		in, out := &in.LoadModules, &out.LoadModules
-		*out = make([]string, len(*in))
+		*out = make([]ZNCSpecModule, len(*in))
 		copy(*out, *in)
 	}
+	if in.ModuleData != nil {
+		in, out := &in.ModuleData, &out.ModuleData
+		*out = make(map[string]map[string]string, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(ZNCSpecConfigUserPass)
+		**out = **in
+	}
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
 	if in.Networks != nil {
 		in, out := &in.Networks, &out.Networks
 		*out = make([]ZNCSpecConfigUserNetwork, len(*in))
@@ -150,21 +309,62 @@ func (in *ZNCSpecConfigUser) DeepCopy() *ZNCSpecConfigUser {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZNCSpecConfigUserNetwork) DeepCopyInto(out *ZNCSpecConfigUserNetwork) {
 	*out = *in
+	if in.IRCConnectEnabled != nil {
+		in, out := &in.IRCConnectEnabled, &out.IRCConnectEnabled
+		*out = new(bool)
+		**out = **in
+	}
 	if in.LoadModules != nil {
 		in, out := &in.LoadModules, &out.LoadModules
-		*out = make([]string, len(*in))
+		*out = make([]ZNCSpecModule, len(*in))
 		copy(*out, *in)
 	}
+	if in.ModuleData != nil {
+		in, out := &in.ModuleData, &out.ModuleData
+		*out = make(map[string]map[string]string, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
 	if in.Servers != nil {
 		in, out := &in.Servers, &out.Servers
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+		*out = make([]ZNCSpecConfigUserNetworkServer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Channels != nil {
 		in, out := &in.Channels, &out.Channels
 		*out = make([]ZNCSpecConfigUserNetworkChan, len(*in))
 		copy(*out, *in)
 	}
+	if in.SASL != nil {
+		in, out := &in.SASL, &out.SASL
+		*out = new(ZNCSpecConfigUserNetworkSASL)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NickServ != nil {
+		in, out := &in.NickServ, &out.NickServ
+		*out = new(ZNCSpecConfigUserNetworkNickServ)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Perform != nil {
+		in, out := &in.Perform, &out.Perform
+		*out = make([]ZNCSpecConfigUserNetworkPerform, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -194,6 +394,90 @@ func (in *ZNCSpecConfigUserNetworkChan) DeepCopy() *ZNCSpecConfigUserNetworkChan
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecConfigUserNetworkNickServ) DeepCopyInto(out *ZNCSpecConfigUserNetworkNickServ) {
+	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecConfigUserNetworkNickServ.
+func (in *ZNCSpecConfigUserNetworkNickServ) DeepCopy() *ZNCSpecConfigUserNetworkNickServ {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecConfigUserNetworkNickServ)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecConfigUserNetworkPerform) DeepCopyInto(out *ZNCSpecConfigUserNetworkPerform) {
+	*out = *in
+	if in.CommandSecretRef != nil {
+		in, out := &in.CommandSecretRef, &out.CommandSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecConfigUserNetworkPerform.
+func (in *ZNCSpecConfigUserNetworkPerform) DeepCopy() *ZNCSpecConfigUserNetworkPerform {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecConfigUserNetworkPerform)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecConfigUserNetworkSASL) DeepCopyInto(out *ZNCSpecConfigUserNetworkSASL) {
+	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecConfigUserNetworkSASL.
+func (in *ZNCSpecConfigUserNetworkSASL) DeepCopy() *ZNCSpecConfigUserNetworkSASL {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecConfigUserNetworkSASL)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecConfigUserNetworkServer) DeepCopyInto(out *ZNCSpecConfigUserNetworkServer) {
+	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecConfigUserNetworkServer.
+func (in *ZNCSpecConfigUserNetworkServer) DeepCopy() *ZNCSpecConfigUserNetworkServer {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecConfigUserNetworkServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZNCSpecConfigUserPass) DeepCopyInto(out *ZNCSpecConfigUserPass) {
 	*out = *in
@@ -210,9 +494,345 @@ func (in *ZNCSpecConfigUserPass) DeepCopy() *ZNCSpecConfigUserPass {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecModule) DeepCopyInto(out *ZNCSpecModule) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecModule.
+func (in *ZNCSpecModule) DeepCopy() *ZNCSpecModule {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecModule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecProbe) DeepCopyInto(out *ZNCSpecProbe) {
+	*out = *in
+	if in.InitialDelaySeconds != nil {
+		in, out := &in.InitialDelaySeconds, &out.InitialDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PeriodSeconds != nil {
+		in, out := &in.PeriodSeconds, &out.PeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SuccessThreshold != nil {
+		in, out := &in.SuccessThreshold, &out.SuccessThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailureThreshold != nil {
+		in, out := &in.FailureThreshold, &out.FailureThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecProbe.
+func (in *ZNCSpecProbe) DeepCopy() *ZNCSpecProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecProbes) DeepCopyInto(out *ZNCSpecProbes) {
+	*out = *in
+	if in.Liveness != nil {
+		in, out := &in.Liveness, &out.Liveness
+		*out = new(ZNCSpecProbe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(ZNCSpecProbe)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecProbes.
+func (in *ZNCSpecProbes) DeepCopy() *ZNCSpecProbes {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecProbes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecRestart) DeepCopyInto(out *ZNCSpecRestart) {
+	*out = *in
+	if in.GracePeriodSeconds != nil {
+		in, out := &in.GracePeriodSeconds, &out.GracePeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecRestart.
+func (in *ZNCSpecRestart) DeepCopy() *ZNCSpecRestart {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecRestart)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecService) DeepCopyInto(out *ZNCSpecService) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]ZNCSpecServicePort, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecService.
+func (in *ZNCSpecService) DeepCopy() *ZNCSpecService {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecServicePort) DeepCopyInto(out *ZNCSpecServicePort) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecServicePort.
+func (in *ZNCSpecServicePort) DeepCopy() *ZNCSpecServicePort {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecServicePort)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecStorage) DeepCopyInto(out *ZNCSpecStorage) {
+	*out = *in
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]corev1.PersistentVolumeAccessMode, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecStorage.
+func (in *ZNCSpecStorage) DeepCopy() *ZNCSpecStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecTLS) DeepCopyInto(out *ZNCSpecTLS) {
+	*out = *in
+	if in.IssuerRef != nil {
+		in, out := &in.IssuerRef, &out.IssuerRef
+		*out = new(ZNCSpecTLSIssuerRef)
+		**out = **in
+	}
+	if in.DNSNames != nil {
+		in, out := &in.DNSNames, &out.DNSNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecTLS.
+func (in *ZNCSpecTLS) DeepCopy() *ZNCSpecTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecTLSIssuerRef) DeepCopyInto(out *ZNCSpecTLSIssuerRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecTLSIssuerRef.
+func (in *ZNCSpecTLSIssuerRef) DeepCopy() *ZNCSpecTLSIssuerRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecTLSIssuerRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecWeb) DeepCopyInto(out *ZNCSpecWeb) {
+	*out = *in
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(ZNCSpecWebIngress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HTTPRoute != nil {
+		in, out := &in.HTTPRoute, &out.HTTPRoute
+		*out = new(ZNCSpecWebHTTPRoute)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecWeb.
+func (in *ZNCSpecWeb) DeepCopy() *ZNCSpecWeb {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecWeb)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecWebHTTPRoute) DeepCopyInto(out *ZNCSpecWebHTTPRoute) {
+	*out = *in
+	if in.ParentRefs != nil {
+		in, out := &in.ParentRefs, &out.ParentRefs
+		*out = make([]ZNCSpecWebHTTPRouteParentRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.Hostnames != nil {
+		in, out := &in.Hostnames, &out.Hostnames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecWebHTTPRoute.
+func (in *ZNCSpecWebHTTPRoute) DeepCopy() *ZNCSpecWebHTTPRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecWebHTTPRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecWebHTTPRouteParentRef) DeepCopyInto(out *ZNCSpecWebHTTPRouteParentRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecWebHTTPRouteParentRef.
+func (in *ZNCSpecWebHTTPRouteParentRef) DeepCopy() *ZNCSpecWebHTTPRouteParentRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecWebHTTPRouteParentRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZNCSpecWebIngress) DeepCopyInto(out *ZNCSpecWebIngress) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZNCSpecWebIngress.
+func (in *ZNCSpecWebIngress) DeepCopy() *ZNCSpecWebIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(ZNCSpecWebIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZNCStatus) DeepCopyInto(out *ZNCStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]ZNCCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]ZNCEndpoint, len(*in))
+		copy(*out, *in)
+	}
+	if in.Networks != nil {
+		in, out := &in.Networks, &out.Networks
+		*out = make([]ZNCNetworkStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -1,126 +1,181 @@
 package znc
 
 import (
-	"bytes"
-	"text/template"
-	zncv1 "znc-operator/pkg/apis/znc/v1"
-)
+	"strings"
 
-func configurationTemplate() string {
-	return `// Automatically generated configuration file.
-Version = {{ .GetVersion }}
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+	"znc-operator/pkg/zncconf"
 
-<Listener irc>
-        AllowIRC = true
-        AllowWeb = false
-        Port = 6667
-        IPv4 = true
-        IPv6 = false
-        SSL = false
-</Listener>
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
 
-<Listener web>
-        AllowIRC = false
-        AllowWeb = true
-        Port = 8080
-        IPv4 = true
-        IPv6 = false
-        SSL = false
-</Listener>
+// configurationHeader is written in front of every rendered configuration.
+const configurationHeader = "// Automatically generated configuration file.\n"
 
-{{- with .GetConfig }}
-{{- range .LoadModules }}
-LoadModule = {{ . }}
-{{- end }}
+// newConfiguration returns the znc.conf document for the given spec.
+func newConfiguration(spec *zncv1.ZNCSpec) (*zncconf.Block, error) {
+	config := spec.GetConfig()
+	root := zncconf.NewBlock("", "")
+	root.Add("Version", spec.GetVersion())
+	addModules(root, config.LoadModules)
+	root.Add("AnonIPLimit", config.AnonIPLimit)
+	root.Add("ConnectDelay", config.ConnectDelay)
+	root.Add("HideVersion", config.HideVersion)
+	root.Add("MaxBufferSize", config.MaxBufferSize)
+	root.AddAll("Motd", config.Motd)
+	root.Add("ServerThrottle", config.ServerThrottle)
+	root.Add("StatusPrefix", config.GetStatusPrefix())
+	root.AddAll("TrustedProxy", config.TrustedProxies)
+	for _, listener := range config.GetListeners() {
+		addListener(root, listener)
+	}
+	for _, user := range config.Users {
+		if err := addUser(root, user); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
 
-AnonIPLimit = {{ .AnonIPLimit }}
-ConnectDelay = {{ .ConnectDelay }}
-HideVersion = {{ .HideVersion }}
-MaxBufferSize = {{ .MaxBufferSize }}
+// addModules adds a LoadModule setting for each of the given modules.
+func addModules(parent *zncconf.Block, modules []zncv1.ZNCSpecModule) {
+	for _, module := range modules {
+		parent.Add("LoadModule", module.String())
+	}
+}
 
-{{- range .Motd }}
-Motd = {{ . }}
-{{- end }}
+func addListener(parent *zncconf.Block, listener zncv1.ZNCSpecConfigListener) {
+	block := parent.AddBlock("Listener", listener.Name)
+	block.Add("AllowIRC", listener.GetAllowIRC())
+	block.Add("AllowWeb", listener.GetAllowWeb())
+	block.AddNonEmpty("Host", listener.Host)
+	block.Add("Port", listener.Port)
+	block.Add("IPv4", listener.GetIPv4())
+	block.Add("IPv6", listener.IPv6)
+	block.Add("SSL", listener.SSL)
+	block.AddNonEmpty("URIPrefix", listener.URIPrefix)
+}
 
-ServerThrottle = {{ .ServerThrottle }}
-StatusPrefix = {{ .GetStatusPrefix }}
+func addUser(parent *zncconf.Block, user zncv1.ZNCSpecConfigUser) error {
+	pass, err := renderPass(user)
+	if err != nil {
+		return err
+	}
+	block := parent.AddBlock("User", user.Name)
+	block.Add("Admin", user.Admin)
+	if user.Name == zncv1.OperatorUserName {
+		block.AddAll("Allow", operatorUserAllow)
+	} else {
+		block.Add("Allow", "*")
+	}
+	block.AddNonEmpty("AltNick", user.AltNick)
+	block.Add("AppendTimestamp", user.AppendTimestamp)
+	block.Add("AutoClearChanBuffer", user.AutoClearChanBuffer)
+	block.Add("AutoClearQueryBuffer", user.AutoClearQueryBuffer)
+	block.Add("Buffer", user.Buffer)
+	block.Add("ChanBufferSize", user.ChanBufferSize)
+	block.Add("ChanModes", user.GetChanModes())
+	block.Add("ClientEncoding", user.GetClientEncoding())
+	block.Add("Ident", user.GetIdent())
+	block.Add("JoinTries", user.JoinTries)
+	addModules(block, user.LoadModules)
+	block.Add("MaxJoins", user.MaxJoins)
+	block.Add("MaxQueryBuffers", user.MaxQueryBuffers)
+	block.Add("MultiClients", user.MultiClients)
+	block.AddNonEmpty("Nick", user.Nick)
+	block.Add("NoTrafficTimeout", user.NoTrafficTimeout)
+	block.Add("PrependTimestamp", user.PrependTimestamp)
+	block.Add("QueryBufferSize", user.QueryBufferSize)
+	block.AddNonEmpty("QuitMsg", user.QuitMsg)
+	block.AddNonEmpty("RealName", user.RealName)
+	block.Add("StatusPrefix", user.GetStatusPrefix())
+	block.AddNonEmpty("TimestampFormat", user.TimestampFormat)
+	block.AddNonEmpty("Timezone", user.Timezone)
+	block.AddNonEmpty("Pass", pass)
+	for _, network := range user.Networks {
+		addNetwork(block, network)
+	}
+	return nil
+}
 
-{{- range .Users }}
-<User {{ .Name }}>
-        Admin = {{ .Admin }}
-        Allow = *
-        AltNick = {{ .AltNick }}
-        AppendTimestamp = {{ .AppendTimestamp }}
-        AutoClearChanBuffer = {{ .AutoClearChanBuffer }}
-        AutoClearQueryBuffer = {{ .AutoClearQueryBuffer }}
-        Buffer = {{ .Buffer }}
-        ChanBufferSize = {{ .ChanBufferSize }}
-        ChanModes = {{ .GetChanModes }}
-        ClientEncoding = {{ .GetClientEncoding }}
-        Ident = {{ .GetIdent }}
-        JoinTries = {{ .JoinTries }}
-        {{- range .LoadModules }}
-        LoadModule = {{ . }}
-        {{- end }}
-        MaxJoins = {{ .MaxJoins }}
-        MaxQueryBuffers = {{ .MaxQueryBuffers }}
-        MultiClients = {{ .MultiClients }}
-        Nick = {{ .Nick }}
-        NoTrafficTimeout = {{ .NoTrafficTimeout }}
-        PrependTimestamp = {{ .PrependTimestamp }}
-        QueryBufferSize = {{ .QueryBufferSize }}
-        {{- if .QuitMsg }}QuitMsg = {{ .QuitMsg }}{{ end }}
-        {{- if .RealName }}RealName = {{ .RealName }}{{ end }}
-        StatusPrefix = {{ .GetStatusPrefix }}
-        {{- if .TimestampFormat }}TimestampFormat = {{ .TimestampFormat }}{{ end }}
-        {{- if .Timezone }}Timezone = {{ .Timezone }}{{ end }}
-        Pass = {{ .Pass }}
-        {{- range .Networks }}
-        <Network {{ .Name }}>
-                {{- if .AltNick }}AltNick = {{ .AltNick }}{{ end }}
-                {{- if .Encoding }}Encoding = {{ .Encoding }}{{ end }}
-                {{- if .Ident }}Ident = {{ .Ident }}{{ end }}
-                IRCConnectEnabled = {{ .IRCConnectEnabled }}
-                {{- if .JoinDelay }}JoinDelay = {{ .JoinDelay }}{{ end }}
-                {{- range .LoadModules }}
-                LoadModule = {{ . }}
-                {{- end }}
-                {{- if .Nick }}Nick = {{ .Nick }}{{ end }}
-                {{- if .QuitMsg }}QuitMsg = {{ .QuitMsg }}{{ end }}
-                {{- if .RealName }}RealName = {{ .RealName }}{{ end }}
-                {{- range .Servers }}
-                Server = {{ . }}
-                {{- end }}
-                {{- range .Channels }}
-                <Channel {{ .Name }}>
-                        AutoClearChanBuffer = {{ .AutoClearChanBuffer }}
-                        Buffer = {{ .Buffer }}
-                        Detached = {{ .Detached }}
-                        Disabled = {{ .Disabled }}
-                        {{- if .Key }}Key = {{ .Key }}{{ end }}
-                        {{- if .Modes }}Modes = {{ .Modes }}{{ end }}
-                </Channel>
-                {{- end }}
-        </Network>
-        {{- end }}
-</User>
-{{- end }}
+func addNetwork(parent *zncconf.Block, network zncv1.ZNCSpecConfigUserNetwork) {
+	block := parent.AddBlock("Network", network.Name)
+	block.AddNonEmpty("AltNick", network.AltNick)
+	block.AddNonEmpty("Encoding", network.Encoding)
+	block.AddNonEmpty("Ident", network.Ident)
+	block.Add("IRCConnectEnabled", network.GetIRCConnectEnabled())
+	if network.JoinDelay != 0 {
+		block.Add("JoinDelay", network.JoinDelay)
+	}
+	addModules(block, network.GetLoadModules())
+	block.AddNonEmpty("Nick", network.Nick)
+	block.AddNonEmpty("QuitMsg", network.QuitMsg)
+	block.AddNonEmpty("RealName", network.RealName)
+	for _, server := range network.Servers {
+		block.Add("Server", server.String())
+	}
+	for _, channel := range network.Channels {
+		addChannel(block, channel)
+	}
+}
 
-{{- end }}
-`
+func addChannel(parent *zncconf.Block, channel zncv1.ZNCSpecConfigUserNetworkChan) {
+	block := parent.AddBlock("Chan", channel.Name)
+	block.Add("AutoClearChanBuffer", channel.AutoClearChanBuffer)
+	block.Add("Buffer", channel.Buffer)
+	block.Add("Detached", channel.Detached)
+	block.Add("Disabled", channel.Disabled)
+	block.AddNonEmpty("Key", channel.Key)
+	block.AddNonEmpty("Modes", channel.Modes)
 }
 
+// RenderConfiguration renders the znc.conf for the given (resolved) spec.
+// Specs containing values outside of the character sets accepted for their fields are rejected, so that no value can
+// inject settings or sections into the configuration.
 func RenderConfiguration(spec *zncv1.ZNCSpec) (cfg string, err error) {
-	tmpl, err := template.New("znc-config").Parse(configurationTemplate())
+	if allErrs := zncv1.ValidateZNCSpec(spec, field.NewPath("spec")); len(allErrs) > 0 {
+		return "", allErrs.ToAggregate()
+	}
+	root, err := newConfiguration(spec)
 	if err != nil {
 		return "", err
 	}
-
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, spec)
+	zncConf, err := zncconf.Marshal(root)
 	if err != nil {
 		return "", err
 	}
+	return configurationHeader + string(zncConf), nil
+}
 
-	return buf.String(), nil
+// redactConfiguration returns the given rendered configuration without the password hashes of the users and the
+// passwords of the servers, so that it can be shown to everyone allowed to read the ConfigMap of the instance.
+func redactConfiguration(zncConf string) (string, error) {
+	root, err := zncconf.Parse(strings.NewReader(zncConf))
+	if err != nil {
+		return "", err
+	}
+	users, _ := nestedBlocks(root, "User")
+	for _, user := range users {
+		var settings []zncconf.Setting
+		for _, setting := range user.Settings {
+			if !strings.EqualFold(setting.Key, "Pass") {
+				settings = append(settings, setting)
+			}
+		}
+		user.Settings = settings
+		networks, _ := nestedBlocks(user, "Network")
+		for _, network := range networks {
+			for i := range network.Settings {
+				// Servers are rendered as <host> [[+]port] [password].
+				if fields := strings.Fields(network.Settings[i].Value); strings.EqualFold(network.Settings[i].Key, "Server") && len(fields) > 2 {
+					network.Settings[i].Value = fields[0] + " " + fields[1]
+				}
+			}
+		}
+	}
+	redacted, err := zncconf.Marshal(root)
+	if err != nil {
+		return "", err
+	}
+	return configurationHeader + string(redacted), nil
 }
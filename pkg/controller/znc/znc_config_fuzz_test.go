@@ -0,0 +1,225 @@
+package znc
+
+import (
+	"math/rand"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// confFragments are the building blocks of confStrings, chosen to resemble attempts to break out of a value.
+var confFragments = []string{
+	"\n", "\r", "\r\n", "\t", " ", "\x00", "\x02", "\x7f", " ", "<", ">", "/", "=", " = ", "#", ",", "+", "*",
+	"User", "Network", "Chan", "Admin", "LoadModule", "shell", "<User evil>", "</User>", "</Network>", "Admin = true",
+	"a", "Z", "0", "9", "-", "_", ".", "@", "é",
+}
+
+// confString is a string that is likely to contain characters with a special meaning in znc.conf.
+type confString string
+
+// Generate implements quick.Generator.
+func (confString) Generate(rand *rand.Rand, size int) reflect.Value {
+	var b strings.Builder
+	for i := rand.Intn(size + 1); i >= 0; i-- {
+		b.WriteString(confFragments[rand.Intn(len(confFragments))])
+	}
+	return reflect.ValueOf(confString(b.String()))
+}
+
+// confStructure returns the keys and section delimiters of the given znc.conf in the order they appear in, i.e.
+// everything but values and section names.
+func confStructure(cfg string) []string {
+	var structure []string
+	for _, line := range strings.Split(cfg, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "</"):
+			structure = append(structure, line)
+		case strings.HasPrefix(line, "<"):
+			structure = append(structure, strings.SplitN(line, " ", 2)[0])
+		case strings.Contains(line, "="):
+			structure = append(structure, strings.TrimSpace(strings.SplitN(line, "=", 2)[0]))
+		default:
+			structure = append(structure, line)
+		}
+	}
+	return structure
+}
+
+func TestRenderConfigurationIsInjectionSafe(t *testing.T) {
+	newSpec := func() *zncv1.ZNCSpec {
+		return &zncv1.ZNCSpec{
+			Config: zncv1.ZNCSpecConfig{
+				Listeners: []zncv1.ZNCSpecConfigListener{
+					{Name: "irc", Port: 6667, Host: "0.0.0.0"},
+					{Name: "web", Port: 8080, URIPrefix: "/znc/"},
+				},
+				LoadModules:    []zncv1.ZNCSpecModule{{Name: "log", Args: "-sanitize"}},
+				Motd:           []string{"motd"},
+				TrustedProxies: []string{"10.0.0.0/8"},
+				Users: []zncv1.ZNCSpecConfigUser{
+					{
+						Name:            "johndoe",
+						Nick:            "johndoe",
+						AltNick:         "johndoe_",
+						Ident:           "john",
+						QuitMsg:         "bye",
+						RealName:        "John Doe",
+						TimestampFormat: "[%H:%M:%S]",
+						Pass:            makepassSecret,
+						LoadModules:     []zncv1.ZNCSpecModule{{Name: "log", Args: "-sanitize"}},
+						Networks: []zncv1.ZNCSpecConfigUserNetwork{
+							{
+								Name:        "libera",
+								Servers:     []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", Port: 6697, TLS: true}},
+								LoadModules: []zncv1.ZNCSpecModule{{Name: "autoreply", Args: "away"}},
+								Channels:    []zncv1.ZNCSpecConfigUserNetworkChan{{Name: "#znc", Key: "key", Modes: "+s"}},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	fields := map[string]func(spec *zncv1.ZNCSpec, value string){
+		"listener.host":      func(spec *zncv1.ZNCSpec, value string) { spec.Config.Listeners[0].Host = value },
+		"listener.uriPrefix": func(spec *zncv1.ZNCSpec, value string) { spec.Config.Listeners[1].URIPrefix = value },
+		"module.args":        func(spec *zncv1.ZNCSpec, value string) { spec.Config.LoadModules[0].Args = value },
+		"motd":               func(spec *zncv1.ZNCSpec, value string) { spec.Config.Motd[0] = value },
+		"statusPrefix":       func(spec *zncv1.ZNCSpec, value string) { spec.Config.StatusPrefix = value },
+		"trustedProxies":     func(spec *zncv1.ZNCSpec, value string) { spec.Config.TrustedProxies[0] = value },
+		"user.altNick":       func(spec *zncv1.ZNCSpec, value string) { spec.Config.Users[0].AltNick = value },
+		"user.ident":         func(spec *zncv1.ZNCSpec, value string) { spec.Config.Users[0].Ident = value },
+		"user.module.args":   func(spec *zncv1.ZNCSpec, value string) { spec.Config.Users[0].LoadModules[0].Args = value },
+		"user.name":          func(spec *zncv1.ZNCSpec, value string) { spec.Config.Users[0].Name = value },
+		"user.nick":          func(spec *zncv1.ZNCSpec, value string) { spec.Config.Users[0].Nick = value },
+		"user.quitMsg":       func(spec *zncv1.ZNCSpec, value string) { spec.Config.Users[0].QuitMsg = value },
+		"user.realName":      func(spec *zncv1.ZNCSpec, value string) { spec.Config.Users[0].RealName = value },
+		"user.pass":          func(spec *zncv1.ZNCSpec, value string) { spec.Config.Users[0].Pass = value },
+		"user.timestampFormat": func(spec *zncv1.ZNCSpec, value string) {
+			spec.Config.Users[0].TimestampFormat = value
+		},
+		"network.name": func(spec *zncv1.ZNCSpec, value string) { spec.Config.Users[0].Networks[0].Name = value },
+		"network.module.args": func(spec *zncv1.ZNCSpec, value string) {
+			spec.Config.Users[0].Networks[0].LoadModules[0].Args = value
+		},
+		"network.server": func(spec *zncv1.ZNCSpec, value string) {
+			spec.Config.Users[0].Networks[0].Servers[0] = zncv1.ParseServer(value)
+		},
+		"network.server.password": func(spec *zncv1.ZNCSpec, value string) {
+			spec.Config.Users[0].Networks[0].Servers[0].Password = value
+		},
+		"channel.name": func(spec *zncv1.ZNCSpec, value string) {
+			spec.Config.Users[0].Networks[0].Channels[0].Name = value
+		},
+		"channel.key": func(spec *zncv1.ZNCSpec, value string) {
+			spec.Config.Users[0].Networks[0].Channels[0].Key = value
+		},
+		"channel.modes": func(spec *zncv1.ZNCSpec, value string) {
+			spec.Config.Users[0].Networks[0].Channels[0].Modes = value
+		},
+	}
+
+	baseline, err := RenderConfiguration(newSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := confStructure(baseline)
+	for name, setField := range fields {
+		property := func(value confString) bool {
+			spec := newSpec()
+			setField(spec, string(value))
+			cfg, err := RenderConfiguration(spec)
+			if err != nil {
+				// Rejecting a value is always safe.
+				return true
+			}
+			return reflect.DeepEqual(confStructure(cfg), expected)
+		}
+		if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestRegistryIsInjectionSafe(t *testing.T) {
+	// Every registry file must be parsed back exactly the way ZNC does, i.e. no key or value can add settings.
+	property := func(settings map[confString]confString) bool {
+		r := registry{}
+		for key, value := range settings {
+			r[string(key)] = string(value)
+		}
+		parsed := registry{}
+		for _, line := range strings.Split(string(r.marshal()), "\n") {
+			if len(line) == 0 {
+				continue
+			}
+			fields := strings.Split(line, " ")
+			if len(fields) != 2 {
+				return false
+			}
+			key, err := url.QueryUnescape(fields[0])
+			if err != nil {
+				return false
+			}
+			value, err := url.QueryUnescape(fields[1])
+			if err != nil {
+				return false
+			}
+			parsed[key] = value
+		}
+		return reflect.DeepEqual(parsed, r)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateZNCSpecRejectsLineBreaks(t *testing.T) {
+	// These values are sent to IRC servers or clients line by line, by modules or by the preStop hook, rather than
+	// written to znc.conf.
+	fields := map[string]func(spec *zncv1.ZNCSpec, value string){
+		"restart.notice": func(spec *zncv1.ZNCSpec, value string) { spec.Restart.Notice = value },
+		"network.nickserv.identifyCmd": func(spec *zncv1.ZNCSpec, value string) {
+			spec.Config.Users[0].Networks[0].NickServ.IdentifyCmd = value
+		},
+		"network.perform": func(spec *zncv1.ZNCSpec, value string) {
+			spec.Config.Users[0].Networks[0].Perform[0].Command = value
+		},
+	}
+	for name, setField := range fields {
+		property := func(value confString) bool {
+			spec := &zncv1.ZNCSpec{
+				Config: zncv1.ZNCSpecConfig{
+					Users: []zncv1.ZNCSpecConfigUser{
+						{
+							Name: "johndoe",
+							Pass: makepassSecret,
+							Networks: []zncv1.ZNCSpecConfigUserNetwork{
+								{
+									Name:     "libera",
+									Servers:  []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat"}},
+									NickServ: &zncv1.ZNCSpecConfigUserNetworkNickServ{PasswordSecretRef: &zncv1.SecretKeyRef{Name: "nickserv", Key: "password"}},
+									Perform:  []zncv1.ZNCSpecConfigUserNetworkPerform{{Command: "JOIN #znc"}},
+								},
+							},
+						},
+					},
+				},
+			}
+			setField(spec, string(value))
+			if allErrs := zncv1.ValidateZNCSpec(spec, field.NewPath("spec")); len(allErrs) > 0 {
+				return true
+			}
+			return !strings.ContainsAny(string(value), "\r\n\x00")
+		}
+		if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
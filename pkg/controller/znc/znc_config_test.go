@@ -1,10 +1,16 @@
 package znc
 
 import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 	zncv1 "znc-operator/pkg/apis/znc/v1"
 )
 
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
 func TestRenderConfiguration(t *testing.T) {
 	_, err := RenderConfiguration(&zncv1.ZNCSpec{
 		Version: "1.7.5",
@@ -12,17 +18,19 @@ func TestRenderConfiguration(t *testing.T) {
 			AnonIPLimit:  0,
 			ConnectDelay: 0,
 			HideVersion:  false,
-			LoadModules: []string{
-				"webadmin",
-				"modperl",
-				"modpython",
+			LoadModules: []zncv1.ZNCSpecModule{
+				{Name: "webadmin"},
+				{Name: "modperl"},
+				{Name: "modpython"},
 			},
 			Users: []zncv1.ZNCSpecConfigUser{
 				{
+					Name:  "johndoe",
 					Admin: false,
-					LoadModules: []string{
-						"controlpanel",
-						"chansaver",
+					Pass:  makepassSecret,
+					LoadModules: []zncv1.ZNCSpecModule{
+						{Name: "controlpanel"},
+						{Name: "chansaver"},
 					},
 				},
 			},
@@ -32,3 +40,180 @@ func TestRenderConfiguration(t *testing.T) {
 		t.Error("rendering config caused an unexpected error", err)
 	}
 }
+
+func TestRenderConfigurationListeners(t *testing.T) {
+	deny := false
+	cfg, err := RenderConfiguration(&zncv1.ZNCSpec{
+		TLS: &zncv1.ZNCSpecTLS{SecretName: "znc-tls"},
+		Config: zncv1.ZNCSpecConfig{
+			Listeners: []zncv1.ZNCSpecConfigListener{
+				{Name: "ircs", AllowWeb: &deny, Port: 6697, IPv6: true, SSL: true},
+				{Name: "web", AllowIRC: &deny, Port: 8080, Host: "127.0.0.1", URIPrefix: "/znc/"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal("rendering config caused an unexpected error", err)
+	}
+	expected := []string{
+		"<Listener ircs>\n\tAllowIRC = true\n\tAllowWeb = false\n\tPort = 6697\n\tIPv4 = true\n\tIPv6 = true\n\tSSL = true\n</Listener>",
+		"<Listener web>\n\tAllowIRC = false\n\tAllowWeb = true\n\tHost = 127.0.0.1\n\tPort = 8080\n\tIPv4 = true\n\tIPv6 = false\n\tSSL = false\n\tURIPrefix = /znc/\n</Listener>",
+	}
+	for _, listener := range expected {
+		if !strings.Contains(cfg, listener) {
+			t.Errorf("expected rendered config to contain\n%s\ngot\n%s", listener, cfg)
+		}
+	}
+	if strings.Contains(cfg, "<Listener irc>") {
+		t.Error("expected default listeners to be replaced")
+	}
+}
+
+func TestRenderConfigurationDefaults(t *testing.T) {
+	spec := &zncv1.ZNCSpec{
+		Config: zncv1.ZNCSpecConfig{
+			Users: []zncv1.ZNCSpecConfigUser{
+				{
+					Name: "johndoe",
+					Pass: makepassSecret,
+					Networks: []zncv1.ZNCSpecConfigUserNetwork{
+						{Name: "libera", Servers: []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", TLS: true}}},
+					},
+				},
+			},
+		},
+	}
+	cfg, err := RenderConfiguration(spec)
+	if err != nil {
+		t.Fatal("rendering config caused an unexpected error", err)
+	}
+	if !strings.Contains(cfg, "IRCConnectEnabled = true") {
+		t.Errorf("expected networks to connect to IRC by default, got %s", cfg)
+	}
+
+	// Making the defaults explicit does not change the configuration ZNC receives.
+	spec.SetDefaults()
+	defaulted, err := RenderConfiguration(spec)
+	if err != nil {
+		t.Fatal("rendering config caused an unexpected error", err)
+	}
+	if cfg != defaulted {
+		t.Errorf("expected defaulting not to change the configuration, got\n%s\ninstead of\n%s", defaulted, cfg)
+	}
+}
+
+// goldenSpecs returns the specs whose rendered configurations are stored in testdata.
+func goldenSpecs() map[string]*zncv1.ZNCSpec {
+	allow, deny := true, false
+	return map[string]*zncv1.ZNCSpec{
+		"minimal": {},
+		"full": {
+			Version: "1.8.2",
+			TLS:     &zncv1.ZNCSpecTLS{SecretName: "znc-tls"},
+			Config: zncv1.ZNCSpecConfig{
+				AnonIPLimit:  10,
+				ConnectDelay: 5,
+				HideVersion:  true,
+				Listeners: []zncv1.ZNCSpecConfigListener{
+					{Name: "ircs", AllowIRC: &allow, AllowWeb: &deny, Port: 6697, Host: "0.0.0.0", IPv4: &allow, IPv6: true, SSL: true},
+					{Name: "web", AllowIRC: &deny, Port: 8080, IPv4: &deny, IPv6: true, URIPrefix: "/znc/"},
+				},
+				LoadModules:    []zncv1.ZNCSpecModule{{Name: "webadmin"}, {Name: "log", Args: "-sanitize"}},
+				MaxBufferSize:  1000,
+				Motd:           []string{"Welcome to ZNC", "Be nice"},
+				ServerThrottle: 30,
+				StatusPrefix:   "?",
+				TrustedProxies: []string{"10.0.0.0/8", "fc00::/7"},
+				Users: []zncv1.ZNCSpecConfigUser{
+					{
+						Name:                 "johndoe",
+						Admin:                true,
+						AltNick:              "johndoe_",
+						AppendTimestamp:      true,
+						AutoClearChanBuffer:  true,
+						AutoClearQueryBuffer: true,
+						Buffer:               500,
+						ChanBufferSize:       400,
+						ChanModes:            "+nt",
+						ClientEncoding:       "ISO-8859-1",
+						Ident:                "john",
+						JoinTries:            3,
+						LoadModules:          []zncv1.ZNCSpecModule{{Name: "chansaver"}, {Name: "controlpanel"}},
+						MaxJoins:             5,
+						MaxQueryBuffers:      20,
+						MultiClients:         true,
+						Nick:                 "johndoe",
+						NoTrafficTimeout:     180,
+						PrependTimestamp:     true,
+						QueryBufferSize:      300,
+						QuitMsg:              "bye",
+						RealName:             "John Doe",
+						StatusPrefix:         "!",
+						TimestampFormat:      "[%H:%M:%S]",
+						Timezone:             "Europe/Berlin",
+						Pass:                 makepassSecret,
+						Networks: []zncv1.ZNCSpecConfigUserNetwork{
+							{
+								Name:              "libera",
+								AltNick:           "jdoe_",
+								Encoding:          "^UTF-8",
+								Ident:             "jdoe",
+								IRCConnectEnabled: &deny,
+								JoinDelay:         2,
+								LoadModules:       []zncv1.ZNCSpecModule{{Name: "simple_away", Args: "-timer 30"}, {Name: "route_replies"}},
+								Nick:              "jdoe",
+								QuitMsg:           "see you",
+								RealName:          "J. Doe",
+								Servers:           []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", Port: 6697, TLS: true}, {Host: "irc.eu.libera.chat", Port: 6667, Password: "secret"}},
+								Channels: []zncv1.ZNCSpecConfigUserNetworkChan{
+									{Name: "#znc", AutoClearChanBuffer: true, Buffer: 100, Detached: true, Disabled: true, Key: "letmein", Modes: "+s"},
+									{Name: "#kubernetes"},
+								},
+							},
+							{
+								Name:    "oftc",
+								Servers: []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.oftc.net", Port: 6697, TLS: true}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestRenderConfigurationGolden(t *testing.T) {
+	for name, spec := range goldenSpecs() {
+		cfg, err := RenderConfiguration(spec)
+		if err != nil {
+			t.Errorf("%s: rendering config caused an unexpected error: %v", name, err)
+			continue
+		}
+		golden := filepath.Join("testdata", name+".conf")
+		if *update {
+			if err := ioutil.WriteFile(golden, []byte(cfg), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg != string(expected) {
+			t.Errorf("%s: rendered config does not match %s, got\n%s", name, golden, cfg)
+		}
+	}
+}
+
+func TestRenderConfigurationRejectsLineBreaks(t *testing.T) {
+	_, err := RenderConfiguration(&zncv1.ZNCSpec{
+		Config: zncv1.ZNCSpecConfig{
+			Users: []zncv1.ZNCSpecConfigUser{
+				{Name: "johndoe", QuitMsg: "bye\nLoadModule = shell"},
+			},
+		},
+	})
+	if err == nil {
+		t.Error("expected values spanning multiple lines to be rejected")
+	}
+}
@@ -6,17 +6,25 @@ import (
 	"github.com/mitchellh/hashstructure"
 	"reflect"
 	"strconv"
+	"sync"
 
+	"github.com/go-logr/logr"
 	zncv1 "znc-operator/pkg/apis/znc/v1"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -34,7 +42,14 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileZNC{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileZNC{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetEventRecorderFor("znc-controller"),
+		dial:     dialZNC,
+		// The buffer keeps the results of queries from being dropped while the controller is busy.
+		networkEvents: make(chan event.GenericEvent, 1024),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -51,8 +66,43 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
-	// Watch for changes to secondary resource Pods and requeue the owner ZNC
-	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{
+	// Watch for changes to secondary resource ConfigMaps and requeue the owner ZNC
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &zncv1.ZNC{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource Secrets and requeue the owner ZNC
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &zncv1.ZNC{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to Secrets referenced by ZNC instances and requeue the referencing ZNC instances
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: &secretMapper{client: mgr.GetClient()},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource Services and requeue the owner ZNC
+	err = c.Watch(&source.Kind{Type: &corev1.Service{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &zncv1.ZNC{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource PersistentVolumeClaims and requeue the owner ZNC
+	err = c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
 		OwnerType:    &zncv1.ZNC{},
 	})
@@ -60,6 +110,58 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to secondary resource Ingresses and requeue the owner ZNC
+	err = c.Watch(&source.Kind{Type: &networkingv1beta1.Ingress{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &zncv1.ZNC{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource HTTPRoutes and requeue the owner ZNC, if the Gateway API has been installed
+	// before the operator started
+	if _, err := mgr.GetRESTMapper().RESTMapping(httpRouteGVK.GroupKind(), httpRouteGVK.Version); err == nil {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		err = c.Watch(&source.Kind{Type: route}, &handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    &zncv1.ZNC{},
+		})
+		if err != nil {
+			return err
+		}
+	} else if !meta.IsNoMatchError(err) {
+		return err
+	}
+
+	// Watch for changes to secondary resources StatefulSets and Deployments and requeue the owner ZNC
+	for _, workload := range []runtime.Object{&appsv1.StatefulSet{}, &appsv1.Deployment{}} {
+		err = c.Watch(&source.Kind{Type: workload}, &handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    &zncv1.ZNC{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Watch for changes to ZNC pods, which are owned by StatefulSets or ReplicaSets, and requeue the ZNC they belong to
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(podToRequests),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for completed queries of the networks of ZNC instances and requeue them, so that the results are recorded
+	if r, ok := r.(*ReconcileZNC); ok && r.networkEvents != nil {
+		err = c.Watch(&source.Channel{Source: r.networkEvents}, &handler.EnqueueRequestForObject{})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -72,6 +174,19 @@ type ReconcileZNC struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
+	// recorder emits events on ZNC instances, which tell users without access to the operator logs what the operator
+	// did and why. If nil, no events are emitted.
+	recorder record.EventRecorder
+	// dial connects to running ZNC instances in order to apply configuration changes. If nil, ZNC is restarted
+	// instead.
+	dial zncDialer
+	// networkQueries tracks the queries of the networks of each ZNC instance, which run in the background, so that ZNC
+	// is neither connected to on every reconciliation nor blocks it.
+	networkQueries   map[types.NamespacedName]*networkQuery
+	networkQueriesMu sync.Mutex
+	// networkEvents triggers the reconciliation of ZNC instances whose networks have been queried. If nil, they are
+	// reconciled after networkStatusTimeout.
+	networkEvents chan event.GenericEvent
 }
 
 // Reconcile reads that state of the cluster for a ZNC object and makes changes based on the state read
@@ -92,108 +207,289 @@ func (r *ReconcileZNC) Reconcile(request reconcile.Request) (reconcile.Result, e
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			r.forgetNetworkQuery(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	var cfgHash uint64
+	status := instance.Status.DeepCopy()
+	status.ObservedGeneration = instance.Generation
+	status.Phase = ""
+
+	result, err := r.reconcileResources(reqLogger, instance, status)
+	updateReadiness(status)
+	if statusErr := r.updateStatus(instance, status); statusErr != nil {
+		reqLogger.Error(statusErr, "Failed to update ZNC status")
+		if err == nil {
+			err = statusErr
+		}
+	}
+	return result, err
+}
+
+// reconcileResources creates or updates all resources owned by the given ZNC instance and records the observed
+// state in status.
+func (r *ReconcileZNC) reconcileResources(reqLogger logr.Logger, instance *zncv1.ZNC, status *zncv1.ZNCStatus) (reconcile.Result, error) {
+	// Invalid specs are not retried, as only changing the spec can fix them.
+	if allErrs := instance.Validate(); len(allErrs) > 0 {
+		reqLogger.Info("Invalid ZNC spec", "Errors", allErrs.ToAggregate().Error())
+		r.recordEvent(instance, corev1.EventTypeWarning, "InvalidSpec", "Invalid spec: %v", allErrs.ToAggregate())
+		status.SetCondition(zncv1.ZNCConditionConfigRendered, corev1.ConditionFalse, "InvalidSpec", allErrs.ToAggregate().Error())
+		return reconcile.Result{}, nil
+	}
+
+	var cfgHash, operatorPassword, zncConf string
+	var spec *zncv1.ZNCSpec
+	var pending *pendingUpdate
 	{
-		configMap, err := newConfigMapForCR(instance)
+		passes, err := r.renderedPasses(instance)
 		if err != nil {
 			return reconcile.Result{}, err
 		}
-		if err := controllerutil.SetControllerReference(instance, configMap, r.scheme); err != nil {
+		spec, err = r.resolveSpec(instance, passes)
+		if err != nil {
+			status.SetCondition(zncv1.ZNCConditionConfigRendered, corev1.ConditionFalse, "SecretResolutionFailed", err.Error())
+			r.recordEvent(instance, corev1.EventTypeWarning, "SecretResolutionFailed", "Failed to resolve Secrets: %v", err)
 			return reconcile.Result{}, err
 		}
-		found := &corev1.ConfigMap{}
-		err = r.client.Get(context.TODO(), types.NamespacedName{Name: configMap.Name, Namespace: configMap.Namespace}, found)
-		if err == nil {
-			if !reflect.DeepEqual(configMap.Data, found.Data) {
-				reqLogger.Info("Updating ZNC ConfigMap")
-				err = r.client.Update(context.TODO(), configMap)
-				if err != nil {
-					return reconcile.Result{}, err
-				}
-				if cfgHash, err = hashstructure.Hash(configMap.Data, nil); err != nil {
-					return reconcile.Result{}, err
-				}
-			}
-		} else {
-			if errors.IsNotFound(err) {
-				reqLogger.Info("Creating a new ConfigMap", "ConfigMap.Namespace", configMap.Namespace, "ConfigMap.Name", configMap.Name)
-				if err = r.client.Create(context.TODO(), configMap); err != nil {
-					return reconcile.Result{}, err
-				}
-
-			}
+		if operatorPassword, err = r.reconcileOperatorSecret(reqLogger, instance); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := addOperatorUser(spec, operatorPassword, passes[zncv1.OperatorUserName]); err != nil {
+			return reconcile.Result{}, err
+		}
+		configMap, renderedConf, err := newConfigMapForCR(instance, spec)
+		if err != nil {
+			status.SetCondition(zncv1.ZNCConditionConfigRendered, corev1.ConditionFalse, "RenderFailed", err.Error())
+			r.recordEvent(instance, corev1.EventTypeWarning, "RenderFailed", "Failed to render the ZNC configuration: %v", err)
+			return reconcile.Result{}, err
 		}
-		if cfgHash, err = hashstructure.Hash(found.Data, nil); err != nil {
+		if cfgHash, pending, err = r.reconcileConfigMap(reqLogger, instance, configMap, renderedConf, operatorPassword); err != nil {
 			return reconcile.Result{}, err
 		}
+		zncConf = renderedConf
+		status.SetCondition(zncv1.ZNCConditionConfigRendered, corev1.ConditionTrue, "Rendered", "The ZNC configuration has been rendered")
 	}
 
-	{
-		cfgHashAsString := strconv.FormatUint(cfgHash, 10)
-		pod := newPodForCR(instance, cfgHashAsString)
-		if err := controllerutil.SetControllerReference(instance, pod, r.scheme); err != nil {
+	if err := r.reconcileCertificate(reqLogger, instance); err != nil {
+		return reconcile.Result{}, err
+	}
+	files, err := r.moduleDataFilesForCR(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	pem, err := r.tlsPEMForCR(reqLogger, instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if pem != nil {
+		files["znc.pem"] = pem
+	}
+	files[configFilePath] = []byte(zncConf)
+	secret, err := r.reconcileSecret(reqLogger, instance, files)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	// Changes are only applied to the running pod once they have been persisted, so that they are never repeated.
+	if pending != nil {
+		if cfgHash, err = r.applyLiveUpdate(reqLogger, instance, pending, cfgHash, operatorPassword); err != nil {
 			return reconcile.Result{}, err
 		}
-		found := &corev1.Pod{}
-		err = r.client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, found)
-		if err == nil {
-			annotations := found.GetAnnotations()
-			if annotations == nil || len(annotations) == 0 || annotations["config.znc.in/checksum"] != cfgHashAsString {
-				// If no annotations are present or if the checksum doesn't match, we need to delete the Pod and re-create it.
-				reqLogger.Info(fmt.Sprintf("Configuration updated (old checksum: %s, new checksum: %s, deleting ZNC pod", annotations["config.znc.in/checksum"], cfgHashAsString))
-				return reconcile.Result{}, r.client.Delete(context.TODO(), found)
-			}
-		} else {
-			if errors.IsNotFound(err) {
-				reqLogger.Info("Creating a new Pod", "Pod.Namespace", pod.Namespace, "Pod.Name", pod.Name)
-				err = r.client.Create(context.TODO(), pod)
-				if err != nil {
-					return reconcile.Result{}, err
-				}
-			} else {
-				return reconcile.Result{}, err
-			}
+	}
+
+	if err := r.reconcileStorage(reqLogger, instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err := r.reconcileService(reqLogger, instance, status); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.reconcileHeadlessService(reqLogger, instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err := r.reconcileIngress(reqLogger, instance); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.reconcileHTTPRoute(reqLogger, instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	status.ConfigChecksum = cfgHash
+	workloadResult, err := r.reconcileWorkload(reqLogger, instance, spec, cfgHash, secret, operatorPassword)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.deleteLegacyPod(reqLogger, instance); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.observePod(reqLogger, instance, cfgHash, status); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	result := r.observeNetworks(reqLogger, instance, cfgHash, operatorPassword, status)
+	if workloadResult.RequeueAfter > 0 && (result.RequeueAfter == 0 || workloadResult.RequeueAfter < result.RequeueAfter) {
+		result.RequeueAfter = workloadResult.RequeueAfter
+	}
+	return result, nil
+}
+
+// reconcileConfigMap creates or updates the given ConfigMap holding the redacted configuration of the given ZNC
+// instance and returns the checksum of the complete configuration zncConf the ZNC pod must run, which is recorded on
+// the ConfigMap. Changes that can be applied to the running pod keep the checksum, so that the pod is not restarted;
+// they are returned as pending update, which must be applied once the complete configuration has been persisted.
+func (r *ReconcileZNC) reconcileConfigMap(reqLogger logr.Logger, instance *zncv1.ZNC, configMap *corev1.ConfigMap, zncConf string, operatorPassword string) (string, *pendingUpdate, error) {
+	if err := controllerutil.SetControllerReference(instance, configMap, r.scheme); err != nil {
+		return "", nil, err
+	}
+	hash, err := hashstructure.Hash(map[string]string{"znc.conf": zncConf}, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	cfgHash := strconv.FormatUint(hash, 10)
+
+	found := &corev1.ConfigMap{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: configMap.Name, Namespace: configMap.Namespace}, found)
+	if errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new ConfigMap", "ConfigMap.Namespace", configMap.Namespace, "ConfigMap.Name", configMap.Name)
+		configMap.Annotations = map[string]string{checksumAnnotation: cfgHash}
+		if err := r.client.Create(context.TODO(), configMap); err != nil {
+			return "", nil, err
 		}
+		r.recordEvent(instance, corev1.EventTypeNormal, "ConfigMapCreated", "Created ConfigMap %s with configuration checksum %s", configMap.Name, cfgHash)
+		return cfgHash, nil, nil
+	} else if err != nil {
+		return "", nil, err
 	}
 
-	return reconcile.Result{}, nil
+	runningHash, ok := found.Annotations[checksumAnnotation]
+	if !ok {
+		// ConfigMaps created by previous versions of the operator carry no checksum, which was derived from their data.
+		hash, err := hashstructure.Hash(found.Data, nil)
+		if err != nil {
+			return "", nil, err
+		}
+		runningHash = strconv.FormatUint(hash, 10)
+	}
+	appliedConf, err := r.appliedConfiguration(instance)
+	if err != nil {
+		return "", nil, err
+	}
+	_, interrupted := found.Annotations[pendingChecksumAnnotation]
+	if appliedConf == zncConf && reflect.DeepEqual(configMap.Data, found.Data) && ok && !interrupted {
+		return runningHash, nil, nil
+	}
+	if found.Annotations == nil {
+		found.Annotations = map[string]string{}
+	}
+	var commands []liveCommand
+	if interrupted {
+		// The previous update may have been applied partially, so it is not repeated.
+		reqLogger.Info("An interrupted live configuration update requires a restart of ZNC")
+		r.recordEvent(instance, corev1.EventTypeNormal, "RestartRequired", "An interrupted live configuration update requires a restart of ZNC")
+		delete(found.Annotations, pendingChecksumAnnotation)
+		runningHash = cfgHash
+	} else if appliedConf != zncConf {
+		if commands, err = r.liveUpdateCommands(instance, appliedConf, zncConf, operatorPassword); err != nil {
+			reqLogger.Info("Configuration changes require a restart of ZNC", "Reason", err.Error())
+			r.recordEvent(instance, corev1.EventTypeNormal, "RestartRequired", "Configuration changes require a restart of ZNC: %v", err)
+			runningHash = cfgHash
+		} else if len(commands) > 0 {
+			found.Annotations[pendingChecksumAnnotation] = cfgHash
+		}
+	}
+	reqLogger.Info("Updating ZNC ConfigMap")
+	found.Annotations[checksumAnnotation] = runningHash
+	found.Data = configMap.Data
+	if err := r.client.Update(context.TODO(), found); err != nil {
+		return "", nil, err
+	}
+	r.recordEvent(instance, corev1.EventTypeNormal, "ConfigMapUpdated", "Updated ConfigMap %s (configuration checksum: %s, running checksum: %s)", found.Name, cfgHash, runningHash)
+	if len(commands) == 0 {
+		return runningHash, nil, nil
+	}
+	return runningHash, &pendingUpdate{configMap: found, commands: commands}, nil
+}
+
+// appliedConfiguration returns the complete configuration last rendered for the given ZNC instance, which is stored in
+// the generated Secret, or an empty string if none has been rendered yet. ConfigMaps created by previous versions of
+// the operator hold the complete configuration themselves.
+func (r *ReconcileZNC) appliedConfiguration(instance *zncv1.ZNC) (string, error) {
+	name := types.NamespacedName{Name: generatedSecretNameForCR(instance), Namespace: instance.Namespace}
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), name, secret); err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	if zncConf, ok := secret.Data[secretKeyForPath(configFilePath)]; ok {
+		return string(zncConf), nil
+	}
+	name.Name = instance.Name
+	configMap := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), name, configMap); err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	return configMap.Data["znc.conf"], nil
+}
+
+// updateStatus writes the given status to the status subresource of the ZNC instance, if it has changed.
+func (r *ReconcileZNC) updateStatus(instance *zncv1.ZNC, status *zncv1.ZNCStatus) error {
+	if reflect.DeepEqual(&instance.Status, status) {
+		return nil
+	}
+	instance.Status = *status
+	return r.client.Status().Update(context.TODO(), instance)
 }
 
-func newConfigMapForCR(cr *zncv1.ZNC) (configMap *corev1.ConfigMap, err error) {
-	zncConf, err := RenderConfiguration(&cr.Spec)
+// recordEvent emits an event of the given type on the given ZNC instance.
+func (r *ReconcileZNC) recordEvent(instance *zncv1.ZNC, eventType string, reason string, messageFmt string, args ...interface{}) {
+	if r.recorder == nil {
+		return
+	}
+	r.recorder.Eventf(instance, eventType, reason, messageFmt, args...)
+}
+
+// newConfigMapForCR returns a ConfigMap containing the redacted ZNC configuration rendered from the given (resolved)
+// spec, together with the complete configuration, which contains password hashes and is only stored in the generated
+// Secret.
+func newConfigMapForCR(cr *zncv1.ZNC, spec *zncv1.ZNCSpec) (configMap *corev1.ConfigMap, zncConf string, err error) {
+	zncConf, err = RenderConfiguration(spec)
+	if err != nil {
+		return nil, "", err
+	}
+	redacted, err := redactConfiguration(zncConf)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	configMap = &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cr.Name,
 			Namespace: cr.Namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/instance":   cr.Name,
-				"app.kubernetes.io/managed-by": "znc-operator",
-				"app.kubernetes.io/name":       "znc",
-			},
+			Labels:    labelsForCR(cr),
 		},
 		Data: map[string]string{
-			"znc.conf": zncConf,
+			"znc.conf": redacted,
 		},
 	}
-	return configMap, nil
+	return configMap, zncConf, nil
 }
 
-// newPodForCR returns a busybox pod with the same name/namespace as the cr
-func newPodForCR(cr *zncv1.ZNC, cfgHash string) *corev1.Pod {
-	labels := map[string]string{
+// labelsForCR returns the labels attached to all resources managed on behalf of the given ZNC instance.
+func labelsForCR(cr *zncv1.ZNC) map[string]string {
+	return map[string]string{
 		"app.kubernetes.io/instance":   cr.Name,
 		"app.kubernetes.io/managed-by": "znc-operator",
 		"app.kubernetes.io/name":       "znc",
 	}
+}
+
+// newPodTemplateForCR returns the template of the ZNC pod for the given cr and its resolved spec.
+// The template carries the checksums of the configuration and of the sensitive files, so that changes to either of
+// them trigger a rollout. The sensitive files, including the configuration, are installed into the data directory by
+// the init container.
+func newPodTemplateForCR(cr *zncv1.ZNC, spec *zncv1.ZNCSpec, cfgHash string, secret *generatedSecret) corev1.PodTemplateSpec {
+	labels := labelsForCR(cr)
 	args := []string{
 		"--foreground",
 	}
@@ -205,13 +501,14 @@ func newPodForCR(cr *zncv1.ZNC, cfgHash string) *corev1.Pod {
 	runAsNonRoot := true
 	var userID int64 = 65534
 	var groupID int64 = 65534
-	return &corev1.Pod{
+	var secretMode int32 = 0440
+	livenessProbe, readinessProbe := probesForCR(spec)
+	return corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      cr.Name,
-			Namespace: cr.Namespace,
-			Labels:    labels,
+			Labels: labels,
 			Annotations: map[string]string{
-				"config.znc.in/checksum": cfgHash,
+				checksumAnnotation:       cfgHash,
+				secretChecksumAnnotation: secret.checksum,
 			},
 		},
 		Spec: corev1.PodSpec{
@@ -222,7 +519,8 @@ func newPodForCR(cr *zncv1.ZNC, cfgHash string) *corev1.Pod {
 						"-c",
 					},
 					Args: []string{
-						"mkdir -p /znc-data/configs && cp /znc-config-src/znc.conf /znc-data/configs/znc.conf",
+						"set -e; cd /znc-secret-src; for f in $(find -L . -path './..*' -prune -o -type f -print); do" +
+							" install -D -m 600 \"$f\" \"/znc-data/$f\"; done",
 					},
 					Image:           "docker.io/alpine:3.11.3",
 					ImagePullPolicy: corev1.PullIfNotPresent,
@@ -241,8 +539,8 @@ func newPodForCR(cr *zncv1.ZNC, cfgHash string) *corev1.Pod {
 					},
 					VolumeMounts: []corev1.VolumeMount{
 						{
-							Name:      "znc-config-src",
-							MountPath: "/znc-config-src",
+							Name:      "znc-secret-src",
+							MountPath: "/znc-secret-src",
 							ReadOnly:  true,
 						},
 						{
@@ -259,18 +557,11 @@ func newPodForCR(cr *zncv1.ZNC, cfgHash string) *corev1.Pod {
 					Image:           fmt.Sprintf("docker.io/library/znc:%s", cr.Spec.GetVersion()),
 					ImagePullPolicy: corev1.PullIfNotPresent,
 					Name:            "znc",
-					Ports: []corev1.ContainerPort{
-						{
-							Name:          "irc",
-							ContainerPort: 6667,
-							Protocol:      corev1.ProtocolTCP,
-						},
-						{
-							Name:          "web",
-							ContainerPort: 8080,
-							Protocol:      corev1.ProtocolTCP,
-						},
-					},
+					Ports:           containerPortsForCR(cr),
+					Env:             restartEnvForCR(cr),
+					LivenessProbe:   livenessProbe,
+					ReadinessProbe:  readinessProbe,
+					Lifecycle:       lifecycleForCR(cr),
 					SecurityContext: &corev1.SecurityContext{
 						AllowPrivilegeEscalation: &allowPrivilegeEscalation,
 						ReadOnlyRootFilesystem:   &readOnlyRootFileSystem,
@@ -292,6 +583,7 @@ func newPodForCR(cr *zncv1.ZNC, cfgHash string) *corev1.Pod {
 					},
 				},
 			},
+			TerminationGracePeriodSeconds: terminationGracePeriodForCR(cr),
 			SecurityContext: &corev1.PodSecurityContext{
 				RunAsUser:    &userID,
 				RunAsGroup:   &groupID,
@@ -300,20 +592,18 @@ func newPodForCR(cr *zncv1.ZNC, cfgHash string) *corev1.Pod {
 			},
 			Volumes: []corev1.Volume{
 				{
-					Name: "znc-config-src",
+					Name: "znc-secret-src",
 					VolumeSource: corev1.VolumeSource{
-						ConfigMap: &corev1.ConfigMapVolumeSource{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: cr.Name,
-							},
+						Secret: &corev1.SecretVolumeSource{
+							SecretName:  generatedSecretNameForCR(cr),
+							Items:       secret.items,
+							DefaultMode: &secretMode,
 						},
 					},
 				},
 				{
-					Name: "znc-data",
-					VolumeSource: corev1.VolumeSource{
-						EmptyDir: &corev1.EmptyDirVolumeSource{},
-					},
+					Name:         "znc-data",
+					VolumeSource: dataVolumeSourceForCR(cr),
 				},
 			},
 		},
@@ -0,0 +1,268 @@
+package znc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestZNC() *zncv1.ZNC {
+	return &zncv1.ZNC{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-znc", Namespace: "default", UID: "0c7e0bd8-0f3c-4d4a-a3a8-6f3d0e1b5d0e"},
+		Spec: zncv1.ZNCSpec{
+			Config: zncv1.ZNCSpecConfig{
+				LoadModules: []zncv1.ZNCSpecModule{{Name: "webadmin"}},
+				Users: []zncv1.ZNCSpecConfigUser{
+					{Name: "johndoe", Nick: "johndoe", AltNick: "johndoe_", Pass: makepassSecret},
+				},
+			},
+		},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+
+	name := types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}
+	if err := r.client.Get(context.TODO(), name, &corev1.ConfigMap{}); err != nil {
+		t.Error("expected ConfigMap to be created", err)
+	}
+	if err := r.client.Get(context.TODO(), name, &corev1.Service{}); err != nil {
+		t.Error("expected Service to be created", err)
+	}
+	statefulSet := &appsv1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), name, statefulSet); err != nil {
+		t.Fatal("expected StatefulSet to be created", err)
+	}
+	checksum := statefulSet.Spec.Template.Annotations[checksumAnnotation]
+	if len(checksum) == 0 {
+		t.Error("expected pod template to carry the configuration checksum")
+	}
+	headlessName := types.NamespacedName{Name: headlessServiceNameForCR(cr), Namespace: cr.Namespace}
+	headless := &corev1.Service{}
+	if err := r.client.Get(context.TODO(), headlessName, headless); err != nil {
+		t.Error("expected headless Service to be created", err)
+	}
+	if headless.Spec.ClusterIP != corev1.ClusterIPNone || statefulSet.Spec.ServiceName != headless.Name {
+		t.Errorf("expected the StatefulSet to be governed by the headless Service, got %s and %+v", statefulSet.Spec.ServiceName, headless.Spec)
+	}
+
+	instance := &zncv1.ZNC{}
+	if err := r.client.Get(context.TODO(), name, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.ConfigChecksum != checksum || instance.Status.Phase != zncv1.ZNCPhasePending || instance.Status.ServiceName != cr.Name {
+		t.Errorf("unexpected status %+v", instance.Status)
+	}
+
+	// Reconciling an unchanged instance leaves the StatefulSet untouched.
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	unchanged := &appsv1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), name, unchanged); err != nil {
+		t.Fatal(err)
+	}
+	if unchanged.ResourceVersion != statefulSet.ResourceVersion {
+		t.Error("expected StatefulSet not to be updated")
+	}
+
+	// Switching to a Deployment replaces the StatefulSet.
+	instance.Spec.Workload = zncv1.ZNCWorkloadKindDeployment
+	if err := r.client.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if err := r.client.Get(context.TODO(), name, &appsv1.Deployment{}); err != nil {
+		t.Error("expected Deployment to be created", err)
+	}
+	if err := r.client.Get(context.TODO(), name, &appsv1.StatefulSet{}); !errors.IsNotFound(err) {
+		t.Error("expected StatefulSet to be deleted", err)
+	}
+	if err := r.client.Get(context.TODO(), headlessName, &corev1.Service{}); !errors.IsNotFound(err) {
+		t.Error("expected headless Service to be deleted", err)
+	}
+}
+
+func TestReconcileRecreatesStatefulSetGovernedByService(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	statefulSet := &appsv1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	// StatefulSets created by previous versions of the operator are governed by the regular Service.
+	statefulSet.Spec.ServiceName = cr.Name
+	if err := r.client.Update(context.TODO(), statefulSet); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, &appsv1.StatefulSet{}); !errors.IsNotFound(err) {
+		t.Error("expected StatefulSet to be deleted", err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	statefulSet = &appsv1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal("expected StatefulSet to be recreated", err)
+	}
+	if statefulSet.Spec.ServiceName != headlessServiceNameForCR(cr) {
+		t.Errorf("expected the StatefulSet to be governed by the headless Service, got %s", statefulSet.Spec.ServiceName)
+	}
+}
+
+func TestReconcileMigratesLegacyPod(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	isController := true
+	legacyPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      cr.Name,
+		Namespace: cr.Namespace,
+		Labels:    labelsForCR(cr),
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: zncv1.SchemeGroupVersion.String(),
+			Kind:       "ZNC",
+			Name:       cr.Name,
+			UID:        cr.UID,
+			Controller: &isController,
+		}},
+	}}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr, legacyPod), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, &corev1.Pod{}); !errors.IsNotFound(err) {
+		t.Error("expected legacy pod to be deleted", err)
+	}
+}
+
+func TestReconcileRejectsInvalidSpec(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	cr.Spec.Config.Users = append(cr.Spec.Config.Users, cr.Spec.Config.Users[0])
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s, recorder: recorder}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling an invalid spec should not be retried", err)
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, &appsv1.StatefulSet{}); !errors.IsNotFound(err) {
+		t.Error("expected no StatefulSet to be created", err)
+	}
+	instance := &zncv1.ZNC{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	condition := instance.Status.GetCondition(zncv1.ZNCConditionConfigRendered)
+	if condition == nil || condition.Reason != "InvalidSpec" || !strings.Contains(condition.Message, "spec.config.users[1].name") {
+		t.Errorf("unexpected condition %+v", condition)
+	}
+	if instance.Status.Phase != zncv1.ZNCPhaseFailed {
+		t.Errorf("expected phase %s, got %s", zncv1.ZNCPhaseFailed, instance.Status.Phase)
+	}
+	if events := recordedEvents(recorder); len(events) != 1 || !strings.HasPrefix(events[0], "Warning InvalidSpec Invalid spec: ") {
+		t.Errorf("expected a single InvalidSpec warning, got %v", events)
+	}
+}
+
+func TestReconcileRecordsEvents(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	cr.Spec.ConfigUpdateStrategy = zncv1.ZNCConfigUpdateStrategyRestart
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s, recorder: recorder}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	instance := &zncv1.ZNC{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	oldHash := instance.Status.ConfigChecksum
+	expected := []string{
+		"Normal ConfigMapCreated Created ConfigMap example-znc with configuration checksum " + oldHash,
+		"Normal Created Created StatefulSet example-znc with configuration checksum " + oldHash,
+	}
+	if events := recordedEvents(recorder); !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+
+	instance.Spec.Config.Users[0].Nick = "jdoe"
+	if err := r.client.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	newHash := instance.Status.ConfigChecksum
+	expected = []string{
+		"Normal RestartRequired Configuration changes require a restart of ZNC: live configuration updates are disabled",
+		"Normal ConfigMapUpdated Updated ConfigMap example-znc (configuration checksum: " + newHash + ", running checksum: " + newHash + ")",
+		"Normal RestartingPod Replacing the ZNC pod due to a configuration change (old checksum: " + oldHash + ", new checksum: " + newHash + ")",
+	}
+	if events := recordedEvents(recorder); !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+
+	instance.Spec.Config.Users[0].Networks = []zncv1.ZNCSpecConfigUserNetwork{{Name: "libera", Nick: "john doe"}}
+	if err := r.client.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling an invalid spec should not be retried", err)
+	}
+	if events := recordedEvents(recorder); len(events) != 1 || !strings.HasPrefix(events[0], "Warning InvalidSpec ") {
+		t.Errorf("expected a single InvalidSpec warning, got %v", events)
+	}
+}
+
+// recordedEvents returns the events recorded so far.
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
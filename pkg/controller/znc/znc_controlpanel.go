@@ -0,0 +1,80 @@
+package znc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+	"znc-operator/pkg/irc"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// zncSession is a connection to a running ZNC instance, on which the operator user is logged in.
+type zncSession interface {
+	// Command sends a command to the given module, e.g. "*controlpanel", and returns the replies of the module.
+	Command(ctx context.Context, module string, command string) ([]string, error)
+	// Close closes the connection.
+	Close() error
+}
+
+// zncDialer connects to the IRC listener of a ZNC instance at the given address and logs in.
+type zncDialer func(ctx context.Context, address string, options irc.Options) (zncSession, error)
+
+// dialZNC implements zncDialer using package irc.
+func dialZNC(ctx context.Context, address string, options irc.Options) (zncSession, error) {
+	return irc.Dial(ctx, address, options)
+}
+
+// dialCR connects to the running pod of the given ZNC instance, which must run the configuration with the given
+// checksum, and logs in as the operator user.
+func (r *ReconcileZNC) dialCR(ctx context.Context, instance *zncv1.ZNC, cfgHash string, operatorPassword string) (zncSession, error) {
+	if r.dial == nil {
+		return nil, fmt.Errorf("connecting to ZNC is disabled")
+	}
+	address, useTLS, err := r.addressForCR(instance, cfgHash)
+	if err != nil {
+		return nil, err
+	}
+	options := irc.Options{User: zncv1.OperatorUserName, Password: operatorPassword}
+	if useTLS {
+		// The certificates of listeners are issued for the names clients use to reach ZNC, not for the pod address.
+		options.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	session, err := r.dial(ctx, address, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ZNC: %v", err)
+	}
+	return session, nil
+}
+
+// addressForCR returns the address of the first IRC listener of the running pod of the given ZNC instance and
+// whether it uses TLS. The pod must be healthy and run the configuration with the given checksum.
+func (r *ReconcileZNC) addressForCR(instance *zncv1.ZNC, cfgHash string) (string, bool, error) {
+	pods, err := r.podsForCR(instance)
+	if err != nil {
+		return "", false, err
+	}
+	if len(pods) != 1 {
+		return "", false, fmt.Errorf("expected a single ZNC pod, found %d", len(pods))
+	}
+	pod := &pods[0]
+	if status, _, message := podHealth(pod); status != corev1.ConditionTrue {
+		return "", false, fmt.Errorf("%s", message)
+	}
+	if pod.Annotations[checksumAnnotation] != cfgHash {
+		return "", false, fmt.Errorf("pod %s does not run the expected configuration", pod.Name)
+	}
+	if len(pod.Status.PodIP) == 0 {
+		return "", false, fmt.Errorf("pod %s has no IP address", pod.Name)
+	}
+	for _, listener := range instance.Spec.Config.GetListeners() {
+		if listener.GetAllowIRC() {
+			return net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(listener.Port))), listener.SSL, nil
+		}
+	}
+	return "", false, fmt.Errorf("no listener accepts IRC connections")
+}
@@ -0,0 +1,316 @@
+package znc
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+	"znc-operator/pkg/zncconf"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// blockReader maps the settings of a znc.conf block onto fields of a spec, keeping track of the settings that have
+// been mapped and of values that could not be converted.
+type blockReader struct {
+	block  *zncconf.Block
+	path   string
+	mapped []bool
+	errs   []error
+}
+
+func newBlockReader(block *zncconf.Block, parent string) *blockReader {
+	path := parent
+	if len(block.Type) > 0 {
+		path += block.Type + " " + block.Name + "/"
+	}
+	return &blockReader{block: block, path: path, mapped: make([]bool, len(block.Settings))}
+}
+
+// values returns the values of all settings with the given key, which is matched case-insensitively like ZNC does.
+func (r *blockReader) values(key string) []string {
+	var values []string
+	for i, setting := range r.block.Settings {
+		if strings.EqualFold(setting.Key, key) {
+			values = append(values, setting.Value)
+			r.mapped[i] = true
+		}
+	}
+	return values
+}
+
+// modules returns the modules loaded by all settings with the given key.
+func (r *blockReader) modules(key string) []zncv1.ZNCSpecModule {
+	var modules []zncv1.ZNCSpecModule
+	for _, value := range r.values(key) {
+		modules = append(modules, zncv1.ParseModule(value))
+	}
+	return modules
+}
+
+// servers returns the servers defined by all settings with the given key.
+func (r *blockReader) servers(key string) []zncv1.ZNCSpecConfigUserNetworkServer {
+	var servers []zncv1.ZNCSpecConfigUserNetworkServer
+	for _, value := range r.values(key) {
+		servers = append(servers, zncv1.ParseServer(value))
+	}
+	return servers
+}
+
+// value returns the value of the first setting with the given key. Further settings with the same key are ignored by
+// ZNC and are therefore left unmapped.
+func (r *blockReader) value(key string) (string, bool) {
+	for i, setting := range r.block.Settings {
+		if strings.EqualFold(setting.Key, key) {
+			r.mapped[i] = true
+			return setting.Value, true
+		}
+	}
+	return "", false
+}
+
+// ignore marks the settings with the given key and value as mapped, e.g. because the operator always renders them.
+func (r *blockReader) ignore(key string, value string) {
+	for i, setting := range r.block.Settings {
+		if strings.EqualFold(setting.Key, key) && setting.Value == value {
+			r.mapped[i] = true
+		}
+	}
+}
+
+func (r *blockReader) setString(key string, field *string) {
+	if value, ok := r.value(key); ok {
+		*field = value
+	}
+}
+
+func (r *blockReader) setBool(key string, field *bool) {
+	value, ok := r.value(key)
+	if !ok {
+		return
+	}
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		*field = true
+	case "false", "no", "off", "0":
+		*field = false
+	default:
+		r.errs = append(r.errs, fmt.Errorf("%s%s: invalid boolean %q", r.path, key, value))
+	}
+}
+
+func (r *blockReader) setBoolPtr(key string, field **bool) {
+	if _, ok := r.value(key); ok {
+		*field = new(bool)
+		r.setBool(key, *field)
+	}
+}
+
+func (r *blockReader) setInt32(key string, field *int32) {
+	value, ok := r.value(key)
+	if !ok {
+		return
+	}
+	number, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s%s: invalid number %q", r.path, key, value))
+		return
+	}
+	*field = int32(number)
+}
+
+func (r *blockReader) setInt(key string, field *int) {
+	number := int32(*field)
+	r.setInt32(key, &number)
+	*field = int(number)
+}
+
+// unmapped returns the paths of all settings that have not been mapped.
+func (r *blockReader) unmapped() []string {
+	var unmapped []string
+	for i, setting := range r.block.Settings {
+		if !r.mapped[i] {
+			unmapped = append(unmapped, r.path+setting.Key)
+		}
+	}
+	return unmapped
+}
+
+// configurationImport collects the unmapped settings and errors while mapping a znc.conf onto a spec.
+type configurationImport struct {
+	unmapped []string
+	errs     []error
+}
+
+// done records the unmapped settings and conversion errors of the given block.
+func (c *configurationImport) done(r *blockReader) {
+	c.unmapped = append(c.unmapped, r.unmapped()...)
+	c.errs = append(c.errs, r.errs...)
+}
+
+// unmappedBlock records a nested block that has no equivalent in the spec.
+func (c *configurationImport) unmappedBlock(r *blockReader, block *zncconf.Block) {
+	c.unmapped = append(c.unmapped, r.path+block.Type+" "+block.Name)
+}
+
+func (c *configurationImport) readRoot(root *zncconf.Block) *zncv1.ZNCSpec {
+	spec := &zncv1.ZNCSpec{}
+	config := &spec.Config
+	r := newBlockReader(root, "")
+	r.setString("Version", &spec.Version)
+	config.LoadModules = r.modules("LoadModule")
+	r.setInt("AnonIPLimit", &config.AnonIPLimit)
+	r.setInt("ConnectDelay", &config.ConnectDelay)
+	r.setBool("HideVersion", &config.HideVersion)
+	r.setInt32("MaxBufferSize", &config.MaxBufferSize)
+	config.Motd = r.values("Motd")
+	r.setInt32("ServerThrottle", &config.ServerThrottle)
+	r.setString("StatusPrefix", &config.StatusPrefix)
+	config.TrustedProxies = r.values("TrustedProxy")
+	c.done(r)
+	for _, block := range root.Blocks {
+		switch strings.ToLower(block.Type) {
+		case "listener":
+			config.Listeners = append(config.Listeners, c.readListener(block, r.path))
+		case "user":
+			config.Users = append(config.Users, c.readUser(block, r.path))
+		default:
+			c.unmappedBlock(r, block)
+		}
+	}
+	return spec
+}
+
+func (c *configurationImport) readListener(block *zncconf.Block, parent string) zncv1.ZNCSpecConfigListener {
+	listener := zncv1.ZNCSpecConfigListener{Name: block.Name}
+	r := newBlockReader(block, parent)
+	r.setBoolPtr("AllowIRC", &listener.AllowIRC)
+	r.setBoolPtr("AllowWeb", &listener.AllowWeb)
+	r.setString("Host", &listener.Host)
+	r.setInt32("Port", &listener.Port)
+	r.setBoolPtr("IPv4", &listener.IPv4)
+	r.setBool("IPv6", &listener.IPv6)
+	r.setBool("SSL", &listener.SSL)
+	r.setString("URIPrefix", &listener.URIPrefix)
+	c.done(r)
+	for _, nested := range block.Blocks {
+		c.unmappedBlock(r, nested)
+	}
+	return listener
+}
+
+func (c *configurationImport) readUser(block *zncconf.Block, parent string) zncv1.ZNCSpecConfigUser {
+	user := zncv1.ZNCSpecConfigUser{Name: block.Name}
+	r := newBlockReader(block, parent)
+	r.setBool("Admin", &user.Admin)
+	r.ignore("Allow", "*")
+	r.setString("AltNick", &user.AltNick)
+	r.setBool("AppendTimestamp", &user.AppendTimestamp)
+	r.setBool("AutoClearChanBuffer", &user.AutoClearChanBuffer)
+	r.setBool("AutoClearQueryBuffer", &user.AutoClearQueryBuffer)
+	r.setInt32("Buffer", &user.Buffer)
+	r.setInt32("ChanBufferSize", &user.ChanBufferSize)
+	r.setString("ChanModes", &user.ChanModes)
+	r.setString("ClientEncoding", &user.ClientEncoding)
+	r.setString("Ident", &user.Ident)
+	r.setInt32("JoinTries", &user.JoinTries)
+	user.LoadModules = r.modules("LoadModule")
+	r.setInt32("MaxJoins", &user.MaxJoins)
+	r.setInt32("MaxQueryBuffers", &user.MaxQueryBuffers)
+	r.setBool("MultiClients", &user.MultiClients)
+	r.setString("Nick", &user.Nick)
+	r.setInt32("NoTrafficTimeout", &user.NoTrafficTimeout)
+	r.setBool("PrependTimestamp", &user.PrependTimestamp)
+	r.setInt32("QueryBufferSize", &user.QueryBufferSize)
+	r.setString("QuitMsg", &user.QuitMsg)
+	r.setString("RealName", &user.RealName)
+	r.setString("StatusPrefix", &user.StatusPrefix)
+	r.setString("TimestampFormat", &user.TimestampFormat)
+	r.setString("Timezone", &user.Timezone)
+	r.setString("Pass", &user.Pass)
+	c.done(r)
+	for _, nested := range block.Blocks {
+		switch strings.ToLower(nested.Type) {
+		case "network":
+			user.Networks = append(user.Networks, c.readNetwork(nested, r.path))
+		case "pass":
+			user.Password = c.readPass(nested, r.path)
+		default:
+			c.unmappedBlock(r, nested)
+		}
+	}
+	return user
+}
+
+// readPass maps the <Pass password> block written by ZNC itself onto the structured password definition.
+func (c *configurationImport) readPass(block *zncconf.Block, parent string) *zncv1.ZNCSpecConfigUserPass {
+	pass := &zncv1.ZNCSpecConfigUserPass{}
+	r := newBlockReader(block, parent)
+	r.setString("Hash", &pass.Hash)
+	r.setString("Method", &pass.Method)
+	r.setString("Salt", &pass.Salt)
+	c.done(r)
+	for _, nested := range block.Blocks {
+		c.unmappedBlock(r, nested)
+	}
+	return pass
+}
+
+func (c *configurationImport) readNetwork(block *zncconf.Block, parent string) zncv1.ZNCSpecConfigUserNetwork {
+	network := zncv1.ZNCSpecConfigUserNetwork{Name: block.Name}
+	r := newBlockReader(block, parent)
+	r.setString("AltNick", &network.AltNick)
+	r.setString("Encoding", &network.Encoding)
+	r.setString("Ident", &network.Ident)
+	r.setBoolPtr("IRCConnectEnabled", &network.IRCConnectEnabled)
+	r.setInt32("JoinDelay", &network.JoinDelay)
+	network.LoadModules = r.modules("LoadModule")
+	r.setString("Nick", &network.Nick)
+	r.setString("QuitMsg", &network.QuitMsg)
+	r.setString("RealName", &network.RealName)
+	network.Servers = r.servers("Server")
+	c.done(r)
+	for _, nested := range block.Blocks {
+		switch strings.ToLower(nested.Type) {
+		case "chan":
+			network.Channels = append(network.Channels, c.readChannel(nested, r.path))
+		default:
+			c.unmappedBlock(r, nested)
+		}
+	}
+	return network
+}
+
+func (c *configurationImport) readChannel(block *zncconf.Block, parent string) zncv1.ZNCSpecConfigUserNetworkChan {
+	channel := zncv1.ZNCSpecConfigUserNetworkChan{Name: block.Name}
+	r := newBlockReader(block, parent)
+	r.setBool("AutoClearChanBuffer", &channel.AutoClearChanBuffer)
+	r.setInt32("Buffer", &channel.Buffer)
+	r.setBool("Detached", &channel.Detached)
+	r.setBool("Disabled", &channel.Disabled)
+	r.setString("Key", &channel.Key)
+	r.setString("Modes", &channel.Modes)
+	c.done(r)
+	for _, nested := range block.Blocks {
+		c.unmappedBlock(r, nested)
+	}
+	return channel
+}
+
+// ParseConfiguration maps an existing znc.conf onto a spec. It is the inverse of RenderConfiguration for resolved
+// specs. Settings and blocks that have no equivalent in the spec are returned as unmapped, identified by their path in
+// the configuration, e.g. "User johndoe/Network libera/FloodRate".
+func ParseConfiguration(r io.Reader) (spec *zncv1.ZNCSpec, unmapped []string, err error) {
+	root, err := zncconf.Parse(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &configurationImport{}
+	spec = c.readRoot(root)
+	if err := utilerrors.NewAggregate(c.errs); err != nil {
+		return nil, nil, err
+	}
+	return spec, c.unmapped, nil
+}
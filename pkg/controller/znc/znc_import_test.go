@@ -0,0 +1,134 @@
+package znc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+)
+
+func TestParseConfigurationRoundTrip(t *testing.T) {
+	for name, spec := range goldenSpecs() {
+		spec.SetDefaults()
+		cfg, err := RenderConfiguration(spec)
+		if err != nil {
+			t.Fatalf("%s: rendering config caused an unexpected error: %v", name, err)
+		}
+		parsed, unmapped, err := ParseConfiguration(strings.NewReader(cfg))
+		if err != nil {
+			t.Fatalf("%s: parsing config caused an unexpected error: %v", name, err)
+		}
+		if len(unmapped) > 0 {
+			t.Errorf("%s: expected all settings to be mapped, got %v", name, unmapped)
+		}
+		// Only the version and the configuration are part of znc.conf.
+		expected := &zncv1.ZNCSpec{Version: spec.Version, Config: spec.Config}
+		if !reflect.DeepEqual(parsed, expected) {
+			t.Errorf("%s: expected\n%+v\ngot\n%+v", name, expected, parsed)
+		}
+	}
+}
+
+func TestParseConfigurationWrittenByZNC(t *testing.T) {
+	cfg := `// WARNING
+//
+// Do NOT edit this file while ZNC is running!
+Version = 1.8.2
+LoadModule = webadmin
+SSLCertFile = /znc-data/znc.pem
+
+<Listener listener0>
+	AllowIRC = true
+	AllowWeb = true
+	IPv4 = true
+	IPv6 = true
+	Port = 6697
+	SSL = true
+	URIPrefix = /
+</Listener>
+
+<User johndoe>
+	Admin      = true
+	Allow      = *
+	Allow      = 192.168.0.0/16
+	Nick       = johndoe
+	nick       = jdoe
+	/* MaxNetworks = 5
+	   MaxJoins = 10 */
+	<Pass password>
+		Method = sha256
+		Hash = 074cd22fd6e2aee30c84aa9ff3e67aebb61b44621797094be4063d912084bfcf
+		Salt = DMexkK*0YWl/AC+7/_Cx
+	</Pass>
+	<Network libera>
+		FloodRate = 2.00
+		IRCConnectEnabled = false
+		Server = irc.libera.chat +6697
+		<Chan #znc>
+			Detached = true
+		</Chan>
+	</Network>
+	<CTCPReply VERSION>
+	</CTCPReply>
+</User>
+`
+	spec, unmapped, err := ParseConfiguration(strings.NewReader(cfg))
+	if err != nil {
+		t.Fatal("parsing config caused an unexpected error", err)
+	}
+	allow, deny := true, false
+	expected := &zncv1.ZNCSpec{
+		Version: "1.8.2",
+		Config: zncv1.ZNCSpecConfig{
+			LoadModules: []zncv1.ZNCSpecModule{{Name: "webadmin"}},
+			Listeners: []zncv1.ZNCSpecConfigListener{
+				{Name: "listener0", AllowIRC: &allow, AllowWeb: &allow, IPv4: &allow, IPv6: true, Port: 6697, SSL: true, URIPrefix: "/"},
+			},
+			Users: []zncv1.ZNCSpecConfigUser{
+				{
+					Name:  "johndoe",
+					Admin: true,
+					Nick:  "johndoe",
+					Password: &zncv1.ZNCSpecConfigUserPass{
+						Method: "sha256",
+						Hash:   "074cd22fd6e2aee30c84aa9ff3e67aebb61b44621797094be4063d912084bfcf",
+						Salt:   "DMexkK*0YWl/AC+7/_Cx",
+					},
+					Networks: []zncv1.ZNCSpecConfigUserNetwork{
+						{
+							Name:              "libera",
+							IRCConnectEnabled: &deny,
+							Servers:           []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", Port: 6697, TLS: true}},
+							Channels:          []zncv1.ZNCSpecConfigUserNetworkChan{{Name: "#znc", Detached: true}},
+						},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, spec)
+	}
+	expectedUnmapped := []string{
+		"SSLCertFile",
+		"User johndoe/Allow",
+		"User johndoe/nick",
+		"User johndoe/Network libera/FloodRate",
+		"User johndoe/CTCPReply VERSION",
+	}
+	if !reflect.DeepEqual(unmapped, expectedUnmapped) {
+		t.Errorf("expected unmapped settings %v, got %v", expectedUnmapped, unmapped)
+	}
+}
+
+func TestParseConfigurationRejectsInvalidValues(t *testing.T) {
+	for _, cfg := range []string{
+		"<User johndoe>\n\tAdmin = maybe\n</User>\n",
+		"<User johndoe>\n\tBuffer = lots\n</User>\n",
+		"<User johndoe>\n",
+	} {
+		if _, _, err := ParseConfiguration(strings.NewReader(cfg)); err == nil {
+			t.Errorf("expected an error for\n%s", cfg)
+		}
+	}
+}
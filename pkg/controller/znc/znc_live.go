@@ -0,0 +1,538 @@
+package znc
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+	"znc-operator/pkg/zncconf"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// operatorPasswordKey is the key of the operator Secret holding the password of the operator user.
+	operatorPasswordKey = "password"
+	// operatorPasswordAlphabet is the set of characters the password of the operator user is generated from.
+	operatorPasswordAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// operatorPasswordLength is the length of the password of the operator user.
+	operatorPasswordLength = 32
+	// liveUpdateTimeout limits the time spent on applying a configuration change to a running ZNC instance.
+	liveUpdateTimeout = 30 * time.Second
+	// controlPanelModule is the target of the commands used to apply configuration changes.
+	controlPanelModule = "*controlpanel"
+)
+
+// The settings that can be changed at runtime, keyed by their lowercased key in znc.conf. The values are the names of
+// the corresponding variables of the controlpanel module.
+var (
+	userVariables = map[string]string{
+		"admin":                "Admin",
+		"altnick":              "AltNick",
+		"appendtimestamp":      "AppendTimestamp",
+		"autoclearchanbuffer":  "AutoClearChanBuffer",
+		"autoclearquerybuffer": "AutoClearQueryBuffer",
+		"chanbuffersize":       "ChanBufferSize",
+		"chanmodes":            "DefaultChanModes",
+		"clientencoding":       "ClientEncoding",
+		"ident":                "Ident",
+		"jointries":            "JoinTries",
+		"maxjoins":             "MaxJoins",
+		"maxquerybuffers":      "MaxQueryBuffers",
+		"multiclients":         "MultiClients",
+		"nick":                 "Nick",
+		"notraffictimeout":     "NoTrafficTimeout",
+		"prependtimestamp":     "PrependTimestamp",
+		"querybuffersize":      "QueryBufferSize",
+		"quitmsg":              "QuitMsg",
+		"realname":             "RealName",
+		"statusprefix":         "StatusPrefix",
+		"timestampformat":      "TimestampFormat",
+		"timezone":             "Timezone",
+	}
+	networkVariables = map[string]string{
+		"altnick":   "AltNick",
+		"encoding":  "Encoding",
+		"ident":     "Ident",
+		"joindelay": "JoinDelay",
+		"nick":      "Nick",
+		"quitmsg":   "QuitMsg",
+		"realname":  "RealName",
+	}
+	channelVariables = map[string]string{
+		"autoclearchanbuffer": "AutoClearChanBuffer",
+		"buffer":              "Buffer",
+		"detached":            "Detached",
+		"key":                 "Key",
+		"modes":               "DefModes",
+	}
+)
+
+// The values ZNC uses for settings missing from a block, keyed by their lowercased key.
+var (
+	userSettingDefaults    = map[string]string{"allow": "*"}
+	networkSettingDefaults = map[string]string{"ircconnectenabled": "true"}
+	channelSettingDefaults = map[string]string{"detached": "false", "disabled": "false"}
+)
+
+// operatorUserAllow restricts the addresses the operator user may connect from to loopback, which the preStop hook
+// connects from, and the addresses of the operator.
+var operatorUserAllow = append([]string{"127.0.0.1", "::1"}, operatorAddresses()...)
+
+// operatorAddresses returns the addresses the operator connects to ZNC instances from. They are read from the
+// comma-separated list of addresses or CIDRs in the environment variable ZNC_OPERATOR_ALLOW, e.g. the pod network of
+// the cluster, and default to the address of the operator pod in POD_IP.
+func operatorAddresses() []string {
+	value := os.Getenv("ZNC_OPERATOR_ALLOW")
+	if len(strings.TrimSpace(value)) == 0 {
+		value = os.Getenv("POD_IP")
+	}
+	var addresses []string
+	for _, address := range strings.Split(value, ",") {
+		if address = strings.TrimSpace(address); len(address) > 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// operatorSecretNameForCR returns the name of the Secret holding the password of the operator user of the given ZNC
+// instance.
+func operatorSecretNameForCR(cr *zncv1.ZNC) string {
+	return cr.Name + "-operator"
+}
+
+// generateOperatorPassword returns a random password for the operator user.
+func generateOperatorPassword() (string, error) {
+	password := make([]byte, operatorPasswordLength)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(operatorPasswordAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = operatorPasswordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// reconcileOperatorSecret returns the password of the operator user of the given ZNC instance. The password is
+// generated once and kept in a Secret owned by the instance.
+func (r *ReconcileZNC) reconcileOperatorSecret(reqLogger logr.Logger, instance *zncv1.ZNC) (string, error) {
+	found := &corev1.Secret{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: operatorSecretNameForCR(instance), Namespace: instance.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	exists := err == nil
+	if password := found.Data[operatorPasswordKey]; len(password) > 0 {
+		return string(password), nil
+	}
+	password, err := generateOperatorPassword()
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		reqLogger.Info("Updating operator Secret")
+		found.Data = map[string][]byte{operatorPasswordKey: []byte(password)}
+		return password, r.client.Update(context.TODO(), found)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      operatorSecretNameForCR(instance),
+			Namespace: instance.Namespace,
+			Labels:    labelsForCR(instance),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{operatorPasswordKey: []byte(password)},
+	}
+	if err := controllerutil.SetControllerReference(instance, secret, r.scheme); err != nil {
+		return "", err
+	}
+	reqLogger.Info("Creating a new Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+	return password, r.client.Create(context.TODO(), secret)
+}
+
+// addOperatorUser adds the admin user the operator logs in as to the given resolved spec. It may only connect from the
+// addresses in operatorUserAllow. Previous is the previously rendered password of the user, if any.
+func addOperatorUser(spec *zncv1.ZNCSpec, password string, previous *zncv1.ZNCSpecConfigUserPass) error {
+	pass, err := hashPlaintextPass(password, nil, previous)
+	if err != nil {
+		return err
+	}
+	spec.Config.Users = append(spec.Config.Users, zncv1.ZNCSpecConfigUser{
+		Name:        zncv1.OperatorUserName,
+		Nick:        zncv1.OperatorUserName,
+		Admin:       true,
+		Password:    pass,
+		LoadModules: []zncv1.ZNCSpecModule{{Name: "controlpanel"}},
+	})
+	return nil
+}
+
+// plaintextPasswords returns the plaintext passwords of the users of the given ZNC instance, as far as they are known,
+// keyed by user name. Only passwords read from Secrets that have not been hashed beforehand are known.
+func (r *ReconcileZNC) plaintextPasswords(cr *zncv1.ZNC) (map[string]string, error) {
+	passwords := map[string]string{}
+	for _, user := range cr.Spec.Config.Users {
+		if user.PasswordSecretRef == nil {
+			continue
+		}
+		password, err := r.getSecretPassword(cr.Namespace, user.PasswordSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := parsePass(password); !ok {
+			passwords[user.Name] = password
+		}
+	}
+	return passwords, nil
+}
+
+// liveCommand is a command sent to a module of a running ZNC instance.
+type liveCommand struct {
+	module  string
+	command string
+}
+
+// livePlan collects the commands that turn the running configuration of a ZNC instance into the desired one.
+type livePlan struct {
+	commands  []liveCommand
+	passwords map[string]string
+}
+
+// planLiveUpdate returns the controlpanel commands that apply the changes between the given configurations to a
+// running ZNC instance. Passwords holds the known plaintext passwords of the users, as ZNC only accepts plaintext
+// passwords at runtime. An error describes a change that requires a restart.
+func planLiveUpdate(oldConf string, newConf string, passwords map[string]string) ([]liveCommand, error) {
+	oldRoot, err := zncconf.Parse(strings.NewReader(oldConf))
+	if err != nil {
+		return nil, err
+	}
+	newRoot, err := zncconf.Parse(strings.NewReader(newConf))
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(oldRoot.Settings, newRoot.Settings) {
+		return nil, fmt.Errorf("global settings changed")
+	}
+	_, oldListeners := nestedBlocks(oldRoot, "User")
+	_, newListeners := nestedBlocks(newRoot, "User")
+	if !reflect.DeepEqual(oldListeners, newListeners) {
+		return nil, fmt.Errorf("listeners changed")
+	}
+	plan := &livePlan{passwords: passwords}
+	if err := diffBlocks(oldRoot, newRoot, "User", plan.updateUser, func(user *zncconf.Block) {
+		plan.add("DelUser %s", user.Name)
+	}); err != nil {
+		return nil, err
+	}
+	return plan.commands, nil
+}
+
+func (p *livePlan) add(format string, args ...interface{}) {
+	p.commands = append(p.commands, liveCommand{module: controlPanelModule, command: fmt.Sprintf(format, args...)})
+}
+
+func (p *livePlan) updateUser(old *zncconf.Block, user *zncconf.Block) error {
+	added := old == nil
+	if added {
+		password, ok := p.passwords[user.Name]
+		if !ok || strings.ContainsAny(password, " \t") {
+			return fmt.Errorf("user %s has been added, but its plaintext password is unknown", user.Name)
+		}
+		p.add("AddUser %s %s", user.Name, password)
+		old = zncconf.NewBlock(user.Type, user.Name)
+	}
+	err := diffSettings(old, user, userSettingDefaults, func(key string, oldValues []string, newValues []string) error {
+		switch key {
+		case "pass":
+			if added {
+				// AddUser has already set the password.
+				return nil
+			}
+			password, ok := p.passwords[user.Name]
+			if !ok {
+				return fmt.Errorf("password of user %s changed, but its plaintext is unknown", user.Name)
+			}
+			p.add("Set Password %s %s", user.Name, password)
+		case "loadmodule":
+			diffModules(oldValues, newValues, func(name string) {
+				p.add("UnloadModule %s %s", user.Name, name)
+			}, func(module string) {
+				p.add("LoadModule %s %s", user.Name, module)
+			})
+		case "buffer":
+			// The legacy setting is overridden by ChanBufferSize, which is always rendered.
+			if len(settingValues(user, "chanbuffersize")) == 0 {
+				return fmt.Errorf("setting Buffer of user %s cannot be changed at runtime", user.Name)
+			}
+		default:
+			variable, ok := userVariables[key]
+			if !ok {
+				return fmt.Errorf("setting %s of user %s cannot be changed at runtime", key, user.Name)
+			}
+			p.add("Set %s %s %s", variable, user.Name, lastValue(newValues))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return diffBlocks(old, user, "Network", func(old *zncconf.Block, network *zncconf.Block) error {
+		return p.updateNetwork(user.Name, old, network)
+	}, func(network *zncconf.Block) {
+		p.add("DelNetwork %s %s", user.Name, network.Name)
+	})
+}
+
+func (p *livePlan) updateNetwork(user string, old *zncconf.Block, network *zncconf.Block) error {
+	if old == nil {
+		p.add("AddNetwork %s %s", user, network.Name)
+		old = zncconf.NewBlock(network.Type, network.Name)
+	}
+	err := diffSettings(old, network, networkSettingDefaults, func(key string, oldValues []string, newValues []string) error {
+		switch key {
+		case "ircconnectenabled":
+			if enabled, _ := strconv.ParseBool(lastValue(newValues)); enabled {
+				p.add("Reconnect %s %s", user, network.Name)
+			} else {
+				p.add("Disconnect %s %s", user, network.Name)
+			}
+		case "loadmodule":
+			diffModules(oldValues, newValues, func(name string) {
+				p.add("UnloadNetModule %s %s %s", user, network.Name, name)
+			}, func(module string) {
+				p.add("LoadNetModule %s %s %s", user, network.Name, module)
+			})
+		case "server":
+			for _, server := range difference(oldValues, newValues) {
+				p.add("DelServer %s %s %s", user, network.Name, server)
+			}
+			for _, server := range difference(newValues, oldValues) {
+				p.add("AddServer %s %s %s", user, network.Name, server)
+			}
+		default:
+			variable, ok := networkVariables[key]
+			if !ok {
+				return fmt.Errorf("setting %s of network %s of user %s cannot be changed at runtime", key, network.Name, user)
+			}
+			p.add("SetNetwork %s %s %s %s", variable, user, network.Name, lastValue(newValues))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return diffBlocks(old, network, "Chan", func(old *zncconf.Block, channel *zncconf.Block) error {
+		if old == nil {
+			p.add("AddChan %s %s %s", user, network.Name, channel.Name)
+			old = zncconf.NewBlock(channel.Type, channel.Name)
+		}
+		return diffSettings(old, channel, channelSettingDefaults, func(key string, oldValues []string, newValues []string) error {
+			variable, ok := channelVariables[key]
+			if !ok {
+				return fmt.Errorf("setting %s of channel %s of network %s of user %s cannot be changed at runtime", key, channel.Name, network.Name, user)
+			}
+			p.add("SetChan %s %s %s %s %s", variable, user, network.Name, channel.Name, lastValue(newValues))
+			return nil
+		})
+	}, func(channel *zncconf.Block) {
+		p.add("DelChan %s %s %s", user, network.Name, channel.Name)
+	})
+}
+
+// diffBlocks calls remove for every nested block of the given type of oldBlock that is missing from newBlock, and then
+// update for every nested block of the given type of newBlock together with its counterpart in oldBlock, which is nil
+// if the block has been added. Nested blocks of other types must not have changed.
+func diffBlocks(oldBlock *zncconf.Block, newBlock *zncconf.Block, blockType string, update func(old *zncconf.Block, new *zncconf.Block) error, remove func(old *zncconf.Block)) error {
+	oldBlocks, oldOthers := nestedBlocks(oldBlock, blockType)
+	newBlocks, newOthers := nestedBlocks(newBlock, blockType)
+	if !reflect.DeepEqual(oldOthers, newOthers) {
+		return fmt.Errorf("sections other than %s of %s %s changed", blockType, newBlock.Type, newBlock.Name)
+	}
+	for _, old := range oldBlocks {
+		if findBlock(newBlocks, old.Name) == nil {
+			remove(old)
+		}
+	}
+	for _, block := range newBlocks {
+		if err := update(findBlock(oldBlocks, block.Name), block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nestedBlocks splits the nested blocks of the given block into those of the given type and the others.
+func nestedBlocks(block *zncconf.Block, blockType string) (matching []*zncconf.Block, others []*zncconf.Block) {
+	for _, nested := range block.Blocks {
+		if strings.EqualFold(nested.Type, blockType) {
+			matching = append(matching, nested)
+		} else {
+			others = append(others, nested)
+		}
+	}
+	return matching, others
+}
+
+// findBlock returns the block with the given name or nil.
+func findBlock(blocks []*zncconf.Block, name string) *zncconf.Block {
+	for _, block := range blocks {
+		if block.Name == name {
+			return block
+		}
+	}
+	return nil
+}
+
+// diffSettings calls changed for every lowercased key whose values differ between the given blocks. Keys missing from
+// the old block are assumed to have the given default values.
+func diffSettings(oldBlock *zncconf.Block, newBlock *zncconf.Block, defaults map[string]string, changed func(key string, oldValues []string, newValues []string) error) error {
+	var keys []string
+	seen := map[string]bool{}
+	for _, setting := range append(append([]zncconf.Setting{}, newBlock.Settings...), oldBlock.Settings...) {
+		if key := strings.ToLower(setting.Key); !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		oldValues, newValues := settingValues(oldBlock, key), settingValues(newBlock, key)
+		if defaultValue, ok := defaults[key]; ok && len(oldValues) == 0 {
+			oldValues = []string{defaultValue}
+		}
+		if reflect.DeepEqual(oldValues, newValues) {
+			continue
+		}
+		if err := changed(key, oldValues, newValues); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settingValues returns the values of the settings of the given block with the given lowercased key.
+func settingValues(block *zncconf.Block, key string) []string {
+	var values []string
+	for _, setting := range block.Settings {
+		if strings.ToLower(setting.Key) == key {
+			values = append(values, setting.Value)
+		}
+	}
+	return values
+}
+
+// lastValue returns the value ZNC uses for a single-valued setting, which is empty if the setting has been removed.
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// difference returns the values of a that are missing from b.
+func difference(a []string, b []string) []string {
+	var result []string
+	for _, value := range a {
+		found := false
+		for _, other := range b {
+			found = found || value == other
+		}
+		if !found {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// diffModules calls unload for the name of every module that is no longer loaded or whose arguments changed, and then
+// load for every module that is not loaded yet, given as name and arguments.
+func diffModules(oldValues []string, newValues []string, unload func(name string), load func(module string)) {
+	for _, module := range difference(oldValues, newValues) {
+		unload(zncv1.ParseModule(module).Name)
+	}
+	for _, module := range difference(newValues, oldValues) {
+		load(module)
+	}
+}
+
+// pendingUpdate is a configuration change that has been persisted, but not yet applied to the running ZNC pod.
+type pendingUpdate struct {
+	// configMap is the ConfigMap marked with the pending checksum.
+	configMap *corev1.ConfigMap
+	commands  []liveCommand
+}
+
+// liveUpdateCommands returns the commands that apply the changes between the given configurations to the running pod
+// of the given ZNC instance. An error describes why the changes require a restart.
+func (r *ReconcileZNC) liveUpdateCommands(instance *zncv1.ZNC, oldConf string, newConf string, operatorPassword string) ([]liveCommand, error) {
+	if instance.Spec.GetConfigUpdateStrategy() != zncv1.ZNCConfigUpdateStrategyLive {
+		return nil, fmt.Errorf("live configuration updates are disabled")
+	}
+	passwords, err := r.plaintextPasswords(instance)
+	if err != nil {
+		return nil, err
+	}
+	passwords[zncv1.OperatorUserName] = operatorPassword
+	return planLiveUpdate(oldConf, newConf, passwords)
+}
+
+// applyLiveUpdate sends the commands of the given pending update to the running pod of the given ZNC instance, which
+// runs the configuration with the given checksum, and clears the pending checksum of the ConfigMap. It returns the
+// checksum of the configuration the pod must run, which is the pending checksum if the update failed, so that the pod
+// is restarted.
+func (r *ReconcileZNC) applyLiveUpdate(reqLogger logr.Logger, instance *zncv1.ZNC, pending *pendingUpdate, cfgHash string, operatorPassword string) (string, error) {
+	configMap := pending.configMap
+	if err := r.sendLiveCommands(instance, pending.commands, cfgHash, operatorPassword); err != nil {
+		reqLogger.Info("Configuration changes require a restart of ZNC", "Reason", err.Error())
+		r.recordEvent(instance, corev1.EventTypeNormal, "RestartRequired", "Configuration changes require a restart of ZNC: %v", err)
+		cfgHash = configMap.Annotations[pendingChecksumAnnotation]
+		configMap.Annotations[checksumAnnotation] = cfgHash
+	} else {
+		reqLogger.Info("Applied configuration changes to the running ZNC pod", "Commands", len(pending.commands))
+		r.recordEvent(instance, corev1.EventTypeNormal, "ConfigApplied", "Applied %d configuration changes to the running ZNC pod", len(pending.commands))
+	}
+	delete(configMap.Annotations, pendingChecksumAnnotation)
+	if err := r.client.Update(context.TODO(), configMap); err != nil {
+		return "", err
+	}
+	return cfgHash, nil
+}
+
+// sendLiveCommands connects to the running pod of the given ZNC instance, which must run the configuration with the
+// given checksum, and sends the given commands.
+func (r *ReconcileZNC) sendLiveCommands(instance *zncv1.ZNC, commands []liveCommand, cfgHash string, operatorPassword string) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), liveUpdateTimeout)
+	defer cancel()
+	session, err := r.dialCR(ctx, instance, cfgHash, operatorPassword)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	for _, command := range commands {
+		// Only the first word of a command is logged, as the arguments may contain passwords.
+		name := strings.Fields(command.command)[0]
+		replies, err := session.Command(ctx, command.module, command.command)
+		if err != nil {
+			return fmt.Errorf("failed to send %s to %s: %v", name, command.module, err)
+		}
+		for _, reply := range replies {
+			if strings.HasPrefix(reply, "Error") || strings.HasPrefix(reply, "Usage") || strings.HasPrefix(reply, "Unknown command") {
+				return fmt.Errorf("%s rejected %s: %s", command.module, name, reply)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,475 @@
+package znc
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+	"znc-operator/pkg/irc"
+	"znc-operator/pkg/irc/irctest"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// dialFakeZNC returns a zncDialer that records the addresses it is asked to connect to and connects to the given fake
+// ZNC instance instead.
+func dialFakeZNC(server *irctest.Server, addresses *[]string) zncDialer {
+	return func(ctx context.Context, address string, options irc.Options) (zncSession, error) {
+		*addresses = append(*addresses, address)
+		return dialZNC(ctx, server.Addr, options)
+	}
+}
+
+// newFakeZNCForCR starts a fake ZNC instance which accepts the operator user of the given reconciled ZNC instance.
+func newFakeZNCForCR(t *testing.T, r *ReconcileZNC, cr *zncv1.ZNC) *irctest.Server {
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: operatorSecretNameForCR(cr), Namespace: cr.Namespace}, secret); err != nil {
+		t.Fatal(err)
+	}
+	return irctest.NewServer(map[string]string{zncv1.OperatorUserName: string(secret.Data[operatorPasswordKey])})
+}
+
+// requestCommands returns the commands of the given requests, prefixed with their module.
+func requestCommands(requests []irctest.Request) []string {
+	var commands []string
+	for _, request := range requests {
+		commands = append(commands, request.Module+" "+request.Command)
+	}
+	return commands
+}
+
+// createRunningPod creates the pod of the given ZNC instance, as created by its StatefulSet, running the configuration
+// with the given checksum.
+func createRunningPod(t *testing.T, r *ReconcileZNC, cr *zncv1.ZNC, checksum string) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cr.Name + "-0",
+			Namespace:   cr.Namespace,
+			Labels:      labelsForCR(cr),
+			Annotations: map[string]string{checksumAnnotation: checksum},
+		},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			PodIP:             "10.0.0.1",
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "znc", Ready: true}},
+		},
+	}
+	if err := r.client.Create(context.TODO(), pod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newLiveTestSpec() *zncv1.ZNCSpec {
+	return &zncv1.ZNCSpec{
+		Config: zncv1.ZNCSpecConfig{
+			Users: []zncv1.ZNCSpecConfigUser{{
+				Name: "johndoe",
+				Pass: makepassSecret,
+				Networks: []zncv1.ZNCSpecConfigUserNetwork{{
+					Name:     "libera",
+					Servers:  []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", TLS: true}},
+					Channels: []zncv1.ZNCSpecConfigUserNetworkChan{{Name: "#znc"}},
+				}},
+			}},
+		},
+	}
+}
+
+func TestPlanLiveUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(spec *zncv1.ZNCSpec)
+		expected []string
+		// partial is set if only the first commands are expected, e.g. as all settings of added users are set.
+		partial bool
+		restart bool
+	}{
+		{
+			name:   "unchanged",
+			modify: func(spec *zncv1.ZNCSpec) {},
+		},
+		{
+			name: "user settings",
+			modify: func(spec *zncv1.ZNCSpec) {
+				spec.Config.Users[0].Nick = "jdoe"
+				spec.Config.Users[0].QuitMsg = "see you"
+				spec.Config.Users[0].LoadModules = []zncv1.ZNCSpecModule{{Name: "log", Args: "-sanitize"}}
+			},
+			expected: []string{
+				"LoadModule johndoe log -sanitize",
+				"Set Nick johndoe jdoe",
+				"Set QuitMsg johndoe see you",
+			},
+		},
+		{
+			name: "module arguments",
+			modify: func(spec *zncv1.ZNCSpec) {
+				spec.Config.Users[0].Networks[0].LoadModules = []zncv1.ZNCSpecModule{{Name: "simple_away", Args: "-timer 30"}}
+			},
+			expected: []string{"LoadNetModule johndoe libera simple_away -timer 30"},
+		},
+		{
+			name: "network settings",
+			modify: func(spec *zncv1.ZNCSpec) {
+				deny := false
+				network := &spec.Config.Users[0].Networks[0]
+				network.IRCConnectEnabled = &deny
+				network.Servers = []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.eu.libera.chat", TLS: true}}
+				network.Channels = []zncv1.ZNCSpecConfigUserNetworkChan{{Name: "#znc", Key: "secret"}, {Name: "#kubernetes"}}
+			},
+			expected: []string{
+				"Disconnect johndoe libera",
+				"DelServer johndoe libera irc.libera.chat +6697",
+				"AddServer johndoe libera irc.eu.libera.chat +6697",
+				"SetChan Key johndoe libera #znc secret",
+				"AddChan johndoe libera #kubernetes",
+				"SetChan AutoClearChanBuffer johndoe libera #kubernetes false",
+				"SetChan Buffer johndoe libera #kubernetes 0",
+			},
+		},
+		{
+			name: "removed network and channel",
+			modify: func(spec *zncv1.ZNCSpec) {
+				spec.Config.Users[0].Networks = append(spec.Config.Users[0].Networks, zncv1.ZNCSpecConfigUserNetwork{
+					Name:    "oftc",
+					Servers: []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.oftc.net", TLS: true}},
+				})
+				spec.Config.Users[0].Networks[0].Channels = nil
+				spec.Config.Users[0].Networks[0].Nick = "jdoe"
+			},
+			expected: []string{
+				"SetNetwork Nick johndoe libera jdoe",
+				"DelChan johndoe libera #znc",
+				"AddNetwork johndoe oftc",
+				"AddServer johndoe oftc irc.oftc.net +6697",
+			},
+		},
+		{
+			name: "added user with known password",
+			modify: func(spec *zncv1.ZNCSpec) {
+				spec.Config.Users = append(spec.Config.Users, zncv1.ZNCSpecConfigUser{Name: "janedoe", Admin: true, PasswordSecretRef: &zncv1.SecretKeyRef{Name: "janedoe", Key: "password"}})
+			},
+			expected: []string{
+				"AddUser janedoe s3cret",
+				"Set Admin janedoe true",
+				"Set AppendTimestamp janedoe false",
+			},
+			partial: true,
+		},
+		{
+			name: "renamed user",
+			modify: func(spec *zncv1.ZNCSpec) {
+				spec.Config.Users[0].Name = "janedoe"
+				spec.Config.Users[0].Networks = nil
+			},
+			expected: []string{
+				"DelUser johndoe",
+				"AddUser janedoe s3cret",
+			},
+			partial: true,
+		},
+		{
+			name: "added user with unknown password",
+			modify: func(spec *zncv1.ZNCSpec) {
+				spec.Config.Users = append(spec.Config.Users, zncv1.ZNCSpecConfigUser{Name: "richardroe", Pass: makepassSecret})
+			},
+			restart: true,
+		},
+		{
+			name: "changed password",
+			modify: func(spec *zncv1.ZNCSpec) {
+				spec.Config.Users[0].Pass = "md5#003d56dd9caadc94bbd3393240799c65#DMexkK*0YWl/AC+7/_Cx#"
+			},
+			restart: true,
+		},
+		{
+			name: "global settings",
+			modify: func(spec *zncv1.ZNCSpec) {
+				spec.Config.MaxBufferSize = 1000
+			},
+			restart: true,
+		},
+		{
+			name: "listeners",
+			modify: func(spec *zncv1.ZNCSpec) {
+				spec.Config.Listeners = []zncv1.ZNCSpecConfigListener{{Name: "irc", Port: 6697, SSL: true}}
+				spec.TLS = &zncv1.ZNCSpecTLS{SecretName: "znc-tls"}
+			},
+			restart: true,
+		},
+		{
+			name: "disabled channel",
+			modify: func(spec *zncv1.ZNCSpec) {
+				spec.Config.Users[0].Networks[0].Channels[0].Disabled = true
+			},
+			restart: true,
+		},
+	}
+
+	oldConf, err := RenderConfiguration(newLiveTestSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		spec := newLiveTestSpec()
+		test.modify(spec)
+		newConf, err := RenderConfiguration(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		commands, err := planLiveUpdate(oldConf, newConf, map[string]string{"janedoe": "s3cret"})
+		if test.restart {
+			if err == nil {
+				t.Errorf("%s: expected a restart, got %v", test.name, commands)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		var actual []string
+		for _, command := range commands {
+			if command.module != controlPanelModule {
+				t.Errorf("%s: unexpected module %s", test.name, command.module)
+			}
+			actual = append(actual, command.command)
+		}
+		if test.partial && len(actual) > len(test.expected) {
+			actual = actual[:len(test.expected)]
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, strings.Join(test.expected, "\n"), strings.Join(actual, "\n"))
+		}
+	}
+}
+
+func TestOperatorAddresses(t *testing.T) {
+	defer os.Unsetenv("POD_IP")
+	defer os.Unsetenv("ZNC_OPERATOR_ALLOW")
+
+	os.Setenv("POD_IP", "10.244.1.7")
+	if expected, actual := []string{"10.244.1.7"}, operatorAddresses(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected addresses %v, got %v", expected, actual)
+	}
+	os.Setenv("ZNC_OPERATOR_ALLOW", " 10.244.0.0/16, fd00:10:244::/56,")
+	if expected, actual := []string{"10.244.0.0/16", "fd00:10:244::/56"}, operatorAddresses(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected addresses %v, got %v", expected, actual)
+	}
+	os.Unsetenv("POD_IP")
+	os.Unsetenv("ZNC_OPERATOR_ALLOW")
+	if actual := operatorAddresses(); len(actual) > 0 {
+		t.Errorf("expected no addresses, got %v", actual)
+	}
+}
+
+func TestReconcileAppliesConfigurationLive(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	server := newFakeZNCForCR(t, r, cr)
+	defer server.Close()
+	var replies []string
+	server.Handle(controlPanelModule, func(request irctest.Request) []string {
+		return replies
+	})
+	var addresses []string
+	r.dial = dialFakeZNC(server, &addresses)
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, configMap); err != nil {
+		t.Fatal(err)
+	}
+	if zncConf := configMap.Data["znc.conf"]; !strings.Contains(zncConf, "<User "+zncv1.OperatorUserName+">\n\tAdmin = true\n\tAllow = 127.0.0.1\n\tAllow = ::1\n\tAppendTimestamp") {
+		t.Errorf("expected the operator user to be rendered with restricted addresses, got\n%s", zncConf)
+	}
+	statefulSet := &appsv1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	checksum := statefulSet.Spec.Template.Annotations[checksumAnnotation]
+
+	createRunningPod(t, r, cr, checksum)
+
+	instance := &zncv1.ZNC{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	instance.Spec.Config.Users[0].QuitMsg = "brb"
+	if err := r.client.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if expected, actual := []string{"*controlpanel Set QuitMsg johndoe brb"}, requestCommands(server.Requests()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected commands %v, got %v", expected, actual)
+	}
+	if expected := []string{"10.0.0.1:6667"}; !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("expected connections to %v, got %v", expected, addresses)
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	if statefulSet.Spec.Template.Annotations[checksumAnnotation] != checksum {
+		t.Error("expected the pod not to be restarted")
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, configMap); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(configMap.Data["znc.conf"], "QuitMsg = brb") || configMap.Annotations[checksumAnnotation] != checksum {
+		t.Errorf("expected the ConfigMap to be updated and to keep the checksum, got %v\n%s", configMap.Annotations, configMap.Data["znc.conf"])
+	}
+	if _, ok := configMap.Annotations[pendingChecksumAnnotation]; ok {
+		t.Errorf("expected the pending checksum to be cleared, got %v", configMap.Annotations)
+	}
+
+	// Applied changes are not repeated.
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if actual := requestCommands(server.Requests()); len(actual) != 1 {
+		t.Errorf("expected no further commands, got %v", actual)
+	}
+
+	// Commands rejected by ZNC cause a restart.
+	replies = []string{"Error: Invalid value"}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	instance.Spec.Config.Users[0].QuitMsg = "bye"
+	noGracePeriod := int32(0)
+	instance.Spec.Restart.GracePeriodSeconds = &noGracePeriod
+	if err := r.client.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	if statefulSet.Spec.Template.Annotations[checksumAnnotation] == checksum {
+		t.Error("expected the pod to be restarted")
+	}
+}
+
+func TestReconcileRestartsAfterInterruptedLiveUpdate(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	server := newFakeZNCForCR(t, r, cr)
+	defer server.Close()
+	var addresses []string
+	r.dial = dialFakeZNC(server, &addresses)
+	statefulSet := &appsv1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	checksum := statefulSet.Spec.Template.Annotations[checksumAnnotation]
+	createRunningPod(t, r, cr, checksum)
+
+	// The ConfigMap is marked as if the operator had stopped while applying a change.
+	configMap := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, configMap); err != nil {
+		t.Fatal(err)
+	}
+	configMap.Annotations[pendingChecksumAnnotation] = "42"
+	if err := r.client.Update(context.TODO(), configMap); err != nil {
+		t.Fatal(err)
+	}
+	instance := &zncv1.ZNC{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	instance.Spec.Config.Users[0].QuitMsg = "brb"
+	noGracePeriod := int32(0)
+	instance.Spec.Restart.GracePeriodSeconds = &noGracePeriod
+	if err := r.client.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	for _, command := range requestCommands(server.Requests()) {
+		if strings.HasPrefix(command, controlPanelModule) {
+			t.Errorf("expected the interrupted update not to be repeated, got %s", command)
+		}
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	if statefulSet.Spec.Template.Annotations[checksumAnnotation] == checksum {
+		t.Error("expected the pod to be restarted")
+	}
+	configMap = &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, configMap); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := configMap.Annotations[pendingChecksumAnnotation]; ok {
+		t.Errorf("expected the pending checksum to be cleared, got %v", configMap.Annotations)
+	}
+}
+
+func TestReconcileRestartsWithRestartStrategy(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	cr.Spec.ConfigUpdateStrategy = zncv1.ZNCConfigUpdateStrategyRestart
+	server := irctest.NewServer(nil)
+	defer server.Close()
+	var addresses []string
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s, dial: dialFakeZNC(server, &addresses)}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	instance := &zncv1.ZNC{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	checksum := instance.Status.ConfigChecksum
+	instance.Spec.Config.Users[0].QuitMsg = "brb"
+	if err := r.client.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.ConfigChecksum == checksum || len(addresses) > 0 {
+		t.Errorf("expected a restart without connecting to ZNC, got %v", addresses)
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, configMap); err != nil {
+		t.Fatal(err)
+	}
+	// The operator user is still added, so that restarts can be announced.
+	if !strings.Contains(configMap.Data["znc.conf"], "<User "+zncv1.OperatorUserName+">") {
+		t.Errorf("expected the operator user with the Restart strategy, got\n%s", configMap.Data["znc.conf"])
+	}
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: operatorSecretNameForCR(cr), Namespace: cr.Namespace}, secret); err != nil {
+		t.Errorf("expected the operator Secret with the Restart strategy, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package znc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// registryFileName is the name of the file modules persist their settings ("NV" data) in.
+const registryFileName = ".registry"
+
+// registry holds the settings of a module, which ZNC stores in the registry file of the module's data directory.
+type registry map[string]string
+
+// marshal returns the content of the registry file. Each line holds a key and a value, both escaped like URL query
+// parameters. The keys are sorted, so that the content is stable across reconciliations.
+func (r registry) marshal() []byte {
+	keys := make([]string, 0, len(r))
+	for key := range r {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s %s\n", escapeRegistryValue(key), escapeRegistryValue(r[key]))
+	}
+	return buf.Bytes()
+}
+
+// escapeRegistryValue escapes a key or value of a registry file the same way ZNC does, i.e. alphanumeric characters,
+// '_', '.' and '-' are kept, spaces are replaced by '+' and all other bytes are percent-encoded.
+func escapeRegistryValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '_', c == '.', c == '-':
+			b.WriteByte(c)
+		case c == ' ':
+			b.WriteByte('+')
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// userModuleDataDir returns the data directory of a user module, relative to the ZNC data directory.
+func userModuleDataDir(user string, module string) string {
+	return path.Join("users", user, "moddata", module)
+}
+
+// networkModuleDataDir returns the data directory of a network module, relative to the ZNC data directory.
+func networkModuleDataDir(user string, network string, module string) string {
+	return path.Join("users", user, "networks", network, "moddata", module)
+}
+
+// parsePerform converts a command to the form stored by the perform module, which turns client commands like
+// "/msg NickServ identify" into raw IRC messages.
+func parsePerform(command string) string {
+	verb, rest := splitToken(strings.TrimPrefix(command, "/"))
+	if strings.EqualFold(verb, "MSG") {
+		verb = "PRIVMSG"
+	}
+	if strings.EqualFold(verb, "PRIVMSG") || strings.EqualFold(verb, "NOTICE") {
+		// Everything after the target is the text of the message.
+		if target, text := splitToken(rest); len(text) > 0 && !strings.HasPrefix(text, ":") {
+			rest = target + " :" + text
+		}
+	}
+	return strings.TrimSpace(verb + " " + rest)
+}
+
+// splitToken splits the first space separated token off the given string.
+func splitToken(value string) (string, string) {
+	tokens := strings.SplitN(strings.TrimSpace(value), " ", 2)
+	if len(tokens) < 2 {
+		return tokens[0], ""
+	}
+	return tokens[0], strings.TrimSpace(tokens[1])
+}
+
+// registries collects the registries of modules, keyed by the data directory of the module.
+type registries map[string]registry
+
+// set merges the given settings into the registry of the module with the given data directory. Settings that have been
+// set before are overridden.
+func (r registries) set(dir string, settings map[string]string) {
+	if r[dir] == nil {
+		r[dir] = registry{}
+	}
+	for key, value := range settings {
+		r[dir][key] = value
+	}
+}
+
+// moduleDataFilesForCR returns the module data files seeded into the data directory of the given ZNC instance, keyed
+// by their path relative to the data directory. The files are derived from the module data of the users and networks,
+// their SASL, NickServ and Perform settings and the Secrets they reference.
+func (r *ReconcileZNC) moduleDataFilesForCR(instance *zncv1.ZNC) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	registries := registries{}
+	for _, user := range instance.Spec.Config.Users {
+		for module, settings := range user.ModuleData {
+			registries.set(userModuleDataDir(user.Name, module), settings)
+		}
+		for _, network := range user.Networks {
+			for module, settings := range network.ModuleData {
+				registries.set(networkModuleDataDir(user.Name, network.Name, module), settings)
+			}
+
+			if sasl := network.SASL; sasl != nil {
+				settings := registry{
+					"mechanisms":   string(sasl.GetMechanism()),
+					"require_auth": "no",
+				}
+				if sasl.RequireAuth {
+					settings["require_auth"] = "yes"
+				}
+				if len(sasl.Username) > 0 {
+					settings["username"] = sasl.Username
+				}
+				if sasl.PasswordSecretRef != nil {
+					password, err := r.getSecretPassword(instance.Namespace, sasl.PasswordSecretRef)
+					if err != nil {
+						return nil, fmt.Errorf("failed to resolve SASL password of network %s of user %s: %v", network.Name, user.Name, err)
+					}
+					settings["password"] = password
+				}
+				registries.set(networkModuleDataDir(user.Name, network.Name, "sasl"), settings)
+
+				if len(sasl.CertificateSecretName) > 0 {
+					secret := &corev1.Secret{}
+					if err := r.client.Get(context.TODO(), types.NamespacedName{Name: sasl.CertificateSecretName, Namespace: instance.Namespace}, secret); err != nil {
+						return nil, fmt.Errorf("failed to get client certificate of network %s of user %s: %v", network.Name, user.Name, err)
+					}
+					pem, err := tlsPEM(secret)
+					if err != nil {
+						return nil, err
+					}
+					// The cert module presents the certificate stored in its data directory to the IRC server.
+					files[path.Join(networkModuleDataDir(user.Name, network.Name, "cert"), "user.pem")] = pem
+				}
+			}
+
+			if nickServ := network.NickServ; nickServ != nil && nickServ.PasswordSecretRef != nil {
+				password, err := r.getSecretPassword(instance.Namespace, nickServ.PasswordSecretRef)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve NickServ password of network %s of user %s: %v", network.Name, user.Name, err)
+				}
+				registries.set(networkModuleDataDir(user.Name, network.Name, "nickserv"), registry{
+					"IdentifyCmd":  nickServ.GetIdentifyCmd(),
+					"NickServName": nickServ.GetName(),
+					"Password":     password,
+				})
+			}
+
+			if len(network.Perform) > 0 {
+				var buf strings.Builder
+				for _, perform := range network.Perform {
+					commands := []string{perform.Command}
+					if perform.CommandSecretRef != nil {
+						value, err := r.getSecretValue(instance.Namespace, perform.CommandSecretRef)
+						if err != nil {
+							return nil, fmt.Errorf("failed to resolve perform commands of network %s of user %s: %v", network.Name, user.Name, err)
+						}
+						commands = strings.Split(value, "\n")
+					}
+					for _, command := range commands {
+						if command = strings.TrimRight(command, "\r"); len(strings.TrimSpace(command)) > 0 {
+							buf.WriteString(parsePerform(command) + "\n")
+						}
+					}
+				}
+				registries.set(networkModuleDataDir(user.Name, network.Name, "perform"), registry{"Perform": buf.String()})
+			}
+		}
+	}
+	for dir, settings := range registries {
+		files[path.Join(dir, registryFileName)] = settings.marshal()
+	}
+	return files, nil
+}
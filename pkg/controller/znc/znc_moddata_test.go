@@ -0,0 +1,231 @@
+package znc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRegistryMarshal(t *testing.T) {
+	data := registry{
+		"Password":    "p@ss word%",
+		"IdentifyCmd": "NICKSERV IDENTIFY {password}",
+	}.marshal()
+	expected := "IdentifyCmd NICKSERV+IDENTIFY+%7Bpassword%7D\n" +
+		"Password p%40ss+word%25\n"
+	if string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, string(data))
+	}
+}
+
+func TestReconcileSeedsAuthenticationModuleData(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	cr.Spec.Config.Users[0].Networks = []zncv1.ZNCSpecConfigUserNetwork{
+		{
+			Name:    "libera",
+			Servers: []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", TLS: true}},
+			SASL: &zncv1.ZNCSpecConfigUserNetworkSASL{
+				Username:          "johndoe",
+				PasswordSecretRef: &zncv1.SecretKeyRef{Name: "irc-accounts", Key: "libera"},
+				RequireAuth:       true,
+			},
+			NickServ: &zncv1.ZNCSpecConfigUserNetworkNickServ{
+				PasswordSecretRef: &zncv1.SecretKeyRef{Name: "irc-accounts", Key: "libera"},
+			},
+		},
+		{
+			Name:    "oftc",
+			Servers: []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.oftc.net", TLS: true}},
+			SASL: &zncv1.ZNCSpecConfigUserNetworkSASL{
+				Mechanism:             zncv1.SASLMechanismExternal,
+				CertificateSecretName: "oftc-cert",
+			},
+		},
+	}
+	accounts := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "irc-accounts", Namespace: cr.Namespace},
+		Data:       map[string][]byte{"libera": []byte("s3cret pass\n")},
+	}
+	cert := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "oftc-cert", Namespace: cr.Namespace},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSPrivateKeyKey: []byte("key"),
+			corev1.TLSCertKey:       []byte("crt"),
+		},
+	}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr, accounts, cert), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, configMap); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"<Network libera>\n\t\tIRCConnectEnabled = true\n\t\tLoadModule = sasl\n\t\tLoadModule = nickserv\n",
+		"<Network oftc>\n\t\tIRCConnectEnabled = true\n\t\tLoadModule = sasl\n\t\tLoadModule = cert\n",
+	} {
+		if !strings.Contains(configMap.Data["znc.conf"], expected) {
+			t.Errorf("expected rendered config to contain\n%s\ngot\n%s", expected, configMap.Data["znc.conf"])
+		}
+	}
+
+	generated := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "example-znc-generated", Namespace: cr.Namespace}, generated); err != nil {
+		t.Fatal("expected Secret to be created", err)
+	}
+	files := map[string]string{
+		"users/johndoe/networks/libera/moddata/sasl/.registry": "mechanisms PLAIN\npassword s3cret+pass\nrequire_auth yes\nusername johndoe\n",
+		"users/johndoe/networks/libera/moddata/nickserv/.registry": "IdentifyCmd NICKSERV+IDENTIFY+%7Bpassword%7D\n" +
+			"NickServName NickServ\nPassword s3cret+pass\n",
+		"users/johndoe/networks/oftc/moddata/sasl/.registry": "mechanisms EXTERNAL\nrequire_auth no\n",
+		"users/johndoe/networks/oftc/moddata/cert/user.pem":  "key\ncrt\n",
+	}
+	if _, ok := generated.Data[secretKeyForPath(configFilePath)]; !ok {
+		t.Error("expected the configuration to be stored alongside the module data")
+	}
+	delete(generated.Data, secretKeyForPath(configFilePath))
+	if len(generated.Data) != len(files) {
+		t.Errorf("expected %d files, got %d", len(files), len(generated.Data))
+	}
+	for path, content := range files {
+		if actual := string(generated.Data[secretKeyForPath(path)]); actual != content {
+			t.Errorf("expected %s to contain\n%s\ngot\n%s", path, content, actual)
+		}
+	}
+
+	statefulSet := &appsv1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	for _, volume := range statefulSet.Spec.Template.Spec.Volumes {
+		if volume.Name != "znc-secret-src" {
+			continue
+		}
+		if len(volume.Secret.Items) != len(files)+1 {
+			t.Errorf("expected all files to be mounted, got %v", volume.Secret.Items)
+		}
+		for _, item := range volume.Secret.Items {
+			if _, ok := files[item.Path]; (!ok && item.Path != configFilePath) || item.Key != secretKeyForPath(item.Path) {
+				t.Errorf("unexpected item %v", item)
+			}
+		}
+	}
+}
+
+func TestSecretKeyForPath(t *testing.T) {
+	if key := secretKeyForPath("znc.pem"); key != "znc.pem" {
+		t.Errorf("expected valid keys to be kept, got %s", key)
+	}
+	a := secretKeyForPath("users/john@doe/moddata/perform/.registry")
+	b := secretKeyForPath("users/john_doe/moddata/perform/.registry")
+	if a == b {
+		t.Errorf("expected distinct paths to have distinct keys, got %s", a)
+	}
+	if !strings.HasPrefix(a, "users_john_doe_moddata_perform_.registry-") {
+		t.Errorf("unexpected key %s", a)
+	}
+}
+
+func TestModuleDataFilesForCR(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	cr.Spec.Config.Users[0].ModuleData = map[string]map[string]string{
+		"autoattach": {"#znc * *": ""},
+	}
+	cr.Spec.Config.Users[0].Networks = []zncv1.ZNCSpecConfigUserNetwork{{
+		Name:    "libera",
+		Servers: []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", TLS: true}},
+		ModuleData: map[string]map[string]string{
+			"perform": {"Perform": "JOIN #znc"},
+			"sasl":    {"mechanisms": "EXTERNAL", "username": "johndoe"},
+		},
+		SASL: &zncv1.ZNCSpecConfigUserNetworkSASL{},
+	}}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s}
+
+	files, err := r.moduleDataFilesForCR(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"users/johndoe/moddata/autoattach/.registry":              "%23znc+%2A+%2A \n",
+		"users/johndoe/networks/libera/moddata/perform/.registry": "Perform JOIN+%23znc\n",
+		// Settings derived from the SASL settings take precedence over the module data.
+		"users/johndoe/networks/libera/moddata/sasl/.registry": "mechanisms PLAIN\nrequire_auth no\nusername johndoe\n",
+	}
+	if len(files) != len(expected) {
+		t.Errorf("expected %d files, got %d", len(expected), len(files))
+	}
+	for path, content := range expected {
+		if actual := string(files[path]); actual != content {
+			t.Errorf("expected %s to contain\n%s\ngot\n%s", path, content, actual)
+		}
+	}
+}
+
+func TestParsePerform(t *testing.T) {
+	for command, expected := range map[string]string{
+		"/join #znc key":                "join #znc key",
+		"/msg NickServ identify s3cret": "PRIVMSG NickServ :identify s3cret",
+		"PRIVMSG NickServ :identify":    "PRIVMSG NickServ :identify",
+		"notice johndoe hello there":    "notice johndoe :hello there",
+		"MODE johndoe +i":               "MODE johndoe +i",
+		"/msg ChanServ":                 "PRIVMSG ChanServ",
+	} {
+		if actual := parsePerform(command); actual != expected {
+			t.Errorf("parsePerform(%q): expected %q, got %q", command, expected, actual)
+		}
+	}
+}
+
+func TestModuleDataFilesForCRPerform(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	cr.Spec.Config.Users[0].Networks = []zncv1.ZNCSpecConfigUserNetwork{{
+		Name:    "libera",
+		Servers: []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", TLS: true}},
+		Perform: []zncv1.ZNCSpecConfigUserNetworkPerform{
+			{Command: "/mode johndoe +i"},
+			{CommandSecretRef: &zncv1.SecretKeyRef{Name: "irc-accounts", Key: "perform"}},
+		},
+	}}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "irc-accounts", Namespace: cr.Namespace},
+		Data:       map[string][]byte{"perform": []byte("/join #secret key\r\n\n/msg NickServ identify s3cret\n")},
+	}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr, secret), scheme: s}
+
+	files, err := r.moduleDataFilesForCR(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Perform mode+johndoe+%2Bi%0Ajoin+%23secret+key%0APRIVMSG+NickServ+%3Aidentify+s3cret%0A\n"
+	if actual := string(files["users/johndoe/networks/libera/moddata/perform/.registry"]); actual != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, actual)
+	}
+	if !referencedSecrets(cr)["irc-accounts"] {
+		t.Error("expected the perform Secret to be referenced")
+	}
+
+	conf, err := RenderConfiguration(&cr.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(conf, "LoadModule = perform\n") {
+		t.Errorf("expected the perform module to be loaded, got\n%s", conf)
+	}
+}
@@ -0,0 +1,244 @@
+package znc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+	"znc-operator/pkg/irc"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// networkStatusInterval is the interval in which the connection state of the networks is queried from ZNC.
+	networkStatusInterval = time.Minute
+	// networkStatusTimeout limits the time spent on querying the connection state of the networks.
+	networkStatusTimeout = 30 * time.Second
+	// networkDisconnectThreshold is the time an enabled network may stay disconnected before the NetworksConnected
+	// condition becomes false, so that reconnects do not flap the condition.
+	networkDisconnectThreshold = 5 * time.Minute
+	// statusModule is the target of the commands used to query the state of ZNC.
+	statusModule = "*status"
+)
+
+// networkQuery tracks the queries of the networks of a ZNC instance, which run in the background, so that
+// reconciliations are not blocked by ZNC.
+type networkQuery struct {
+	// started is the time the last query has been started.
+	started time.Time
+	// running is set while a query is in progress.
+	running bool
+	// result is the result of the last completed query, until it has been recorded in the status.
+	result *networkQueryResult
+}
+
+// networkQueryResult is the result of a query of the networks of a ZNC instance.
+type networkQueryResult struct {
+	networks []zncv1.ZNCNetworkStatus
+	err      error
+}
+
+// observeNetworks records the connection state of the networks of all users of the given ZNC instance, as far as it has
+// been queried from the running pod since the last reconciliation, in status. The pod must run the configuration with
+// the given checksum. Queries are started in the background once per networkStatusInterval and trigger a
+// reconciliation once they complete. The returned result schedules the next query.
+func (r *ReconcileZNC) observeNetworks(reqLogger logr.Logger, instance *zncv1.ZNC, cfgHash string, operatorPassword string, status *zncv1.ZNCStatus) reconcile.Result {
+	hasNetworks := false
+	for _, user := range instance.Spec.Config.Users {
+		hasNetworks = hasNetworks || len(user.Networks) > 0
+	}
+	if !hasNetworks {
+		status.Networks = nil
+		status.SetCondition(zncv1.ZNCConditionNetworksConnected, corev1.ConditionTrue, "NoNetworks", "No networks are configured")
+		return reconcile.Result{}
+	}
+	name := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+	if !status.IsConditionTrue(zncv1.ZNCConditionPodHealthy) {
+		// Changes of the pod trigger a reconciliation, so there is no need to poll. Once the pod is healthy again, the
+		// networks are queried right away.
+		r.forgetNetworkQuery(name)
+		status.SetCondition(zncv1.ZNCConditionNetworksConnected, corev1.ConditionUnknown, "PodNotHealthy", "The ZNC pod is not healthy")
+		return reconcile.Result{}
+	}
+
+	// Reconciliations in between, e.g. triggered by the status update of the previous query, keep the status.
+	result, wait := r.pollNetworkQuery(instance, cfgHash, operatorPassword, time.Now())
+	if result == nil {
+		return reconcile.Result{RequeueAfter: wait}
+	}
+	if result.err != nil {
+		reqLogger.Info("Failed to query the state of the networks", "Reason", result.err.Error())
+		status.SetCondition(zncv1.ZNCConditionNetworksConnected, corev1.ConditionUnknown, "QueryFailed", result.err.Error())
+		return reconcile.Result{RequeueAfter: wait}
+	}
+	now := metav1.Now()
+	status.Networks = mergeNetworkStatus(status.Networks, result.networks, now)
+	updateNetworksConnected(instance, status, now.Time)
+	return reconcile.Result{RequeueAfter: wait}
+}
+
+// pollNetworkQuery returns the result of the last query of the networks of the given ZNC instance, if it has completed
+// since the last call, and starts the next query, if the last one has been started at least networkStatusInterval
+// before the given time. It returns the time to wait before calling it again.
+func (r *ReconcileZNC) pollNetworkQuery(instance *zncv1.ZNC, cfgHash string, operatorPassword string, now time.Time) (*networkQueryResult, time.Duration) {
+	name := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+	r.networkQueriesMu.Lock()
+	defer r.networkQueriesMu.Unlock()
+	if r.networkQueries == nil {
+		r.networkQueries = map[types.NamespacedName]*networkQuery{}
+	}
+	query := r.networkQueries[name]
+	if query == nil {
+		query = &networkQuery{}
+		r.networkQueries[name] = query
+	}
+	result := query.result
+	query.result = nil
+	if query.running {
+		// The query triggers a reconciliation once it completes, which happens within networkStatusTimeout.
+		return nil, networkStatusTimeout
+	}
+	if elapsed := now.Sub(query.started); !query.started.IsZero() && elapsed >= 0 && elapsed < networkStatusInterval {
+		return result, networkStatusInterval - elapsed
+	}
+	query.started = now
+	query.running = true
+	go r.runNetworkQuery(query, instance.DeepCopy(), cfgHash, operatorPassword)
+	if result != nil {
+		return result, networkStatusInterval
+	}
+	return nil, networkStatusTimeout
+}
+
+// runNetworkQuery queries the networks of the given ZNC instance, records the result in the given query and triggers a
+// reconciliation of the instance.
+func (r *ReconcileZNC) runNetworkQuery(query *networkQuery, instance *zncv1.ZNC, cfgHash string, operatorPassword string) {
+	ctx, cancel := context.WithTimeout(context.Background(), networkStatusTimeout)
+	defer cancel()
+	networks, err := r.queryNetworks(ctx, instance, cfgHash, operatorPassword)
+	r.networkQueriesMu.Lock()
+	query.running = false
+	query.result = &networkQueryResult{networks: networks, err: err}
+	r.networkQueriesMu.Unlock()
+	if r.networkEvents != nil {
+		select {
+		case r.networkEvents <- event.GenericEvent{Meta: instance, Object: instance}:
+		default:
+			// The instance is reconciled after networkStatusTimeout anyway.
+		}
+	}
+}
+
+// forgetNetworkQuery forgets the queries of the networks of the named ZNC instance. A query in progress completes, but
+// its result is discarded.
+func (r *ReconcileZNC) forgetNetworkQuery(name types.NamespacedName) {
+	r.networkQueriesMu.Lock()
+	defer r.networkQueriesMu.Unlock()
+	delete(r.networkQueries, name)
+}
+
+// queryNetworks returns the connection state of the networks of all users of the given ZNC instance, as reported by
+// "ListNetworks" of *status. "ListServers" is not used, as it only covers the network of the session, and the operator
+// user has none. The transition times are not set.
+func (r *ReconcileZNC) queryNetworks(ctx context.Context, instance *zncv1.ZNC, cfgHash string, operatorPassword string) ([]zncv1.ZNCNetworkStatus, error) {
+	session, err := r.dialCR(ctx, instance, cfgHash, operatorPassword)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	var networks []zncv1.ZNCNetworkStatus
+	for _, user := range instance.Spec.Config.Users {
+		if len(user.Networks) == 0 {
+			continue
+		}
+		rows, err := queryTable(ctx, session, "ListNetworks "+user.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			network := zncv1.ZNCNetworkStatus{
+				User:      user.Name,
+				Network:   row["Network"],
+				Connected: row["On IRC"] == "Yes",
+				Server:    row["IRC Server"],
+				// The IRC user is reported as nick!ident@host.
+				Nick: strings.SplitN(row["IRC User"], "!", 2)[0],
+			}
+			// The channels are only reported while the network is connected.
+			if channels, err := strconv.ParseInt(row["Channels"], 10, 32); err == nil {
+				network.Channels = int32(channels)
+			}
+			networks = append(networks, network)
+		}
+	}
+	return networks, nil
+}
+
+// queryTable sends the given command to *status and parses the table it replies with.
+func queryTable(ctx context.Context, session zncSession, command string) ([]map[string]string, error) {
+	replies, err := session.Command(ctx, statusModule, command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s to %s: %v", command, statusModule, err)
+	}
+	for _, reply := range replies {
+		if strings.HasPrefix(reply, "Error") || strings.HasPrefix(reply, "Usage") || strings.HasPrefix(reply, "No such") {
+			return nil, fmt.Errorf("%s rejected %s: %s", statusModule, command, reply)
+		}
+	}
+	return irc.ParseTable(replies)
+}
+
+// mergeNetworkStatus returns the observed network states with their transition times carried over from the previous
+// states, unless the network has connected or disconnected since.
+func mergeNetworkStatus(previous []zncv1.ZNCNetworkStatus, observed []zncv1.ZNCNetworkStatus, now metav1.Time) []zncv1.ZNCNetworkStatus {
+	for i := range observed {
+		observed[i].LastTransitionTime = now
+		if old := findNetworkStatus(previous, observed[i].User, observed[i].Network); old != nil && old.Connected == observed[i].Connected {
+			observed[i].LastTransitionTime = old.LastTransitionTime
+		}
+	}
+	return observed
+}
+
+// findNetworkStatus returns the state of the given network of the given user or nil, if it has not been observed.
+func findNetworkStatus(networks []zncv1.ZNCNetworkStatus, user string, network string) *zncv1.ZNCNetworkStatus {
+	for i := range networks {
+		if networks[i].User == user && networks[i].Network == network {
+			return &networks[i]
+		}
+	}
+	return nil
+}
+
+// updateNetworksConnected derives the NetworksConnected condition from the observed network states. Networks that have
+// not been observed yet, e.g. because they are about to be added, are ignored.
+func updateNetworksConnected(instance *zncv1.ZNC, status *zncv1.ZNCStatus, now time.Time) {
+	var disconnected []string
+	for _, user := range instance.Spec.Config.Users {
+		for _, network := range user.Networks {
+			if !network.GetIRCConnectEnabled() {
+				continue
+			}
+			observed := findNetworkStatus(status.Networks, user.Name, network.Name)
+			if observed != nil && !observed.Connected && now.Sub(observed.LastTransitionTime.Time) >= networkDisconnectThreshold {
+				disconnected = append(disconnected, user.Name+"/"+network.Name)
+			}
+		}
+	}
+	if len(disconnected) > 0 {
+		status.SetCondition(zncv1.ZNCConditionNetworksConnected, corev1.ConditionFalse, "NetworksDisconnected",
+			fmt.Sprintf("Disconnected for more than %v: %s", networkDisconnectThreshold, strings.Join(disconnected, ", ")))
+		return
+	}
+	status.SetCondition(zncv1.ZNCConditionNetworksConnected, corev1.ConditionTrue, "NetworksConnected",
+		fmt.Sprintf("No enabled network has been disconnected for more than %v", networkDisconnectThreshold))
+}
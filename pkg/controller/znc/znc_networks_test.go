@@ -0,0 +1,189 @@
+package znc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+	"znc-operator/pkg/irc/irctest"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// waitForNetworkQuery waits for the query of the networks of the named ZNC instance to trigger a reconciliation.
+func waitForNetworkQuery(t *testing.T, r *ReconcileZNC, name types.NamespacedName) {
+	t.Helper()
+	select {
+	case e := <-r.networkEvents:
+		if e.Meta.GetName() != name.Name || e.Meta.GetNamespace() != name.Namespace {
+			t.Errorf("expected %s to be reconciled, got %s/%s", name, e.Meta.GetNamespace(), e.Meta.GetName())
+		}
+	case <-time.After(networkStatusTimeout):
+		t.Fatal("expected the query of the networks to complete")
+	}
+}
+
+func TestReconcileObservesNetworks(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	cr.Spec.Config.Users[0].Networks = []zncv1.ZNCSpecConfigUserNetwork{
+		{Name: "libera", Servers: []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", TLS: true}}},
+		{Name: "oftc", Servers: []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.oftc.net", TLS: true}}},
+	}
+	// The networks are observed whatever the configuration update strategy.
+	cr.Spec.ConfigUpdateStrategy = zncv1.ZNCConfigUpdateStrategyRestart
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	result, err := r.Reconcile(request)
+	if err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("expected no polling without a running pod, got %v", result)
+	}
+	instance := &zncv1.ZNC{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	if condition := instance.Status.GetCondition(zncv1.ZNCConditionNetworksConnected); condition == nil || condition.Status != corev1.ConditionUnknown {
+		t.Errorf("expected the NetworksConnected condition to be unknown, got %+v", condition)
+	}
+
+	server := newFakeZNCForCR(t, r, cr)
+	defer server.Close()
+	server.Handle(statusModule, func(request irctest.Request) []string {
+		switch request.Command {
+		case "ListNetworks johndoe":
+			return []string{
+				"+---------+--------+-----------------------+-------------------------+----------+",
+				"| Network | On IRC | IRC Server            | IRC User                | Channels |",
+				"+---------+--------+-----------------------+-------------------------+----------+",
+				"| libera  | Yes    | zirconium.libera.chat | johndoe!~jd@example.org | 3        |",
+				"| oftc    | No     |                       |                         |          |",
+				"+---------+--------+-----------------------+-------------------------+----------+",
+			}
+		}
+		return []string{"Unknown command!"}
+	})
+	var addresses []string
+	r.dial = dialFakeZNC(server, &addresses)
+	statefulSet := &appsv1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	createRunningPod(t, r, cr, statefulSet.Spec.Template.Annotations[checksumAnnotation])
+	r.networkEvents = make(chan event.GenericEvent, 1)
+
+	// The query runs in the background and triggers another reconciliation, which records its result.
+	result, err = r.Reconcile(request)
+	if err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if result.RequeueAfter != networkStatusTimeout {
+		t.Errorf("expected the instance to be reconciled once the query has timed out, got %v", result)
+	}
+	waitForNetworkQuery(t, r, request.NamespacedName)
+	result, err = r.Reconcile(request)
+	if err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > networkStatusInterval {
+		t.Errorf("expected the networks to be polled, got %v", result)
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	var networks []zncv1.ZNCNetworkStatus
+	for _, network := range instance.Status.Networks {
+		if network.LastTransitionTime.IsZero() {
+			t.Errorf("expected network %s to have a transition time", network.Network)
+		}
+		network.LastTransitionTime = metav1.Time{}
+		networks = append(networks, network)
+	}
+	expected := []zncv1.ZNCNetworkStatus{
+		{User: "johndoe", Network: "libera", Connected: true, Server: "zirconium.libera.chat", Nick: "johndoe", Channels: 3},
+		{User: "johndoe", Network: "oftc"},
+	}
+	if !reflect.DeepEqual(networks, expected) {
+		t.Errorf("expected networks %+v, got %+v", expected, networks)
+	}
+	if !instance.Status.IsConditionTrue(zncv1.ZNCConditionNetworksConnected) {
+		t.Errorf("expected networks disconnected just now to be tolerated, got %+v", instance.Status.GetCondition(zncv1.ZNCConditionNetworksConnected))
+	}
+
+	// ZNC is only queried once per interval, however often the instance is reconciled.
+	result, err = r.Reconcile(request)
+	if err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > networkStatusInterval {
+		t.Errorf("expected the next query to be scheduled, got %v", result)
+	}
+	if expected := []string{"*status ListNetworks johndoe"}; !reflect.DeepEqual(requestCommands(server.Requests()), expected) {
+		t.Errorf("expected commands %v, got %v", expected, requestCommands(server.Requests()))
+	}
+	r.networkQueriesMu.Lock()
+	r.networkQueries[request.NamespacedName].started = time.Now().Add(-networkStatusInterval)
+	r.networkQueriesMu.Unlock()
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	waitForNetworkQuery(t, r, request.NamespacedName)
+	if actual := requestCommands(server.Requests()); len(actual) != 2 {
+		t.Errorf("expected ZNC to be queried again after the interval, got %v", actual)
+	}
+}
+
+func TestUpdateNetworksConnected(t *testing.T) {
+	disabled := false
+	cr := newTestZNC()
+	cr.Spec.Config.Users[0].Networks = []zncv1.ZNCSpecConfigUserNetwork{
+		{Name: "libera"}, {Name: "oftc"}, {Name: "efnet", IRCConnectEnabled: &disabled}, {Name: "hackint"},
+	}
+	now := time.Now()
+	longAgo := metav1.NewTime(now.Add(-2 * networkDisconnectThreshold))
+	recently := metav1.NewTime(now.Add(-networkDisconnectThreshold / 2))
+	previous := []zncv1.ZNCNetworkStatus{
+		{User: "johndoe", Network: "libera", Connected: true, LastTransitionTime: longAgo},
+		{User: "johndoe", Network: "oftc", Connected: true, LastTransitionTime: longAgo},
+		{User: "johndoe", Network: "efnet", LastTransitionTime: longAgo},
+		{User: "johndoe", Network: "hackint", LastTransitionTime: longAgo},
+	}
+	observed := []zncv1.ZNCNetworkStatus{
+		{User: "johndoe", Network: "libera", Connected: true},
+		{User: "johndoe", Network: "oftc"},
+		{User: "johndoe", Network: "efnet"},
+		{User: "johndoe", Network: "hackint"},
+	}
+	status := &zncv1.ZNCStatus{Networks: mergeNetworkStatus(previous, observed, recently)}
+	for i, network := range status.Networks {
+		expected := longAgo
+		if network.Network == "oftc" {
+			expected = recently
+		}
+		if !network.LastTransitionTime.Equal(&expected) {
+			t.Errorf("expected network %d to have transitioned at %v, got %v", i, expected, network.LastTransitionTime)
+		}
+	}
+
+	updateNetworksConnected(cr, status, now)
+	condition := status.GetCondition(zncv1.ZNCConditionNetworksConnected)
+	if condition == nil || condition.Status != corev1.ConditionFalse || !strings.HasSuffix(condition.Message, ": johndoe/hackint") {
+		t.Errorf("expected only johndoe/hackint to be reported as disconnected, got %+v", condition)
+	}
+
+	updateNetworksConnected(cr, status, now.Add(networkDisconnectThreshold))
+	if condition := status.GetCondition(zncv1.ZNCConditionNetworksConnected); !strings.HasSuffix(condition.Message, ": johndoe/oftc, johndoe/hackint") {
+		t.Errorf("expected johndoe/oftc and johndoe/hackint to be reported as disconnected, got %+v", condition)
+	}
+}
@@ -0,0 +1,115 @@
+package znc
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+)
+
+// saltAlphabet is the set of characters ZNC itself uses when generating password salts.
+const saltAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!?.,:;/*-+_()"
+
+// saltLength is the length of the password salts generated by ZNC.
+const saltLength = 20
+
+// hashedPassRegexp matches passwords that have already been hashed, ie. <method>#<hash>#<salt>#.
+var hashedPassRegexp = regexp.MustCompile(`^(sha256|md5)#([0-9a-fA-F]+)#([^#]*)#$`)
+
+// parsePass parses a pre-hashed password in the <method>#<hash>#<salt># format understood by ZNC.
+// The second return value reports whether the given value actually is a pre-hashed password.
+func parsePass(value string) (*zncv1.ZNCSpecConfigUserPass, bool) {
+	matches := hashedPassRegexp.FindStringSubmatch(value)
+	if matches == nil {
+		return nil, false
+	}
+	return &zncv1.ZNCSpecConfigUserPass{
+		Method: matches[1],
+		Hash:   matches[2],
+		Salt:   matches[3],
+	}, true
+}
+
+// formatPass returns the given password in the <method>#<hash>#<salt># format understood by ZNC.
+func formatPass(pass zncv1.ZNCSpecConfigUserPass) string {
+	return fmt.Sprintf("%s#%s#%s#", pass.GetMethod(), pass.Hash, pass.Salt)
+}
+
+// generateSalt returns a random salt of saltLength characters.
+func generateSalt() (string, error) {
+	salt := make([]byte, saltLength)
+	for i := range salt {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(saltAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		salt[i] = saltAlphabet[n.Int64()]
+	}
+	return string(salt), nil
+}
+
+// hashPass hashes the given plaintext password and salt using the given method the same way ZNC does, ie. by hashing
+// the concatenation of password and salt, and returns the hex encoded digest.
+func hashPass(method string, password string, salt string) (string, error) {
+	switch method {
+	case "sha256":
+		sum := sha256.Sum256([]byte(password + salt))
+		return hex.EncodeToString(sum[:]), nil
+	case "md5":
+		sum := md5.Sum([]byte(password + salt))
+		return hex.EncodeToString(sum[:]), nil
+	default:
+		return "", fmt.Errorf("unsupported password hashing method %q", method)
+	}
+}
+
+// hashPlaintextPass hashes the given plaintext password using the method and salt of the given structured password
+// definition, which may be nil. If no salt has been specified, the previously rendered password is reused as long as it
+// matches the plaintext password and method, so that the rendered configuration stays stable across reconciliations.
+// Otherwise, a random salt is generated.
+func hashPlaintextPass(password string, pass *zncv1.ZNCSpecConfigUserPass, previous *zncv1.ZNCSpecConfigUserPass) (*zncv1.ZNCSpecConfigUserPass, error) {
+	result := &zncv1.ZNCSpecConfigUserPass{}
+	if pass != nil {
+		result.Method = pass.Method
+		result.Salt = pass.Salt
+	}
+	result.Method = result.GetMethod()
+	if len(result.Salt) == 0 && previous != nil && previous.GetMethod() == result.Method {
+		if hash, err := hashPass(result.Method, password, previous.Salt); err == nil && strings.EqualFold(hash, previous.Hash) {
+			return previous.DeepCopy(), nil
+		}
+	}
+	if len(result.Salt) == 0 {
+		salt, err := generateSalt()
+		if err != nil {
+			return nil, err
+		}
+		result.Salt = salt
+	}
+	hash, err := hashPass(result.Method, password, result.Salt)
+	if err != nil {
+		return nil, err
+	}
+	result.Hash = hash
+	return result, nil
+}
+
+// renderPass returns the value of the "Pass" setting of the given user.
+func renderPass(user zncv1.ZNCSpecConfigUser) (string, error) {
+	if user.Password == nil {
+		return user.Pass, nil
+	}
+	if len(user.Password.Hash) == 0 {
+		return "", fmt.Errorf("password of user %s has no hash; use passwordSecretRef to supply a plaintext password", user.Name)
+	}
+	if _, err := hashPass(user.Password.GetMethod(), "", ""); err != nil {
+		return "", fmt.Errorf("password of user %s: %v", user.Name, err)
+	}
+	return formatPass(*user.Password), nil
+}
@@ -0,0 +1,316 @@
+package znc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// makepassSecret is the output of "znc --makepass" for the password 'secret'.
+const makepassSecret = "sha256#074cd22fd6e2aee30c84aa9ff3e67aebb61b44621797094be4063d912084bfcf#DMexkK*0YWl/AC+7/_Cx#"
+
+func TestHashPass(t *testing.T) {
+	tests := []struct {
+		method   string
+		password string
+		salt     string
+		expected string
+	}{
+		// Generated with "znc --makepass".
+		{"sha256", "secret", "DMexkK*0YWl/AC+7/_Cx", "074cd22fd6e2aee30c84aa9ff3e67aebb61b44621797094be4063d912084bfcf"},
+		// Hashed the way ZNC's legacy MD5 passwords are verified, ie. md5(password + salt).
+		{"md5", "secret", "DMexkK*0YWl/AC+7/_Cx", "003d56dd9caadc94bbd3393240799c65"},
+	}
+	for _, test := range tests {
+		actual, err := hashPass(test.method, test.password, test.salt)
+		if err != nil {
+			t.Errorf("%s: hashing caused an unexpected error: %v", test.method, err)
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.method, test.expected, actual)
+		}
+	}
+	if _, err := hashPass("sha1", "secret", ""); err == nil {
+		t.Error("expected an error for an unsupported hashing method")
+	}
+}
+
+func TestParsePass(t *testing.T) {
+	pass, ok := parsePass(makepassSecret)
+	if !ok {
+		t.Fatalf("expected %s to be recognized as hashed password", makepassSecret)
+	}
+	if pass.Method != "sha256" || pass.Salt != "DMexkK*0YWl/AC+7/_Cx" || formatPass(*pass) != makepassSecret {
+		t.Errorf("unexpected parse result %+v", pass)
+	}
+	if _, ok := parsePass("secret"); ok {
+		t.Error("expected plaintext password not to be recognized as hashed password")
+	}
+}
+
+func TestGenerateSalt(t *testing.T) {
+	salt, err := generateSalt()
+	if err != nil {
+		t.Fatal("generating salt caused an unexpected error", err)
+	}
+	if len(salt) != saltLength {
+		t.Errorf("expected salt of length %d, got %q", saltLength, salt)
+	}
+	for _, c := range salt {
+		if !strings.ContainsRune(saltAlphabet, c) {
+			t.Errorf("unexpected character %q in salt %q", c, salt)
+		}
+	}
+	if other, _ := generateSalt(); salt == other {
+		t.Error("expected salts to be random")
+	}
+}
+
+func TestHashPlaintextPass(t *testing.T) {
+	previous, _ := parsePass(makepassSecret)
+	pass, err := hashPlaintextPass("secret", nil, previous)
+	if err != nil {
+		t.Fatal("hashing password caused an unexpected error", err)
+	}
+	if formatPass(*pass) != makepassSecret {
+		t.Errorf("expected the previous hash %s to be reused, got %s", makepassSecret, formatPass(*pass))
+	}
+
+	pass, err = hashPlaintextPass("changed", nil, previous)
+	if err != nil {
+		t.Fatal("hashing password caused an unexpected error", err)
+	}
+	if pass.Salt == previous.Salt || len(pass.Salt) != saltLength {
+		t.Errorf("expected a new random salt for a changed password, got %q", pass.Salt)
+	}
+	if hash, _ := hashPass("sha256", "changed", pass.Salt); hash != pass.Hash {
+		t.Errorf("expected hash %s, got %s", hash, pass.Hash)
+	}
+
+	pass, err = hashPlaintextPass("secret", &zncv1.ZNCSpecConfigUserPass{Method: "md5"}, previous)
+	if err != nil {
+		t.Fatal("hashing password caused an unexpected error", err)
+	}
+	if pass.Method != "md5" || pass.Salt == previous.Salt {
+		t.Errorf("expected a new md5 hash for a changed method, got %+v", pass)
+	}
+}
+
+func TestRenderPass(t *testing.T) {
+	tests := []struct {
+		user     zncv1.ZNCSpecConfigUser
+		expected string
+	}{
+		{zncv1.ZNCSpecConfigUser{Pass: makepassSecret}, makepassSecret},
+		{zncv1.ZNCSpecConfigUser{
+			Pass: "ignored",
+			Password: &zncv1.ZNCSpecConfigUserPass{
+				Hash: "074cd22fd6e2aee30c84aa9ff3e67aebb61b44621797094be4063d912084bfcf",
+				Salt: "DMexkK*0YWl/AC+7/_Cx",
+			},
+		}, makepassSecret},
+		{zncv1.ZNCSpecConfigUser{
+			Password: &zncv1.ZNCSpecConfigUserPass{
+				Hash:   "003d56dd9caadc94bbd3393240799c65",
+				Method: "md5",
+				Salt:   "DMexkK*0YWl/AC+7/_Cx",
+			},
+		}, "md5#003d56dd9caadc94bbd3393240799c65#DMexkK*0YWl/AC+7/_Cx#"},
+	}
+	for _, test := range tests {
+		actual, err := renderPass(test.user)
+		if err != nil {
+			t.Errorf("rendering password caused an unexpected error: %v", err)
+		}
+		if actual != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, actual)
+		}
+	}
+	if _, err := renderPass(zncv1.ZNCSpecConfigUser{Password: &zncv1.ZNCSpecConfigUserPass{Salt: "abc"}}); err == nil {
+		t.Error("expected an error for a password without hash")
+	}
+}
+
+func TestResolveSpecPasswordSecretRef(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "znc-passwords", Namespace: "default"},
+		Data: map[string][]byte{
+			"johndoe": []byte(makepassSecret),
+			"janedoe": []byte("secret\n"),
+		},
+	}
+	cr := &zncv1.ZNC{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-znc", Namespace: "default", UID: "0c7e0bd8-0f3c-4d4a-a3a8-6f3d0e1b5d0e"},
+		Spec: zncv1.ZNCSpec{
+			Config: zncv1.ZNCSpecConfig{
+				Users: []zncv1.ZNCSpecConfigUser{
+					{Name: "johndoe", PasswordSecretRef: &zncv1.SecretKeyRef{Name: "znc-passwords", Key: "johndoe"}},
+					{Name: "janedoe", PasswordSecretRef: &zncv1.SecretKeyRef{Name: "znc-passwords", Key: "janedoe"}},
+					{
+						Name:              "jimdoe",
+						Password:          &zncv1.ZNCSpecConfigUserPass{Method: "md5", Salt: "DMexkK*0YWl/AC+7/_Cx"},
+						PasswordSecretRef: &zncv1.SecretKeyRef{Name: "znc-passwords", Key: "janedoe"},
+					},
+					{Name: "nobody", PasswordSecretRef: &zncv1.SecretKeyRef{Name: "znc-passwords", Key: "nobody"}},
+				},
+			},
+		},
+	}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(scheme.Scheme, secret), scheme: scheme.Scheme}
+
+	if _, err := r.resolveSpec(cr, nil); err == nil {
+		t.Error("expected an error for a missing secret key")
+	}
+
+	cr.Spec.Config.Users = cr.Spec.Config.Users[:3]
+	spec, err := r.resolveSpec(cr, nil)
+	if err != nil {
+		t.Fatal("resolving spec caused an unexpected error", err)
+	}
+	salt := spec.Config.Users[1].Password.Salt
+	hash, _ := hashPass("sha256", "secret", salt)
+	expected := []string{
+		makepassSecret,
+		"sha256#" + hash + "#" + salt + "#",
+		"md5#003d56dd9caadc94bbd3393240799c65#DMexkK*0YWl/AC+7/_Cx#",
+	}
+	for i, user := range spec.Config.Users {
+		actual, err := renderPass(user)
+		if err != nil {
+			t.Errorf("rendering password of user %s caused an unexpected error: %v", user.Name, err)
+		}
+		if actual != expected[i] {
+			t.Errorf("user %s: expected %s, got %s", user.Name, expected[i], actual)
+		}
+	}
+	if cr.Spec.Config.Users[1].Password != nil {
+		t.Error("expected the spec of the ZNC instance not to be modified")
+	}
+}
+
+func TestReconcileKeepsPasswordsOutOfConfigMap(t *testing.T) {
+	s := newTestScheme(t)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "znc-passwords", Namespace: "default"},
+		Data:       map[string][]byte{"janedoe": []byte("secret")},
+	}
+	cr := newTestZNC()
+	cr.Spec.Config.Users = append(cr.Spec.Config.Users, zncv1.ZNCSpecConfigUser{
+		Name:              "janedoe",
+		PasswordSecretRef: &zncv1.SecretKeyRef{Name: "znc-passwords", Key: "janedoe"},
+	})
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr, secret), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, configMap); err != nil {
+		t.Fatal(err)
+	}
+	if zncConf := configMap.Data["znc.conf"]; strings.Contains(zncConf, "Pass =") || strings.Contains(zncConf, "sha256#") {
+		t.Errorf("expected the ConfigMap to contain no password hashes, got\n%s", zncConf)
+	}
+	generated := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: generatedSecretNameForCR(cr), Namespace: cr.Namespace}, generated); err != nil {
+		t.Fatal(err)
+	}
+	zncConf := string(generated.Data[secretKeyForPath(configFilePath)])
+	if strings.Count(zncConf, "Pass = sha256#") != 3 {
+		t.Errorf("expected the generated Secret to contain the password hashes of all users, got\n%s", zncConf)
+	}
+
+	// The randomly salted hashes are kept as long as the passwords do not change.
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: generatedSecretNameForCR(cr), Namespace: cr.Namespace}, generated); err != nil {
+		t.Fatal(err)
+	}
+	if actual := string(generated.Data[secretKeyForPath(configFilePath)]); actual != zncConf {
+		t.Errorf("expected the rendered configuration to be stable, got\n%s\ninstead of\n%s", actual, zncConf)
+	}
+}
+
+func TestResolveSpecServerPasswordSecretRef(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "irc-accounts", Namespace: "default"},
+		Data:       map[string][]byte{"libera": []byte("s3cret\n")},
+	}
+	cr := newTestZNC()
+	cr.Spec.Config.Users[0].Networks = []zncv1.ZNCSpecConfigUserNetwork{{
+		Name: "libera",
+		Servers: []zncv1.ZNCSpecConfigUserNetworkServer{
+			{Host: "irc.libera.chat", TLS: true, PasswordSecretRef: &zncv1.SecretKeyRef{Name: "irc-accounts", Key: "libera"}},
+			{Host: "irc.eu.libera.chat", Port: 6667, Password: "plain"},
+		},
+	}}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(scheme.Scheme, secret), scheme: scheme.Scheme}
+
+	spec, err := r.resolveSpec(cr, nil)
+	if err != nil {
+		t.Fatal("resolving spec caused an unexpected error", err)
+	}
+	conf, err := RenderConfiguration(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Server = irc.libera.chat +6697 s3cret\n", "Server = irc.eu.libera.chat 6667 plain\n"} {
+		if !strings.Contains(conf, expected) {
+			t.Errorf("expected rendered config to contain %q, got\n%s", expected, conf)
+		}
+	}
+	if cr.Spec.Config.Users[0].Networks[0].Servers[0].Password != "" {
+		t.Error("expected the spec of the ZNC instance not to be modified")
+	}
+	if !referencedSecrets(cr)["irc-accounts"] {
+		t.Error("expected the server password Secret to be referenced")
+	}
+
+	cr.Spec.Config.Users[0].Networks[0].Servers[0].PasswordSecretRef.Key = "oftc"
+	if _, err := r.resolveSpec(cr, nil); err == nil {
+		t.Error("expected an error for a missing secret key")
+	}
+}
+
+func TestReconcileKeepsServerPasswordsOutOfConfigMap(t *testing.T) {
+	s := newTestScheme(t)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "irc-accounts", Namespace: "default"},
+		Data:       map[string][]byte{"libera": []byte("s3cret")},
+	}
+	cr := newTestZNC()
+	cr.Spec.Config.Users[0].Networks = []zncv1.ZNCSpecConfigUserNetwork{{
+		Name:    "libera",
+		Servers: []zncv1.ZNCSpecConfigUserNetworkServer{{Host: "irc.libera.chat", TLS: true, PasswordSecretRef: &zncv1.SecretKeyRef{Name: "irc-accounts", Key: "libera"}}},
+	}}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr, secret), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, configMap); err != nil {
+		t.Fatal(err)
+	}
+	if zncConf := configMap.Data["znc.conf"]; strings.Contains(zncConf, "s3cret") || !strings.Contains(zncConf, "Server = irc.libera.chat +6697\n") {
+		t.Errorf("expected the ConfigMap to contain the server without its password, got\n%s", zncConf)
+	}
+	generated := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: generatedSecretNameForCR(cr), Namespace: cr.Namespace}, generated); err != nil {
+		t.Fatal(err)
+	}
+	if zncConf := string(generated.Data[secretKeyForPath(configFilePath)]); !strings.Contains(zncConf, "Server = irc.libera.chat +6697 s3cret\n") {
+		t.Errorf("expected the generated Secret to contain the server password, got\n%s", zncConf)
+	}
+}
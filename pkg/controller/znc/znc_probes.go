@@ -0,0 +1,155 @@
+package znc
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// probesForCR returns the liveness and readiness probes of the ZNC container for the given resolved spec, with the
+// overrides of the spec applied. Either of them is nil, if it has been disabled or if there is no listener to probe.
+// The liveness probe connects to the first listener allowing IRC clients and, as a hung ZNC still accepts TCP
+// connections, requests the web interface, if a listener allows web clients. The readiness probe connects to the first
+// listener allowing IRC clients.
+func probesForCR(spec *zncv1.ZNCSpec) (liveness *corev1.Probe, readiness *corev1.Probe) {
+	var ircListener, webListener *zncv1.ZNCSpecConfigListener
+	listeners := spec.Config.GetListeners()
+	for i := range listeners {
+		if ircListener == nil && listeners[i].GetAllowIRC() {
+			ircListener = &listeners[i]
+		}
+		if webListener == nil && listeners[i].GetAllowWeb() {
+			webListener = &listeners[i]
+		}
+	}
+	var overrides zncv1.ZNCSpecProbes
+	if spec.Probes != nil {
+		overrides = *spec.Probes
+	}
+
+	switch {
+	case webListener != nil && ircListener != nil && webListener != ircListener:
+		liveness = newListenersProbe(ircListener, webListener)
+	case webListener != nil:
+		liveness = newHTTPProbe(webListener)
+	case ircListener != nil:
+		liveness = newTCPProbe(ircListener)
+	}
+	if liveness != nil {
+		liveness.InitialDelaySeconds = 10
+		liveness.PeriodSeconds = 20
+		liveness.TimeoutSeconds = 5
+		liveness.FailureThreshold = 3
+		liveness = applyProbeOverrides(liveness, overrides.Liveness)
+	}
+	if ircListener != nil {
+		readiness = newTCPProbe(ircListener)
+		readiness.PeriodSeconds = 10
+		readiness.TimeoutSeconds = 3
+		readiness.FailureThreshold = 3
+		readiness = applyProbeOverrides(readiness, overrides.Readiness)
+	}
+	return liveness, readiness
+}
+
+// newTCPProbe returns a probe connecting to the given listener.
+func newTCPProbe(listener *zncv1.ZNCSpecConfigListener) *corev1.Probe {
+	return &corev1.Probe{
+		Handler: corev1.Handler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString(listener.Name)},
+		},
+		SuccessThreshold: 1,
+	}
+}
+
+// newListenersProbe returns a probe connecting to the given IRC listener and requesting the start page of the web
+// interface served by the given web listener, as a container has a single liveness probe.
+func newListenersProbe(ircListener *zncv1.ZNCSpecConfigListener, webListener *zncv1.ZNCSpecConfigListener) *corev1.Probe {
+	wget := "wget -q -T 3 -O /dev/null"
+	scheme := "http"
+	if webListener.SSL {
+		// ZNC usually serves a self-signed certificate.
+		wget += " --no-check-certificate"
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(localAddress(webListener), strconv.Itoa(int(webListener.Port))), startPagePath(webListener))
+	// The addresses are passed as arguments rather than interpolated into the script, so that the host and URI prefix
+	// of listeners are never interpreted by the shell.
+	script := fmt.Sprintf("nc -z -w 3 \"$1\" \"$2\" && %s \"$3\"", wget)
+	return &corev1.Probe{
+		Handler: corev1.Handler{
+			Exec: &corev1.ExecAction{Command: []string{"/bin/sh", "-c", script, "probe", localAddress(ircListener), strconv.Itoa(int(ircListener.Port)), url}},
+		},
+		SuccessThreshold: 1,
+	}
+}
+
+// localAddress returns the address the given listener can be reached at from within the ZNC container.
+func localAddress(listener *zncv1.ZNCSpecConfigListener) string {
+	switch {
+	case len(listener.Host) > 0:
+		return listener.Host
+	case !listener.GetIPv4():
+		return "::1"
+	}
+	return "127.0.0.1"
+}
+
+// startPagePath returns the path of the start page of the web interface served by the given listener.
+func startPagePath(listener *zncv1.ZNCSpecConfigListener) string {
+	path := "/"
+	if prefix := strings.Trim(listener.URIPrefix, "/"); len(prefix) > 0 {
+		path += prefix + "/"
+	}
+	return path
+}
+
+// newHTTPProbe returns a probe requesting the start page of the web interface served by the given listener.
+func newHTTPProbe(listener *zncv1.ZNCSpecConfigListener) *corev1.Probe {
+	scheme := corev1.URISchemeHTTP
+	if listener.SSL {
+		scheme = corev1.URISchemeHTTPS
+	}
+	return &corev1.Probe{
+		Handler: corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:   startPagePath(listener),
+				Port:   intstr.FromString(listener.Name),
+				Scheme: scheme,
+			},
+		},
+		SuccessThreshold: 1,
+	}
+}
+
+// applyProbeOverrides applies the given overrides, which may be nil, to the given probe. It returns nil, if the probe
+// has been disabled.
+func applyProbeOverrides(probe *corev1.Probe, overrides *zncv1.ZNCSpecProbe) *corev1.Probe {
+	if overrides == nil {
+		return probe
+	}
+	if overrides.Disabled {
+		return nil
+	}
+	for _, override := range []struct {
+		value  *int32
+		target *int32
+	}{
+		{value: overrides.InitialDelaySeconds, target: &probe.InitialDelaySeconds},
+		{value: overrides.PeriodSeconds, target: &probe.PeriodSeconds},
+		{value: overrides.TimeoutSeconds, target: &probe.TimeoutSeconds},
+		{value: overrides.SuccessThreshold, target: &probe.SuccessThreshold},
+		{value: overrides.FailureThreshold, target: &probe.FailureThreshold},
+	} {
+		if override.value != nil {
+			*override.target = *override.value
+		}
+	}
+	return probe
+}
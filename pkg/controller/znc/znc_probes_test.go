@@ -0,0 +1,121 @@
+package znc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestProbesForCR(t *testing.T) {
+	allow, deny := true, false
+	five := int32(5)
+	tests := []struct {
+		name      string
+		listeners []zncv1.ZNCSpecConfigListener
+		probes    *zncv1.ZNCSpecProbes
+		liveness  *corev1.Handler
+		readiness *corev1.Handler
+	}{
+		{
+			name: "default listeners",
+			liveness: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{
+				"/bin/sh", "-c", `nc -z -w 3 "$1" "$2" && wget -q -T 3 -O /dev/null "$3"`, "probe", "127.0.0.1", "6667", "http://127.0.0.1:8080/",
+			}}},
+			readiness: &corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("irc")}},
+		},
+		{
+			name:      "shared listener with SSL and URI prefix",
+			listeners: []zncv1.ZNCSpecConfigListener{{Name: "znc", Port: 6697, SSL: true, URIPrefix: "/znc"}},
+			liveness:  &corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/znc/", Port: intstr.FromString("znc"), Scheme: corev1.URISchemeHTTPS}},
+			readiness: &corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("znc")}},
+		},
+		{
+			name: "separate listeners with SSL, IPv6 and URI prefix",
+			listeners: []zncv1.ZNCSpecConfigListener{
+				{Name: "irc", Port: 6697, SSL: true, AllowWeb: &deny},
+				{Name: "web", Port: 8443, SSL: true, IPv4: &deny, IPv6: true, AllowIRC: &deny, URIPrefix: "/znc/"},
+			},
+			liveness: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{
+				"/bin/sh", "-c", `nc -z -w 3 "$1" "$2" && wget -q -T 3 -O /dev/null --no-check-certificate "$3"`, "probe", "127.0.0.1", "6697", "https://[::1]:8443/znc/",
+			}}},
+			readiness: &corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("irc")}},
+		},
+		{
+			name:      "IRC only",
+			listeners: []zncv1.ZNCSpecConfigListener{{Name: "irc", Port: 6667, AllowWeb: &deny}},
+			liveness:  &corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("irc")}},
+			readiness: &corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("irc")}},
+		},
+		{
+			name:      "web only",
+			listeners: []zncv1.ZNCSpecConfigListener{{Name: "web", Port: 8080, AllowIRC: &deny, AllowWeb: &allow}},
+			liveness:  &corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromString("web"), Scheme: corev1.URISchemeHTTP}},
+		},
+		{
+			name:      "disabled liveness probe",
+			probes:    &zncv1.ZNCSpecProbes{Liveness: &zncv1.ZNCSpecProbe{Disabled: true}, Readiness: &zncv1.ZNCSpecProbe{PeriodSeconds: &five}},
+			readiness: &corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("irc")}},
+		},
+	}
+	for _, test := range tests {
+		cr := newTestZNC()
+		cr.Spec.Config.Listeners = test.listeners
+		cr.Spec.Probes = test.probes
+		liveness, readiness := probesForCR(&cr.Spec)
+		if (liveness == nil) != (test.liveness == nil) || liveness != nil && !reflect.DeepEqual(liveness.Handler, *test.liveness) {
+			t.Errorf("%s: expected liveness probe %+v, got %+v", test.name, test.liveness, liveness)
+		}
+		if (readiness == nil) != (test.readiness == nil) || readiness != nil && !reflect.DeepEqual(readiness.Handler, *test.readiness) {
+			t.Errorf("%s: expected readiness probe %+v, got %+v", test.name, test.readiness, readiness)
+		}
+	}
+}
+
+func TestProbesForCROverrides(t *testing.T) {
+	cr := newTestZNC()
+	initialDelay, failureThreshold := int32(60), int32(10)
+	cr.Spec.Probes = &zncv1.ZNCSpecProbes{
+		Liveness: &zncv1.ZNCSpecProbe{InitialDelaySeconds: &initialDelay, FailureThreshold: &failureThreshold},
+	}
+	liveness, readiness := probesForCR(&cr.Spec)
+	if liveness.InitialDelaySeconds != 60 || liveness.FailureThreshold != 10 || liveness.PeriodSeconds != 20 || liveness.SuccessThreshold != 1 {
+		t.Errorf("expected the overrides to be applied to the defaults, got %+v", liveness)
+	}
+	if readiness.InitialDelaySeconds != 0 || readiness.PeriodSeconds != 10 || readiness.FailureThreshold != 3 {
+		t.Errorf("expected the readiness probe to keep its defaults, got %+v", readiness)
+	}
+
+	template := newPodTemplateForCR(cr, &cr.Spec, "", &generatedSecret{})
+	container := template.Spec.Containers[0]
+	if !reflect.DeepEqual(container.LivenessProbe, liveness) || !reflect.DeepEqual(container.ReadinessProbe, readiness) {
+		t.Errorf("expected the ZNC container to be probed, got %+v and %+v", container.LivenessProbe, container.ReadinessProbe)
+	}
+}
+
+func TestReconcileProbesWebURIPrefix(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	cr.Spec.Web = &zncv1.ZNCSpecWeb{Ingress: &zncv1.ZNCSpecWebIngress{Host: "znc.example.com", Path: "/znc"}}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	statefulSet := &appsv1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	liveness := statefulSet.Spec.Template.Spec.Containers[0].LivenessProbe
+	if liveness == nil || liveness.Exec == nil || liveness.Exec.Command[len(liveness.Exec.Command)-1] != "http://127.0.0.1:8080/znc/" {
+		t.Errorf("expected the liveness probe to request the URI prefix of the web listener, got %+v", liveness)
+	}
+}
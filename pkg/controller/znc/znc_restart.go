@@ -0,0 +1,143 @@
+package znc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// restartAnnouncedAnnotation holds the time the upcoming restart has been broadcast to the clients of a pod.
+	restartAnnouncedAnnotation = "config.znc.in/restart-announced"
+	// restartTimeout limits the time spent on broadcasting the restart notice and on shutting down ZNC.
+	restartTimeout = 30 * time.Second
+	// terminationGracePeriodSlack is the time ZNC is given to shut down after the grace period of the preStop hook has
+	// passed.
+	terminationGracePeriodSlack = 30
+)
+
+// prepareRestart drains the running pod of the given ZNC instance before it is replaced: the restart notice is
+// broadcast to all clients and, once the grace period has passed, ZNC is shut down, so that it sends QUIT messages to
+// all networks. It returns the time to wait before the pod may be replaced, which is zero once ZNC has been shut down.
+// Pods that are not running properly or cannot be reached are not waited for; the preStop hook announces the restart
+// then, if possible.
+func (r *ReconcileZNC) prepareRestart(reqLogger logr.Logger, instance *zncv1.ZNC, operatorPassword string) (time.Duration, error) {
+	pods, err := r.podsForCR(instance)
+	if err != nil {
+		return 0, err
+	}
+	if len(pods) != 1 {
+		return 0, nil
+	}
+	pod := &pods[0]
+	if status, _, _ := podHealth(pod); status != corev1.ConditionTrue || pod.DeletionTimestamp != nil {
+		return 0, nil
+	}
+
+	gracePeriod := time.Duration(instance.Spec.Restart.GetGracePeriodSeconds()) * time.Second
+	announced, err := time.Parse(time.RFC3339, pod.Annotations[restartAnnouncedAnnotation])
+	if err != nil {
+		notice := instance.Spec.Restart.GetNotice()
+		if err := r.sendStatusCommand(instance, pod.Annotations[checksumAnnotation], operatorPassword, "Broadcast "+notice); err != nil {
+			reqLogger.Info("Failed to announce the restart of ZNC", "Reason", err.Error())
+			r.recordEvent(instance, corev1.EventTypeWarning, "RestartAnnouncementFailed", "Failed to announce the restart of ZNC: %v", err)
+			return 0, nil
+		}
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[restartAnnouncedAnnotation] = time.Now().Format(time.RFC3339)
+		if err := r.client.Update(context.TODO(), pod); err != nil {
+			return 0, err
+		}
+		reqLogger.Info("Announced the restart of ZNC", "Pod.Name", pod.Name, "GracePeriod", gracePeriod.String())
+		r.recordEvent(instance, corev1.EventTypeNormal, "RestartAnnounced", "Announced the restart of ZNC to all clients, shutting down in %v", gracePeriod)
+		if gracePeriod > 0 {
+			return gracePeriod, nil
+		}
+	} else if remaining := gracePeriod - time.Since(announced); remaining > 0 {
+		return remaining, nil
+	}
+
+	// ZNC closes the connection without replying to the pending command, so errors are expected here. The pod is
+	// replaced right afterwards, before the restarted container gets to reconnect to the networks.
+	if err := r.sendStatusCommand(instance, pod.Annotations[checksumAnnotation], operatorPassword, "Shutdown"); err != nil {
+		reqLogger.Info("Shutting down ZNC did not complete cleanly", "Reason", err.Error())
+	}
+	r.recordEvent(instance, corev1.EventTypeNormal, "ShuttingDown", "Shut down ZNC in pod %s before replacing it", pod.Name)
+	return 0, nil
+}
+
+// sendStatusCommand connects to the running pod of the given ZNC instance, which must run the configuration with the
+// given checksum, and sends the given command to *status. Listeners using SSL are connected to through TLS.
+func (r *ReconcileZNC) sendStatusCommand(instance *zncv1.ZNC, cfgHash string, operatorPassword string, command string) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), restartTimeout)
+	defer cancel()
+	session, err := r.dialCR(ctx, instance, cfgHash, operatorPassword)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	replies, err := session.Command(ctx, statusModule, command)
+	if err != nil {
+		return fmt.Errorf("failed to send %s to %s: %v", command, statusModule, err)
+	}
+	for _, reply := range replies {
+		if strings.HasPrefix(reply, "Access denied") || strings.HasPrefix(reply, "Unknown command") {
+			return fmt.Errorf("%s rejected %s: %s", statusModule, command, reply)
+		}
+	}
+	return nil
+}
+
+// lifecycleForCR returns the lifecycle of the ZNC container of the given cr. Its preStop hook covers terminations the
+// operator does not drive, e.g. node drains: it broadcasts the restart notice through the first plain-text IRC
+// listener and waits for the grace period before the container is terminated. ZNC shuts down cleanly on SIGTERM
+// afterwards, sending QUIT messages to all networks. If ZNC cannot be reached, e.g. because the operator has shut it
+// down already, the hook returns immediately. It returns nil, if no listener is suitable, as the hook cannot speak TLS;
+// restarts due to configuration changes are still announced by the operator then.
+func lifecycleForCR(cr *zncv1.ZNC) *corev1.Lifecycle {
+	for _, listener := range cr.Spec.Config.GetListeners() {
+		if !listener.GetAllowIRC() || listener.SSL {
+			continue
+		}
+		script := fmt.Sprintf("if { printf 'PASS %[1]s:%%s\\r\\nNICK %[1]s\\r\\nUSER %[1]s 0 * :%[1]s\\r\\n' \"$ZNC_OPERATOR_PASSWORD\"; sleep 1;"+
+			" printf 'PRIVMSG %[2]s :Broadcast %%s\\r\\nQUIT\\r\\n' \"$ZNC_RESTART_NOTICE\"; } | nc -w 5 127.0.0.1 %[3]d; then sleep %[4]d; fi",
+			zncv1.OperatorUserName, statusModule, listener.Port, cr.Spec.Restart.GetGracePeriodSeconds())
+		return &corev1.Lifecycle{PreStop: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"/bin/sh", "-c", script}}}}
+	}
+	return nil
+}
+
+// restartEnvForCR returns the environment variables used by the preStop hook of the ZNC container of the given cr.
+func restartEnvForCR(cr *zncv1.ZNC) []corev1.EnvVar {
+	if lifecycleForCR(cr) == nil {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{
+			Name: "ZNC_OPERATOR_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: operatorSecretNameForCR(cr)},
+				Key:                  operatorPasswordKey,
+			}},
+		},
+		{
+			Name:  "ZNC_RESTART_NOTICE",
+			Value: cr.Spec.Restart.GetNotice(),
+		},
+	}
+}
+
+// terminationGracePeriodForCR returns the termination grace period of the ZNC pod of the given cr, which covers the
+// grace period of the preStop hook and the shutdown of ZNC.
+func terminationGracePeriodForCR(cr *zncv1.ZNC) *int64 {
+	seconds := int64(cr.Spec.Restart.GetGracePeriodSeconds()) + terminationGracePeriodSlack
+	return &seconds
+}
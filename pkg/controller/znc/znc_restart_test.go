@@ -0,0 +1,132 @@
+package znc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileRestartsGracefully(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	gracePeriod := int32(60)
+	cr.Spec.Restart = zncv1.ZNCSpecRestart{Notice: "brb", GracePeriodSeconds: &gracePeriod}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	server := newFakeZNCForCR(t, r, cr)
+	defer server.Close()
+	var addresses []string
+	r.dial = dialFakeZNC(server, &addresses)
+	statefulSet := &appsv1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	checksum := statefulSet.Spec.Template.Annotations[checksumAnnotation]
+	createRunningPod(t, r, cr, checksum)
+
+	instance := &zncv1.ZNC{}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	// Users added with a hashed password cannot be added at runtime.
+	instance.Spec.Config.Users = append(instance.Spec.Config.Users, zncv1.ZNCSpecConfigUser{Name: "richardroe", Pass: makepassSecret})
+	if err := r.client.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+
+	// The restart is announced and the pod is kept for the grace period.
+	for i := 0; i < 2; i++ {
+		result, err := r.Reconcile(request)
+		if err != nil {
+			t.Fatal("reconciling caused an unexpected error", err)
+		}
+		if result.RequeueAfter <= 0 || result.RequeueAfter > time.Minute {
+			t.Errorf("expected the update to be scheduled after the grace period, got %v", result)
+		}
+	}
+	if expected, actual := []string{"*status Broadcast brb"}, requestCommands(server.Requests()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected commands %v, got %v", expected, actual)
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	if statefulSet.Spec.Template.Annotations[checksumAnnotation] != checksum {
+		t.Error("expected the pod not to be replaced during the grace period")
+	}
+
+	// Once the grace period has passed, ZNC is shut down and the pod is replaced.
+	pod := &corev1.Pod{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Name + "-0", Namespace: cr.Namespace}, pod); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := time.Parse(time.RFC3339, pod.Annotations[restartAnnouncedAnnotation]); err != nil {
+		t.Errorf("expected the pod to record the announcement, got %v", pod.Annotations)
+	}
+	pod.Annotations[restartAnnouncedAnnotation] = time.Now().Add(-time.Minute).Format(time.RFC3339)
+	if err := r.client.Update(context.TODO(), pod); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatal("reconciling caused an unexpected error", err)
+	}
+	if expected, actual := []string{"*status Broadcast brb", "*status Shutdown"}, requestCommands(server.Requests()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected commands %v, got %v", expected, actual)
+	}
+	if err := r.client.Get(context.TODO(), request.NamespacedName, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	template := statefulSet.Spec.Template
+	if template.Annotations[checksumAnnotation] == checksum {
+		t.Error("expected the pod to be replaced")
+	}
+	container := template.Spec.Containers[0]
+	if container.Lifecycle == nil || container.Lifecycle.PreStop == nil || !strings.Contains(container.Lifecycle.PreStop.Exec.Command[2], "then sleep 60") {
+		t.Errorf("expected the preStop hook to wait for the grace period, got %+v", container.Lifecycle)
+	}
+	if seconds := template.Spec.TerminationGracePeriodSeconds; seconds == nil || *seconds != 90 {
+		t.Errorf("expected the termination grace period to cover the preStop hook, got %v", seconds)
+	}
+}
+
+func TestLifecycleForCR(t *testing.T) {
+	cr := newTestZNC()
+	lifecycle := lifecycleForCR(cr)
+	if lifecycle == nil || lifecycle.PreStop == nil || lifecycle.PreStop.Exec == nil {
+		t.Fatalf("expected a preStop hook, got %+v", lifecycle)
+	}
+	script := lifecycle.PreStop.Exec.Command[2]
+	for _, expected := range []string{"PASS znc-operator:%s", "PRIVMSG *status :Broadcast %s", "nc -w 5 127.0.0.1 6667", "then sleep 30"} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("expected the preStop hook to contain %q, got %s", expected, script)
+		}
+	}
+	template := newPodTemplateForCR(cr, &cr.Spec, "", &generatedSecret{})
+	if seconds := template.Spec.TerminationGracePeriodSeconds; seconds == nil || *seconds != 60 {
+		t.Errorf("expected the termination grace period to cover the preStop hook, got %v", seconds)
+	}
+	if env := template.Spec.Containers[0].Env; len(env) != 2 || env[1].Value != zncv1.RestartNoticeDefault {
+		t.Errorf("expected the restart notice to be passed to the preStop hook, got %+v", env)
+	}
+
+	cr.Spec.ConfigUpdateStrategy = zncv1.ZNCConfigUpdateStrategyRestart
+	if lifecycle := lifecycleForCR(cr); lifecycle == nil {
+		t.Error("expected a preStop hook with the Restart strategy")
+	}
+	cr.Spec.Config.Listeners = []zncv1.ZNCSpecConfigListener{{Name: "irc", Port: 6697, SSL: true}}
+	if lifecycle := lifecycleForCR(cr); lifecycle != nil {
+		t.Errorf("expected no preStop hook without a plain-text IRC listener, got %+v", lifecycle)
+	}
+}
@@ -0,0 +1,281 @@
+package znc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+	"znc-operator/pkg/zncconf"
+
+	"github.com/go-logr/logr"
+	"github.com/mitchellh/hashstructure"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// getSecretValue returns the value stored under the referenced key of a Secret in the given namespace.
+func (r *ReconcileZNC) getSecretValue(namespace string, ref *zncv1.SecretKeyRef) (string, error) {
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: namespace}, secret); err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %v", ref.Name, err)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s does not contain key %s", ref.Name, ref.Key)
+	}
+	return string(value), nil
+}
+
+// getSecretPassword returns the password stored under the referenced key of a Secret in the given namespace.
+func (r *ReconcileZNC) getSecretPassword(namespace string, ref *zncv1.SecretKeyRef) (string, error) {
+	password, err := r.getSecretValue(namespace, ref)
+	// Secrets created from files commonly carry a trailing newline, which is never part of the password.
+	return strings.TrimRight(password, "\r\n"), err
+}
+
+// resolveSpec returns a copy of the spec of the given ZNC instance in which all references to Secrets have been
+// replaced by the values they point to and all derived settings have been applied, so that the result can be rendered
+// into a ZNC configuration. Previous holds the previously rendered passwords keyed by user name, which are reused for
+// unchanged plaintext passwords.
+func (r *ReconcileZNC) resolveSpec(cr *zncv1.ZNC, previous map[string]*zncv1.ZNCSpecConfigUserPass) (*zncv1.ZNCSpec, error) {
+	spec := cr.Spec.DeepCopy()
+	for i := range spec.Config.Users {
+		user := &spec.Config.Users[i]
+		if err := r.resolveServerPasswords(cr.Namespace, user); err != nil {
+			return nil, err
+		}
+		if user.PasswordSecretRef == nil {
+			continue
+		}
+		password, err := r.getSecretPassword(cr.Namespace, user.PasswordSecretRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve password of user %s: %v", user.Name, err)
+		}
+		pass, ok := parsePass(password)
+		if !ok {
+			if pass, err = hashPlaintextPass(password, user.Password, previous[user.Name]); err != nil {
+				return nil, fmt.Errorf("failed to hash password of user %s: %v", user.Name, err)
+			}
+		}
+		user.Password = pass
+	}
+	applyWebSpec(spec)
+	return spec, nil
+}
+
+// renderedPasses returns the passwords of all users in the configuration last rendered for the given ZNC instance,
+// keyed by user name.
+func (r *ReconcileZNC) renderedPasses(cr *zncv1.ZNC) (map[string]*zncv1.ZNCSpecConfigUserPass, error) {
+	zncConf, err := r.appliedConfiguration(cr)
+	if err != nil || len(zncConf) == 0 {
+		return nil, err
+	}
+	root, err := zncconf.Parse(strings.NewReader(zncConf))
+	if err != nil {
+		return nil, err
+	}
+	passes := map[string]*zncv1.ZNCSpecConfigUserPass{}
+	users, _ := nestedBlocks(root, "User")
+	for _, user := range users {
+		if pass, ok := parsePass(lastValue(settingValues(user, "pass"))); ok {
+			passes[user.Name] = pass
+		}
+	}
+	return passes, nil
+}
+
+// resolveServerPasswords replaces the references to Secrets of the server passwords of all networks of the given user
+// by the passwords they point to. The rendered passwords are only stored in the generated Secret, as the copy of the
+// configuration in the ConfigMap is redacted.
+func (r *ReconcileZNC) resolveServerPasswords(namespace string, user *zncv1.ZNCSpecConfigUser) error {
+	for i := range user.Networks {
+		network := &user.Networks[i]
+		for j := range network.Servers {
+			server := &network.Servers[j]
+			if server.PasswordSecretRef == nil {
+				continue
+			}
+			password, err := r.getSecretPassword(namespace, server.PasswordSecretRef)
+			if err != nil {
+				return fmt.Errorf("failed to resolve password of server %s of network %s of user %s: %v", server.Host, network.Name, user.Name, err)
+			}
+			server.Password = password
+			server.PasswordSecretRef = nil
+		}
+	}
+	return nil
+}
+
+// referencedSecrets returns the names of all Secrets referenced by the given ZNC instance.
+func referencedSecrets(cr *zncv1.ZNC) map[string]bool {
+	names := map[string]bool{}
+	if secretName := tlsSecretNameForCR(cr); len(secretName) > 0 {
+		names[secretName] = true
+	}
+	for _, user := range cr.Spec.Config.Users {
+		if user.PasswordSecretRef != nil {
+			names[user.PasswordSecretRef.Name] = true
+		}
+		for _, network := range user.Networks {
+			if sasl := network.SASL; sasl != nil {
+				if sasl.PasswordSecretRef != nil {
+					names[sasl.PasswordSecretRef.Name] = true
+				}
+				if len(sasl.CertificateSecretName) > 0 {
+					names[sasl.CertificateSecretName] = true
+				}
+			}
+			if network.NickServ != nil && network.NickServ.PasswordSecretRef != nil {
+				names[network.NickServ.PasswordSecretRef.Name] = true
+			}
+			for _, server := range network.Servers {
+				if server.PasswordSecretRef != nil {
+					names[server.PasswordSecretRef.Name] = true
+				}
+			}
+			for _, perform := range network.Perform {
+				if perform.CommandSecretRef != nil {
+					names[perform.CommandSecretRef.Name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// secretMapper maps Secrets to reconcile requests for all ZNC instances in the same namespace that reference them.
+type secretMapper struct {
+	client client.Client
+}
+
+// Map implements handler.Mapper.
+func (m *secretMapper) Map(obj handler.MapObject) []reconcile.Request {
+	zncs := &zncv1.ZNCList{}
+	if err := m.client.List(context.TODO(), zncs, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		log.Error(err, "Failed to list ZNC instances", "Namespace", obj.Meta.GetNamespace())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range zncs.Items {
+		if referencedSecrets(&zncs.Items[i])[obj.Meta.GetName()] {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      zncs.Items[i].Name,
+				Namespace: zncs.Items[i].Namespace,
+			}})
+		}
+	}
+	return requests
+}
+
+// generatedSecretNameForCR returns the name of the Secret holding the sensitive files generated for the given ZNC
+// instance. It is distinct from the name of the instance, which users are likely to choose for their own Secrets.
+func generatedSecretNameForCR(cr *zncv1.ZNC) string {
+	return cr.Name + "-generated"
+}
+
+// configFilePath is the path of the configuration file relative to the data directory. It is stored in the generated
+// Secret, as it contains password hashes.
+const configFilePath = "configs/znc.conf"
+
+// generatedSecret describes the reconciled Secret holding the generated files of a ZNC instance.
+type generatedSecret struct {
+	// checksum is the checksum of the files.
+	checksum string
+	// items maps the keys of the Secret to the paths of the files relative to the data directory.
+	items []corev1.KeyToPath
+}
+
+// invalidSecretKeyCharRegexp matches the characters that are not allowed in keys of Secrets.
+var invalidSecretKeyCharRegexp = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// secretKeyForPath returns the key of the generated Secret the file at the given path relative to the data directory
+// is stored under. Paths that are valid keys are used as they are, e.g. "znc.pem". Other paths are made valid and
+// suffixed with their hash, so that distinct paths never share a key.
+func secretKeyForPath(path string) string {
+	if len(validation.IsConfigMapKey(path)) == 0 {
+		return path
+	}
+	key := invalidSecretKeyCharRegexp.ReplaceAllString(path, "_")
+	if len(key) > 200 {
+		key = key[:200]
+	}
+	sum := sha256.Sum256([]byte(path))
+	return key + "-" + hex.EncodeToString(sum[:4])
+}
+
+// newSecretForCR returns a Secret containing the sensitive files that are copied into the data directory of the given
+// ZNC instance on startup. The files are keyed by their path relative to the data directory.
+func newSecretForCR(cr *zncv1.ZNC, files map[string][]byte) *corev1.Secret {
+	data := make(map[string][]byte, len(files))
+	for path, content := range files {
+		data[secretKeyForPath(path)] = content
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generatedSecretNameForCR(cr),
+			Namespace: cr.Namespace,
+			Labels:    labelsForCR(cr),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+}
+
+// reconcileSecret creates or updates the Secret containing the sensitive files of the given ZNC instance, which are
+// keyed by their path relative to the data directory.
+func (r *ReconcileZNC) reconcileSecret(reqLogger logr.Logger, instance *zncv1.ZNC, files map[string][]byte) (*generatedSecret, error) {
+	secret := newSecretForCR(instance, files)
+	if err := controllerutil.SetControllerReference(instance, secret, r.scheme); err != nil {
+		return nil, err
+	}
+	// Changes of the configuration are tracked by its own checksum, as some of them are applied to the running pod.
+	checksummed := make(map[string][]byte, len(files))
+	for path, content := range files {
+		if path != configFilePath {
+			checksummed[path] = content
+		}
+	}
+	hash, err := hashstructure.Hash(checksummed, nil)
+	if err != nil {
+		return nil, err
+	}
+	found := &corev1.Secret{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, found)
+	if errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+		if err := r.client.Create(context.TODO(), secret); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if !reflect.DeepEqual(found.Data, secret.Data) && (len(found.Data) > 0 || len(secret.Data) > 0) {
+		reqLogger.Info("Updating ZNC Secret")
+		found.Data = secret.Data
+		if err := r.client.Update(context.TODO(), found); err != nil {
+			return nil, err
+		}
+	}
+
+	generated := &generatedSecret{checksum: strconv.FormatUint(hash, 10)}
+	for path := range files {
+		generated.items = append(generated.items, corev1.KeyToPath{Key: secretKeyForPath(path), Path: path})
+	}
+	sort.Slice(generated.items, func(i, j int) bool {
+		return generated.items[i].Path < generated.items[j].Path
+	})
+	return generated, nil
+}
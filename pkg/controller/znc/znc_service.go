@@ -0,0 +1,270 @@
+package znc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// managedAnnotationsAnnotation lists the keys of the annotations of a Service that have been set by the operator, so
+	// that annotations added by others, e.g. cloud controllers or external-dns, are retained.
+	managedAnnotationsAnnotation = "config.znc.in/managed-annotations"
+)
+
+// containerPortsForCR returns the container ports of the ZNC container, one for each listener.
+func containerPortsForCR(cr *zncv1.ZNC) []corev1.ContainerPort {
+	var ports []corev1.ContainerPort
+	for _, listener := range cr.Spec.Config.GetListeners() {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          listener.Name,
+			ContainerPort: listener.Port,
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+	return ports
+}
+
+// newServiceForCR returns a Service exposing all listeners of the given ZNC instance.
+func newServiceForCR(cr *zncv1.ZNC) *corev1.Service {
+	spec := cr.Spec.Service
+	serviceType := spec.GetType()
+	var ports []corev1.ServicePort
+	for _, containerPort := range containerPortsForCR(cr) {
+		port := corev1.ServicePort{
+			Name:       containerPort.Name,
+			Port:       containerPort.ContainerPort,
+			Protocol:   containerPort.Protocol,
+			TargetPort: intstr.FromString(containerPort.Name),
+		}
+		for _, override := range spec.Ports {
+			if override.Name != containerPort.Name {
+				continue
+			}
+			if override.Port != 0 {
+				port.Port = override.Port
+			}
+			if serviceType != corev1.ServiceTypeClusterIP {
+				port.NodePort = override.NodePort
+			}
+		}
+		ports = append(ports, port)
+	}
+	var annotations map[string]string
+	if len(spec.Annotations) > 0 {
+		annotations = map[string]string{}
+		var keys []string
+		for key, value := range spec.Annotations {
+			annotations[key] = value
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		annotations[managedAnnotationsAnnotation] = strings.Join(keys, ",")
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cr.Name,
+			Namespace:   cr.Namespace,
+			Labels:      labelsForCR(cr),
+			Annotations: annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     serviceType,
+			Ports:    ports,
+			Selector: labelsForCR(cr),
+		},
+	}
+	if serviceType != corev1.ServiceTypeClusterIP {
+		service.Spec.ExternalTrafficPolicy = spec.ExternalTrafficPolicy
+	}
+	if serviceType == corev1.ServiceTypeLoadBalancer {
+		service.Spec.LoadBalancerSourceRanges = spec.LoadBalancerSourceRanges
+	}
+	return service
+}
+
+// headlessServiceNameForCR returns the name of the headless Service governing the StatefulSet of the given ZNC
+// instance.
+func headlessServiceNameForCR(cr *zncv1.ZNC) string {
+	return cr.Name + "-headless"
+}
+
+// newHeadlessServiceForCR returns the headless Service governing the StatefulSet of the given ZNC instance, which
+// provides the DNS name of its pod.
+func newHeadlessServiceForCR(cr *zncv1.ZNC) *corev1.Service {
+	var ports []corev1.ServicePort
+	for _, containerPort := range containerPortsForCR(cr) {
+		ports = append(ports, corev1.ServicePort{
+			Name:       containerPort.Name,
+			Port:       containerPort.ContainerPort,
+			Protocol:   containerPort.Protocol,
+			TargetPort: intstr.FromString(containerPort.Name),
+		})
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      headlessServiceNameForCR(cr),
+			Namespace: cr.Namespace,
+			Labels:    labelsForCR(cr),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeClusterIP,
+			ClusterIP: corev1.ClusterIPNone,
+			Ports:     ports,
+			Selector:  labelsForCR(cr),
+		},
+	}
+}
+
+// mergeServiceAnnotations returns the existing annotations of a Service with the desired ones applied. Annotations the
+// operator has set before, which are listed in managedAnnotationsAnnotation, are removed if they are no longer
+// desired; all others are retained.
+func mergeServiceAnnotations(existing map[string]string, desired map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range existing {
+		merged[key] = value
+	}
+	for _, key := range strings.Split(existing[managedAnnotationsAnnotation], ",") {
+		delete(merged, key)
+	}
+	delete(merged, managedAnnotationsAnnotation)
+	for key, value := range desired {
+		merged[key] = value
+	}
+	if len(merged) == 0 && existing == nil {
+		return nil
+	}
+	return merged
+}
+
+// mergeServiceSpec applies the desired Service spec to the existing one, retaining values that have been allocated or
+// defaulted by the API server, such as the cluster IP or node ports.
+func mergeServiceSpec(existing *corev1.ServiceSpec, desired *corev1.ServiceSpec) {
+	if desired.Type == corev1.ServiceTypeClusterIP {
+		existing.ExternalTrafficPolicy = ""
+	} else if len(desired.ExternalTrafficPolicy) > 0 {
+		existing.ExternalTrafficPolicy = desired.ExternalTrafficPolicy
+	} else {
+		// The API server defaults the policy to Cluster, which also applies once the policy is no longer set.
+		existing.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+	}
+	ports := make([]corev1.ServicePort, len(desired.Ports))
+	for i, port := range desired.Ports {
+		if port.NodePort == 0 && desired.Type != corev1.ServiceTypeClusterIP {
+			for _, existingPort := range existing.Ports {
+				if existingPort.Name == port.Name {
+					port.NodePort = existingPort.NodePort
+				}
+			}
+		}
+		ports[i] = port
+	}
+	existing.Type = desired.Type
+	existing.Ports = ports
+	existing.Selector = desired.Selector
+	existing.LoadBalancerSourceRanges = desired.LoadBalancerSourceRanges
+}
+
+// endpointsForService returns the addresses the listeners exposed by the given Service can be reached at.
+func endpointsForService(service *corev1.Service) []zncv1.ZNCEndpoint {
+	var endpoints []zncv1.ZNCEndpoint
+	host := fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace)
+	for _, port := range service.Spec.Ports {
+		endpoints = append(endpoints, zncv1.ZNCEndpoint{
+			Name:     port.Name,
+			Host:     host,
+			Port:     port.Port,
+			NodePort: port.NodePort,
+		})
+	}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		host := ingress.IP
+		if len(host) == 0 {
+			host = ingress.Hostname
+		}
+		for _, port := range service.Spec.Ports {
+			endpoints = append(endpoints, zncv1.ZNCEndpoint{
+				Name: port.Name,
+				Host: host,
+				Port: port.Port,
+			})
+		}
+	}
+	return endpoints
+}
+
+// reconcileService creates or updates the Service exposing the given ZNC instance.
+func (r *ReconcileZNC) reconcileService(reqLogger logr.Logger, instance *zncv1.ZNC, status *zncv1.ZNCStatus) error {
+	service := newServiceForCR(instance)
+	if err := controllerutil.SetControllerReference(instance, service, r.scheme); err != nil {
+		return err
+	}
+	found := &corev1.Service{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: service.Namespace}, found)
+	if err == nil {
+		updated := found.DeepCopy()
+		updated.Labels = service.Labels
+		updated.Annotations = mergeServiceAnnotations(found.Annotations, service.Annotations)
+		mergeServiceSpec(&updated.Spec, &service.Spec)
+		if !reflect.DeepEqual(updated, found) {
+			reqLogger.Info("Updating ZNC Service", "Service.Namespace", found.Namespace, "Service.Name", found.Name)
+			if err := r.client.Update(context.TODO(), updated); err != nil {
+				return err
+			}
+			found = updated
+		}
+	} else if errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new Service", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+		if err := r.client.Create(context.TODO(), service); err != nil {
+			return err
+		}
+		found = service
+	} else {
+		return err
+	}
+	status.ServiceName = found.Name
+	status.Endpoints = endpointsForService(found)
+	return nil
+}
+
+// reconcileHeadlessService creates or updates the headless Service governing the StatefulSet of the given ZNC
+// instance, or deletes it if the instance runs as Deployment.
+func (r *ReconcileZNC) reconcileHeadlessService(reqLogger logr.Logger, instance *zncv1.ZNC) error {
+	name := types.NamespacedName{Name: headlessServiceNameForCR(instance), Namespace: instance.Namespace}
+	if instance.Spec.GetWorkload() != zncv1.ZNCWorkloadKindStatefulSet {
+		return r.deleteIfControlled(reqLogger, instance, name, &corev1.Service{})
+	}
+	service := newHeadlessServiceForCR(instance)
+	if err := controllerutil.SetControllerReference(instance, service, r.scheme); err != nil {
+		return err
+	}
+	found := &corev1.Service{}
+	err := r.client.Get(context.TODO(), name, found)
+	if errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new Service", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+		return r.client.Create(context.TODO(), service)
+	} else if err != nil {
+		return err
+	}
+	updated := found.DeepCopy()
+	updated.Labels = service.Labels
+	updated.Spec.Ports = service.Spec.Ports
+	updated.Spec.Selector = service.Spec.Selector
+	if !reflect.DeepEqual(updated, found) {
+		reqLogger.Info("Updating headless Service", "Service.Namespace", found.Namespace, "Service.Name", found.Name)
+		return r.client.Update(context.TODO(), updated)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package znc
+
+import (
+	"reflect"
+	"testing"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewServiceForCR(t *testing.T) {
+	cr := &zncv1.ZNC{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-znc", Namespace: "default"},
+		Spec: zncv1.ZNCSpec{
+			Service: zncv1.ZNCSpecService{
+				Type:                     corev1.ServiceTypeLoadBalancer,
+				ExternalTrafficPolicy:    corev1.ServiceExternalTrafficPolicyTypeLocal,
+				LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+				Ports: []zncv1.ZNCSpecServicePort{
+					{Name: "irc", Port: 6697, NodePort: 30697},
+				},
+			},
+		},
+	}
+	service := newServiceForCR(cr)
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer || service.Spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeLocal {
+		t.Errorf("unexpected service spec %+v", service.Spec)
+	}
+	if len(service.Spec.Ports) != 2 {
+		t.Fatalf("expected 2 ports, got %d", len(service.Spec.Ports))
+	}
+	if irc := service.Spec.Ports[0]; irc.Name != "irc" || irc.Port != 6697 || irc.NodePort != 30697 || irc.TargetPort.StrVal != "irc" {
+		t.Errorf("unexpected irc port %+v", irc)
+	}
+	if web := service.Spec.Ports[1]; web.Name != "web" || web.Port != 8080 || web.NodePort != 0 {
+		t.Errorf("unexpected web port %+v", web)
+	}
+
+	cr.Spec.Service.Type = ""
+	service = newServiceForCR(cr)
+	if service.Spec.Type != corev1.ServiceTypeClusterIP || service.Spec.ExternalTrafficPolicy != "" || service.Spec.LoadBalancerSourceRanges != nil {
+		t.Errorf("unexpected service spec %+v", service.Spec)
+	}
+	if service.Spec.Ports[0].NodePort != 0 {
+		t.Error("expected node ports to be omitted for ClusterIP services")
+	}
+}
+
+func TestMergeServiceSpec(t *testing.T) {
+	existing := corev1.ServiceSpec{
+		Type:                  corev1.ServiceTypeNodePort,
+		ClusterIP:             "10.96.0.10",
+		ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeCluster,
+		Ports: []corev1.ServicePort{
+			{Name: "irc", Port: 6667, NodePort: 31667},
+			{Name: "web", Port: 8080, NodePort: 31080},
+		},
+	}
+	desired := corev1.ServiceSpec{
+		Type: corev1.ServiceTypeNodePort,
+		Ports: []corev1.ServicePort{
+			{Name: "irc", Port: 6697},
+			{Name: "web", Port: 8080, NodePort: 30080},
+		},
+	}
+	mergeServiceSpec(&existing, &desired)
+	if existing.ClusterIP != "10.96.0.10" || existing.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeCluster {
+		t.Errorf("expected allocated and defaulted values to be retained, got %+v", existing)
+	}
+	if existing.Ports[0].Port != 6697 || existing.Ports[0].NodePort != 31667 || existing.Ports[1].NodePort != 30080 {
+		t.Errorf("unexpected ports %+v", existing.Ports)
+	}
+
+	existing.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
+	mergeServiceSpec(&existing, &desired)
+	if existing.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeCluster {
+		t.Errorf("expected the external traffic policy to be reset, got %s", existing.ExternalTrafficPolicy)
+	}
+
+	mergeServiceSpec(&existing, &corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, Ports: []corev1.ServicePort{{Name: "irc", Port: 6667}}})
+	if existing.ExternalTrafficPolicy != "" || existing.Ports[0].NodePort != 0 {
+		t.Errorf("expected node port settings to be dropped for ClusterIP services, got %+v", existing)
+	}
+}
+
+func TestMergeServiceAnnotations(t *testing.T) {
+	cr := &zncv1.ZNC{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-znc", Namespace: "default"},
+		Spec: zncv1.ZNCSpec{
+			Service: zncv1.ZNCSpecService{
+				Annotations: map[string]string{"a": "1", "b": "2"},
+			},
+		},
+	}
+	existing := newServiceForCR(cr).Annotations
+	existing["metallb.universe.tf/ip-allocated-from-pool"] = "default"
+
+	cr.Spec.Service.Annotations = map[string]string{"b": "3"}
+	merged := mergeServiceAnnotations(existing, newServiceForCR(cr).Annotations)
+	expected := map[string]string{"b": "3", "metallb.universe.tf/ip-allocated-from-pool": "default", managedAnnotationsAnnotation: "b"}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, merged)
+	}
+
+	cr.Spec.Service.Annotations = nil
+	merged = mergeServiceAnnotations(merged, newServiceForCR(cr).Annotations)
+	expected = map[string]string{"metallb.universe.tf/ip-allocated-from-pool": "default"}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, merged)
+	}
+}
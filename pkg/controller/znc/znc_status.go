@@ -0,0 +1,62 @@
+package znc
+
+import (
+	"fmt"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podHealth evaluates the health of the given ZNC pod and returns the resulting condition status together with a
+// reason and a human readable message.
+func podHealth(pod *corev1.Pod) (status corev1.ConditionStatus, reason string, message string) {
+	if pod.DeletionTimestamp != nil {
+		return corev1.ConditionFalse, "PodTerminating", fmt.Sprintf("Pod %s is being terminated", pod.Name)
+	}
+	switch pod.Status.Phase {
+	case corev1.PodFailed:
+		return corev1.ConditionFalse, "PodFailed", fmt.Sprintf("Pod %s has failed: %s", pod.Name, pod.Status.Message)
+	case corev1.PodSucceeded:
+		return corev1.ConditionFalse, "PodCompleted", fmt.Sprintf("Pod %s has terminated", pod.Name)
+	case corev1.PodRunning:
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason == "CrashLoopBackOff" {
+				return corev1.ConditionFalse, "CrashLoopBackOff", fmt.Sprintf("Container %s of pod %s is crash-looping", containerStatus.Name, pod.Name)
+			}
+			if !containerStatus.Ready {
+				return corev1.ConditionFalse, "ContainersNotReady", fmt.Sprintf("Container %s of pod %s is not ready", containerStatus.Name, pod.Name)
+			}
+		}
+		return corev1.ConditionTrue, "PodReady", fmt.Sprintf("Pod %s is running", pod.Name)
+	default:
+		for _, containerStatus := range pod.Status.InitContainerStatuses {
+			if containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason == "CrashLoopBackOff" {
+				return corev1.ConditionFalse, "CrashLoopBackOff", fmt.Sprintf("Init container %s of pod %s is crash-looping", containerStatus.Name, pod.Name)
+			}
+		}
+		return corev1.ConditionFalse, "PodPending", fmt.Sprintf("Pod %s is pending", pod.Name)
+	}
+}
+
+// updateReadiness derives the Ready condition and the phase from the remaining conditions of the given status.
+// A phase that has been explicitly set to Restarting or Failed during reconciliation is preserved.
+func updateReadiness(status *zncv1.ZNCStatus) {
+	switch {
+	case !status.IsConditionTrue(zncv1.ZNCConditionConfigRendered):
+		status.SetCondition(zncv1.ZNCConditionReady, corev1.ConditionFalse, "ConfigNotRendered", "The ZNC configuration could not be rendered")
+		status.Phase = zncv1.ZNCPhaseFailed
+	case !status.IsConditionTrue(zncv1.ZNCConditionPodHealthy):
+		message := "The ZNC pod is not healthy"
+		if podHealthy := status.GetCondition(zncv1.ZNCConditionPodHealthy); podHealthy != nil {
+			message = podHealthy.Message
+		}
+		status.SetCondition(zncv1.ZNCConditionReady, corev1.ConditionFalse, "PodNotHealthy", message)
+		if status.Phase != zncv1.ZNCPhaseRestarting && status.Phase != zncv1.ZNCPhaseFailed {
+			status.Phase = zncv1.ZNCPhasePending
+		}
+	default:
+		status.SetCondition(zncv1.ZNCConditionReady, corev1.ConditionTrue, "Ready", "The ZNC instance is ready")
+		status.Phase = zncv1.ZNCPhaseRunning
+	}
+}
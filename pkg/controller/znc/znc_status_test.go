@@ -0,0 +1,85 @@
+package znc
+
+import (
+	"testing"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodHealth(t *testing.T) {
+	tests := []struct {
+		name           string
+		pod            corev1.Pod
+		expectedStatus corev1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "pending",
+			pod:            corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}},
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "PodPending",
+		},
+		{
+			name: "running and ready",
+			pod: corev1.Pod{Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "znc", Ready: true}},
+			}},
+			expectedStatus: corev1.ConditionTrue,
+			expectedReason: "PodReady",
+		},
+		{
+			name: "crash loop",
+			pod: corev1.Pod{Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "znc",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				}},
+			}},
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "CrashLoopBackOff",
+		},
+		{
+			name: "terminating",
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &metav1.Time{}},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			},
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "PodTerminating",
+		},
+	}
+	for _, test := range tests {
+		status, reason, _ := podHealth(&test.pod)
+		if status != test.expectedStatus || reason != test.expectedReason {
+			t.Errorf("%s: expected %s/%s, got %s/%s", test.name, test.expectedStatus, test.expectedReason, status, reason)
+		}
+	}
+}
+
+func TestUpdateReadiness(t *testing.T) {
+	status := &zncv1.ZNCStatus{}
+	status.SetCondition(zncv1.ZNCConditionConfigRendered, corev1.ConditionTrue, "Rendered", "")
+	status.SetCondition(zncv1.ZNCConditionPodHealthy, corev1.ConditionTrue, "PodReady", "")
+	updateReadiness(status)
+	if !status.IsConditionTrue(zncv1.ZNCConditionReady) || status.Phase != zncv1.ZNCPhaseRunning {
+		t.Errorf("expected ready and running instance, got phase %s", status.Phase)
+	}
+
+	status.Phase = zncv1.ZNCPhaseRestarting
+	status.SetCondition(zncv1.ZNCConditionPodHealthy, corev1.ConditionFalse, "ConfigChanged", "")
+	updateReadiness(status)
+	if status.IsConditionTrue(zncv1.ZNCConditionReady) || status.Phase != zncv1.ZNCPhaseRestarting {
+		t.Errorf("expected restarting instance, got phase %s", status.Phase)
+	}
+
+	status.Phase = ""
+	status.SetCondition(zncv1.ZNCConditionConfigRendered, corev1.ConditionFalse, "RenderFailed", "")
+	updateReadiness(status)
+	if status.IsConditionTrue(zncv1.ZNCConditionReady) || status.Phase != zncv1.ZNCPhaseFailed {
+		t.Errorf("expected failed instance, got phase %s", status.Phase)
+	}
+}
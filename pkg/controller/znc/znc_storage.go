@@ -0,0 +1,112 @@
+package znc
+
+import (
+	"context"
+	"reflect"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// claimNameForCR returns the name of the PersistentVolumeClaim holding the data directory of the given ZNC instance.
+func claimNameForCR(cr *zncv1.ZNC) string {
+	if cr.Spec.Storage != nil && len(cr.Spec.Storage.ExistingClaim) > 0 {
+		return cr.Spec.Storage.ExistingClaim
+	}
+	return cr.Name + "-data"
+}
+
+// dataVolumeSourceForCR returns the volume source of the data directory of the given ZNC instance.
+func dataVolumeSourceForCR(cr *zncv1.ZNC) corev1.VolumeSource {
+	if cr.Spec.Storage == nil {
+		return corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		}
+	}
+	return corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: claimNameForCR(cr),
+		},
+	}
+}
+
+// newPersistentVolumeClaimForCR returns a PersistentVolumeClaim for the data directory of the given ZNC instance.
+func newPersistentVolumeClaimForCR(cr *zncv1.ZNC) *corev1.PersistentVolumeClaim {
+	storage := cr.Spec.Storage
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      claimNameForCR(cr),
+			Namespace: cr.Namespace,
+			Labels:    labelsForCR(cr),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      storage.GetAccessModes(),
+			StorageClassName: storage.StorageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: storage.GetSize(),
+				},
+			},
+		},
+	}
+}
+
+// setClaimOwnership adds or removes the controller reference of the given PersistentVolumeClaim depending on the
+// reclaim policy of the ZNC instance, so that the claim is only garbage collected if the policy says so.
+func (r *ReconcileZNC) setClaimOwnership(cr *zncv1.ZNC, claim *corev1.PersistentVolumeClaim) error {
+	if cr.Spec.Storage.GetReclaimPolicy() == zncv1.ZNCStorageReclaimPolicyDelete {
+		return controllerutil.SetControllerReference(cr, claim, r.scheme)
+	}
+	var ownerReferences []metav1.OwnerReference
+	for _, ownerReference := range claim.OwnerReferences {
+		if ownerReference.UID != cr.UID {
+			ownerReferences = append(ownerReferences, ownerReference)
+		}
+	}
+	claim.OwnerReferences = ownerReferences
+	return nil
+}
+
+// reconcileStorage creates or updates the PersistentVolumeClaim holding the data directory of the given ZNC instance.
+// Nothing needs to be done if the data directory is ephemeral or stored on an existing claim.
+func (r *ReconcileZNC) reconcileStorage(reqLogger logr.Logger, instance *zncv1.ZNC) error {
+	if instance.Spec.Storage == nil || len(instance.Spec.Storage.ExistingClaim) > 0 {
+		return nil
+	}
+	claim := newPersistentVolumeClaimForCR(instance)
+	found := &corev1.PersistentVolumeClaim{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: claim.Name, Namespace: claim.Namespace}, found)
+	if errors.IsNotFound(err) {
+		if err := r.setClaimOwnership(instance, claim); err != nil {
+			return err
+		}
+		reqLogger.Info("Creating a new PersistentVolumeClaim", "PersistentVolumeClaim.Namespace", claim.Namespace, "PersistentVolumeClaim.Name", claim.Name)
+		return r.client.Create(context.TODO(), claim)
+	} else if err != nil {
+		return err
+	}
+
+	// Apart from the requested size, which can only grow, the spec of a PersistentVolumeClaim is immutable.
+	updated := found.DeepCopy()
+	if err := r.setClaimOwnership(instance, updated); err != nil {
+		return err
+	}
+	size := instance.Spec.Storage.GetSize()
+	if current, ok := updated.Spec.Resources.Requests[corev1.ResourceStorage]; !ok || size.Cmp(current) > 0 {
+		if updated.Spec.Resources.Requests == nil {
+			updated.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		updated.Spec.Resources.Requests[corev1.ResourceStorage] = size
+	}
+	if !reflect.DeepEqual(found, updated) {
+		reqLogger.Info("Updating PersistentVolumeClaim", "PersistentVolumeClaim.Namespace", found.Namespace, "PersistentVolumeClaim.Name", found.Name)
+		return r.client.Update(context.TODO(), updated)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package znc
+
+import (
+	"context"
+	"testing"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := zncv1.SchemeBuilder.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestReconcileStorage(t *testing.T) {
+	s := newTestScheme(t)
+	size := resource.MustParse("5Gi")
+	cr := &zncv1.ZNC{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-znc", Namespace: "default", UID: "0c7e0bd8-0f3c-4d4a-a3a8-6f3d0e1b5d0e"},
+		Spec: zncv1.ZNCSpec{
+			Storage: &zncv1.ZNCSpecStorage{Size: &size},
+		},
+	}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s}
+	reqLogger := logf.Log.WithName("test")
+
+	if err := r.reconcileStorage(reqLogger, cr); err != nil {
+		t.Fatal("reconciling storage caused an unexpected error", err)
+	}
+	claim := &corev1.PersistentVolumeClaim{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "example-znc-data", Namespace: "default"}, claim); err != nil {
+		t.Fatal("expected PersistentVolumeClaim to be created", err)
+	}
+	if len(claim.OwnerReferences) != 1 || claim.Spec.AccessModes[0] != corev1.ReadWriteOnce {
+		t.Errorf("unexpected PersistentVolumeClaim %+v", claim)
+	}
+	if requested := claim.Spec.Resources.Requests[corev1.ResourceStorage]; requested.Cmp(size) != 0 {
+		t.Errorf("expected size %s, got %s", size.String(), requested.String())
+	}
+
+	cr.Spec.Storage.ReclaimPolicy = zncv1.ZNCStorageReclaimPolicyRetain
+	if err := r.reconcileStorage(reqLogger, cr); err != nil {
+		t.Fatal("reconciling storage caused an unexpected error", err)
+	}
+	claim = &corev1.PersistentVolumeClaim{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "example-znc-data", Namespace: "default"}, claim); err != nil {
+		t.Fatal(err)
+	}
+	if len(claim.OwnerReferences) != 0 {
+		t.Errorf("expected retained PersistentVolumeClaim not to be owned, got %+v", claim.OwnerReferences)
+	}
+
+	cr.Spec.Storage.ExistingClaim = "existing"
+	if source := dataVolumeSourceForCR(cr); source.PersistentVolumeClaim == nil || source.PersistentVolumeClaim.ClaimName != "existing" {
+		t.Errorf("expected existing claim to be mounted, got %+v", source)
+	}
+}
@@ -0,0 +1,160 @@
+package znc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	"github.com/go-logr/logr"
+	"github.com/mitchellh/hashstructure"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// certificateGVK is the GroupVersionKind of cert-manager Certificates.
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1alpha2", Kind: "Certificate"}
+
+// tlsSecretNameForCR returns the name of the Secret holding the certificate of the given ZNC instance or an empty
+// string, if TLS has not been configured.
+func tlsSecretNameForCR(cr *zncv1.ZNC) string {
+	if cr.Spec.TLS == nil {
+		return ""
+	}
+	if len(cr.Spec.TLS.SecretName) > 0 {
+		return cr.Spec.TLS.SecretName
+	}
+	if cr.Spec.TLS.IssuerRef != nil {
+		return cr.Name + "-tls"
+	}
+	return ""
+}
+
+// tlsPEM returns the private key and certificate chain from the given Secret of type kubernetes.io/tls
+// combined into the single PEM file ZNC expects.
+func tlsPEM(secret *corev1.Secret) ([]byte, error) {
+	key, crt := secret.Data[corev1.TLSPrivateKeyKey], secret.Data[corev1.TLSCertKey]
+	if len(key) == 0 || len(crt) == 0 {
+		return nil, fmt.Errorf("secret %s does not contain both %s and %s", secret.Name, corev1.TLSPrivateKeyKey, corev1.TLSCertKey)
+	}
+	var pem bytes.Buffer
+	pem.Write(bytes.TrimSpace(key))
+	pem.WriteString("\n")
+	pem.Write(bytes.TrimSpace(crt))
+	pem.WriteString("\n")
+	return pem.Bytes(), nil
+}
+
+// tlsPEMForCR returns the PEM file assembled from the TLS Secret of the given ZNC instance.
+// If the Secret does not exist yet, e.g. because cert-manager has not issued the certificate yet, nil is returned.
+func (r *ReconcileZNC) tlsPEMForCR(reqLogger logr.Logger, instance *zncv1.ZNC) ([]byte, error) {
+	secretName := tlsSecretNameForCR(instance)
+	if len(secretName) == 0 {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: instance.Namespace}, secret)
+	if errors.IsNotFound(err) {
+		reqLogger.Info("Waiting for TLS Secret", "Secret.Namespace", instance.Namespace, "Secret.Name", secretName)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return tlsPEM(secret)
+}
+
+// newCertificateForCR returns a cert-manager Certificate for the given ZNC instance, which is valid for the DNS names
+// of its Service.
+func newCertificateForCR(cr *zncv1.ZNC) (*unstructured.Unstructured, error) {
+	issuerRef := cr.Spec.TLS.IssuerRef
+	dnsNames := []interface{}{
+		cr.Name,
+		fmt.Sprintf("%s.%s", cr.Name, cr.Namespace),
+		fmt.Sprintf("%s.%s.svc", cr.Name, cr.Namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", cr.Name, cr.Namespace),
+	}
+	for _, dnsName := range cr.Spec.TLS.DNSNames {
+		dnsNames = append(dnsNames, dnsName)
+	}
+	labels := map[string]interface{}{}
+	for key, value := range labelsForCR(cr) {
+		labels[key] = value
+	}
+	certificateSpec := map[string]interface{}{
+		"secretName": tlsSecretNameForCR(cr),
+		"dnsNames":   dnsNames,
+		"issuerRef": map[string]interface{}{
+			"name":  issuerRef.Name,
+			"kind":  issuerRef.GetKind(),
+			"group": issuerRef.GetGroup(),
+		},
+	}
+	specHash, err := hashstructure.Hash(certificateSpec, nil)
+	if err != nil {
+		return nil, err
+	}
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":      cr.Name,
+			"namespace": cr.Namespace,
+			"labels":    labels,
+		},
+		"spec": certificateSpec,
+	}}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetAnnotations(map[string]string{
+		specChecksumAnnotation: strconv.FormatUint(specHash, 10),
+	})
+	return certificate, nil
+}
+
+// reconcileCertificate creates or updates the cert-manager Certificate of the given ZNC instance, if an issuer has
+// been configured, and deletes it otherwise. Clusters without cert-manager are tolerated, as long as no Certificate is
+// requested.
+func (r *ReconcileZNC) reconcileCertificate(reqLogger logr.Logger, instance *zncv1.ZNC) error {
+	if instance.Spec.TLS == nil || instance.Spec.TLS.IssuerRef == nil {
+		obsolete := &unstructured.Unstructured{}
+		obsolete.SetGroupVersionKind(certificateGVK)
+		name := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+		if err := r.deleteIfControlled(reqLogger, instance, name, obsolete); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+		return nil
+	}
+	certificate, err := newCertificateForCR(instance)
+	if err != nil {
+		return err
+	}
+	if err := controllerutil.SetControllerReference(instance, certificate, r.scheme); err != nil {
+		return err
+	}
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(certificateGVK)
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: certificate.GetName(), Namespace: certificate.GetNamespace()}, found)
+	if errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new Certificate", "Certificate.Namespace", certificate.GetNamespace(), "Certificate.Name", certificate.GetName())
+		return r.client.Create(context.TODO(), certificate)
+	} else if err != nil {
+		return err
+	}
+	// The spec may be defaulted by cert-manager, hence it is compared like the spec of HTTPRoutes.
+	if found.GetAnnotations()[specChecksumAnnotation] != certificate.GetAnnotations()[specChecksumAnnotation] || !containsFields(found.Object["spec"], certificate.Object["spec"]) {
+		reqLogger.Info("Updating Certificate", "Certificate.Namespace", found.GetNamespace(), "Certificate.Name", found.GetName())
+		annotations := found.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[specChecksumAnnotation] = certificate.GetAnnotations()[specChecksumAnnotation]
+		found.SetAnnotations(annotations)
+		found.Object["spec"] = certificate.Object["spec"]
+		return r.client.Update(context.TODO(), found)
+	}
+	return nil
+}
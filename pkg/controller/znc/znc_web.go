@@ -0,0 +1,310 @@
+package znc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	"github.com/go-logr/logr"
+	"github.com/mitchellh/hashstructure"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// ingressClassAnnotation selects the Ingress controller that is supposed to serve an Ingress.
+	ingressClassAnnotation = "kubernetes.io/ingress.class"
+)
+
+// httpRouteGVK is the GroupVersionKind of Gateway API HTTPRoutes.
+var httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// exposesWeb returns whether the web interface is exposed through an Ingress or HTTPRoute according to the given spec.
+func exposesWeb(spec *zncv1.ZNCSpec) bool {
+	return spec.Web != nil && (spec.Web.Ingress != nil || spec.Web.HTTPRoute != nil)
+}
+
+// webListenerForSpec returns the index of the listener serving the web interface within the listeners of the given
+// spec or -1, if there is none.
+func webListenerForSpec(spec *zncv1.ZNCSpec) int {
+	listeners := spec.Config.GetListeners()
+	for i, listener := range listeners {
+		if spec.Web != nil && len(spec.Web.Listener) > 0 {
+			if listener.Name == spec.Web.Listener {
+				return i
+			}
+		} else if listener.GetAllowWeb() {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyWebSpec derives the settings required to serve the web interface behind a reverse proxy from the given spec:
+// the URIPrefix of the web listener is set to the exposed path and the reverse proxies are trusted, unless configured
+// explicitly.
+func applyWebSpec(spec *zncv1.ZNCSpec) {
+	if !exposesWeb(spec) {
+		return
+	}
+	if i := webListenerForSpec(spec); i >= 0 {
+		spec.Config.Listeners = spec.Config.GetListeners()
+		listener := &spec.Config.Listeners[i]
+		if path := spec.Web.GetPath(); len(listener.URIPrefix) == 0 && path != "/" {
+			listener.URIPrefix = strings.TrimSuffix(path, "/") + "/"
+		}
+	}
+	if len(spec.Config.TrustedProxies) == 0 {
+		spec.Config.TrustedProxies = append([]string{}, zncv1.TrustedProxiesDefault...)
+	}
+}
+
+// webServicePortForCR returns the port of the Service that exposes the web listener of the given ZNC instance.
+func webServicePortForCR(cr *zncv1.ZNC) (name string, port int32, err error) {
+	i := webListenerForSpec(&cr.Spec)
+	if i < 0 {
+		return "", 0, fmt.Errorf("no listener serves the web interface")
+	}
+	name = cr.Spec.Config.GetListeners()[i].Name
+	for _, servicePort := range newServiceForCR(cr).Spec.Ports {
+		if servicePort.Name == name {
+			return name, servicePort.Port, nil
+		}
+	}
+	return "", 0, fmt.Errorf("listener %s is not exposed by the Service", name)
+}
+
+// newIngressForCR returns an Ingress exposing the web interface of the given ZNC instance.
+func newIngressForCR(cr *zncv1.ZNC) (*networkingv1beta1.Ingress, error) {
+	spec := cr.Spec.Web.Ingress
+	portName, _, err := webServicePortForCR(cr)
+	if err != nil {
+		return nil, err
+	}
+	annotations := map[string]string{}
+	for key, value := range spec.Annotations {
+		annotations[key] = value
+	}
+	if len(spec.ClassName) > 0 {
+		annotations[ingressClassAnnotation] = spec.ClassName
+	}
+	ingress := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cr.Name,
+			Namespace:   cr.Namespace,
+			Labels:      labelsForCR(cr),
+			Annotations: annotations,
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: spec.Host,
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: []networkingv1beta1.HTTPIngressPath{
+								{
+									Path: cr.Spec.Web.GetPath(),
+									Backend: networkingv1beta1.IngressBackend{
+										ServiceName: cr.Name,
+										ServicePort: intstr.FromString(portName),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if len(spec.TLSSecret) > 0 {
+		ingress.Spec.TLS = []networkingv1beta1.IngressTLS{
+			{
+				Hosts:      []string{spec.Host},
+				SecretName: spec.TLSSecret,
+			},
+		}
+	}
+	return ingress, nil
+}
+
+// reconcileIngress creates, updates or deletes the Ingress exposing the web interface of the given ZNC instance.
+func (r *ReconcileZNC) reconcileIngress(reqLogger logr.Logger, instance *zncv1.ZNC) error {
+	name := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+	if instance.Spec.Web == nil || instance.Spec.Web.Ingress == nil {
+		return r.deleteIfControlled(reqLogger, instance, name, &networkingv1beta1.Ingress{})
+	}
+	ingress, err := newIngressForCR(instance)
+	if err != nil {
+		return err
+	}
+	if err := controllerutil.SetControllerReference(instance, ingress, r.scheme); err != nil {
+		return err
+	}
+	found := &networkingv1beta1.Ingress{}
+	err = r.client.Get(context.TODO(), name, found)
+	if errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new Ingress", "Ingress.Namespace", ingress.Namespace, "Ingress.Name", ingress.Name)
+		return r.client.Create(context.TODO(), ingress)
+	} else if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(found.Labels, ingress.Labels) || !reflect.DeepEqual(found.Annotations, ingress.Annotations) || !reflect.DeepEqual(found.Spec, ingress.Spec) {
+		reqLogger.Info("Updating Ingress", "Ingress.Namespace", found.Namespace, "Ingress.Name", found.Name)
+		found.Labels = ingress.Labels
+		found.Annotations = ingress.Annotations
+		found.Spec = ingress.Spec
+		return r.client.Update(context.TODO(), found)
+	}
+	return nil
+}
+
+// newHTTPRouteForCR returns a Gateway API HTTPRoute exposing the web interface of the given ZNC instance.
+func newHTTPRouteForCR(cr *zncv1.ZNC) (*unstructured.Unstructured, error) {
+	spec := cr.Spec.Web.HTTPRoute
+	_, port, err := webServicePortForCR(cr)
+	if err != nil {
+		return nil, err
+	}
+	var parentRefs []interface{}
+	for _, parentRef := range spec.ParentRefs {
+		ref := map[string]interface{}{
+			"name": parentRef.Name,
+		}
+		if len(parentRef.Namespace) > 0 {
+			ref["namespace"] = parentRef.Namespace
+		}
+		if len(parentRef.SectionName) > 0 {
+			ref["sectionName"] = parentRef.SectionName
+		}
+		parentRefs = append(parentRefs, ref)
+	}
+	routeSpec := map[string]interface{}{
+		"parentRefs": parentRefs,
+		"rules": []interface{}{
+			map[string]interface{}{
+				"matches": []interface{}{
+					map[string]interface{}{
+						"path": map[string]interface{}{
+							"type":  "PathPrefix",
+							"value": cr.Spec.Web.GetPath(),
+						},
+					},
+				},
+				"backendRefs": []interface{}{
+					map[string]interface{}{
+						"name": cr.Name,
+						"port": int64(port),
+					},
+				},
+			},
+		},
+	}
+	if len(spec.Hostnames) > 0 {
+		var hostnames []interface{}
+		for _, hostname := range spec.Hostnames {
+			hostnames = append(hostnames, hostname)
+		}
+		routeSpec["hostnames"] = hostnames
+	}
+	specHash, err := hashstructure.Hash(routeSpec, nil)
+	if err != nil {
+		return nil, err
+	}
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": routeSpec,
+	}}
+	route.SetGroupVersionKind(httpRouteGVK)
+	route.SetName(cr.Name)
+	route.SetNamespace(cr.Namespace)
+	route.SetLabels(labelsForCR(cr))
+	route.SetAnnotations(map[string]string{
+		specChecksumAnnotation: strconv.FormatUint(specHash, 10),
+	})
+	return route, nil
+}
+
+// containsFields returns whether the given unstructured value contains all fields of the given expected value, which
+// may omit fields that are defaulted by the API server. Lists must have the same length.
+func containsFields(actual interface{}, expected interface{}) bool {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range expected {
+			if !containsFields(actual[key], value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(actual) != len(expected) {
+			return false
+		}
+		for i := range expected {
+			if !containsFields(actual[i], expected[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(actual, expected)
+}
+
+// reconcileHTTPRoute creates, updates or deletes the Gateway API HTTPRoute exposing the web interface of the given
+// ZNC instance. Clusters without the Gateway API are tolerated, as long as no HTTPRoute is requested.
+func (r *ReconcileZNC) reconcileHTTPRoute(reqLogger logr.Logger, instance *zncv1.ZNC) error {
+	name := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+	if instance.Spec.Web == nil || instance.Spec.Web.HTTPRoute == nil {
+		obsolete := &unstructured.Unstructured{}
+		obsolete.SetGroupVersionKind(httpRouteGVK)
+		if err := r.deleteIfControlled(reqLogger, instance, name, obsolete); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+		return nil
+	}
+	route, err := newHTTPRouteForCR(instance)
+	if err != nil {
+		return err
+	}
+	if err := controllerutil.SetControllerReference(instance, route, r.scheme); err != nil {
+		return err
+	}
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(httpRouteGVK)
+	err = r.client.Get(context.TODO(), name, found)
+	if errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new HTTPRoute", "HTTPRoute.Namespace", route.GetNamespace(), "HTTPRoute.Name", route.GetName())
+		return r.client.Create(context.TODO(), route)
+	} else if err != nil {
+		return err
+	}
+	// The spec is defaulted by the Gateway API, hence changes of the ZNC instance are detected by means of its checksum
+	// and changes of the HTTPRoute by comparing the fields set by the operator only.
+	if found.GetAnnotations()[specChecksumAnnotation] != route.GetAnnotations()[specChecksumAnnotation] || !containsFields(found.Object["spec"], route.Object["spec"]) {
+		reqLogger.Info("Updating HTTPRoute", "HTTPRoute.Namespace", found.GetNamespace(), "HTTPRoute.Name", found.GetName())
+		annotations := found.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[specChecksumAnnotation] = route.GetAnnotations()[specChecksumAnnotation]
+		found.SetAnnotations(annotations)
+		found.SetLabels(route.GetLabels())
+		found.Object["spec"] = route.Object["spec"]
+		return r.client.Update(context.TODO(), found)
+	}
+	return nil
+}
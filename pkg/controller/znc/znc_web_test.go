@@ -0,0 +1,128 @@
+package znc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestApplyWebSpec(t *testing.T) {
+	spec := &zncv1.ZNCSpec{}
+	applyWebSpec(spec)
+	if spec.Config.Listeners != nil || spec.Config.TrustedProxies != nil {
+		t.Errorf("expected spec without exposed web interface to be left untouched, got %+v", spec.Config)
+	}
+
+	spec.Web = &zncv1.ZNCSpecWeb{Ingress: &zncv1.ZNCSpecWebIngress{Host: "znc.example.com", Path: "/znc"}}
+	applyWebSpec(spec)
+	if len(spec.Config.Listeners) != 2 || spec.Config.Listeners[0].URIPrefix != "" || spec.Config.Listeners[1].URIPrefix != "/znc/" {
+		t.Errorf("expected URIPrefix of the web listener to be derived from the path, got %+v", spec.Config.Listeners)
+	}
+	if !reflect.DeepEqual(spec.Config.TrustedProxies, zncv1.TrustedProxiesDefault) {
+		t.Errorf("expected default trusted proxies, got %v", spec.Config.TrustedProxies)
+	}
+
+	zncConf, err := RenderConfiguration(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(zncConf, "\nTrustedProxy = 10.0.0.0/8\n") || !strings.Contains(zncConf, "URIPrefix = /znc/") {
+		t.Errorf("expected trusted proxies and URIPrefix to be rendered, got %s", zncConf)
+	}
+}
+
+func TestReconcileWeb(t *testing.T) {
+	s := newTestScheme(t)
+	cr := newTestZNC()
+	cr.Spec.Web = &zncv1.ZNCSpecWeb{
+		Ingress: &zncv1.ZNCSpecWebIngress{Host: "znc.example.com", ClassName: "nginx", TLSSecret: "znc-example-com"},
+		HTTPRoute: &zncv1.ZNCSpecWebHTTPRoute{
+			ParentRefs: []zncv1.ZNCSpecWebHTTPRouteParentRef{{Name: "gateway", Namespace: "infra"}},
+			Hostnames:  []string{"znc.example.com"},
+		},
+	}
+	r := &ReconcileZNC{client: fake.NewFakeClientWithScheme(s, cr), scheme: s}
+	reqLogger := logf.Log.WithName("test")
+	name := types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}
+
+	if err := r.reconcileIngress(reqLogger, cr); err != nil {
+		t.Fatal("reconciling the Ingress caused an unexpected error", err)
+	}
+	ingress := &networkingv1beta1.Ingress{}
+	if err := r.client.Get(context.TODO(), name, ingress); err != nil {
+		t.Fatal("expected Ingress to be created", err)
+	}
+	if ingress.Annotations[ingressClassAnnotation] != "nginx" || len(ingress.Spec.TLS) != 1 {
+		t.Errorf("unexpected Ingress %+v", ingress)
+	}
+	if backend := ingress.Spec.Rules[0].HTTP.Paths[0].Backend; backend.ServiceName != cr.Name || backend.ServicePort.StrVal != "web" {
+		t.Errorf("unexpected backend %+v", backend)
+	}
+
+	if err := r.reconcileHTTPRoute(reqLogger, cr); err != nil {
+		t.Fatal("reconciling the HTTPRoute caused an unexpected error", err)
+	}
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(httpRouteGVK)
+	if err := r.client.Get(context.TODO(), name, route); err != nil {
+		t.Fatal("expected HTTPRoute to be created", err)
+	}
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	if len(rules) != 1 {
+		t.Errorf("unexpected HTTPRoute %+v", route.Object)
+	}
+
+	// Fields defaulted by the Gateway API are kept.
+	parentRefs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	parentRefs[0].(map[string]interface{})["kind"] = "Gateway"
+	if err := unstructured.SetNestedSlice(route.Object, parentRefs, "spec", "parentRefs"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Update(context.TODO(), route); err != nil {
+		t.Fatal(err)
+	}
+	resourceVersion := route.GetResourceVersion()
+	if err := r.reconcileHTTPRoute(reqLogger, cr); err != nil {
+		t.Fatal("reconciling the HTTPRoute caused an unexpected error", err)
+	}
+	if err := r.client.Get(context.TODO(), name, route); err != nil {
+		t.Fatal(err)
+	}
+	if route.GetResourceVersion() != resourceVersion {
+		t.Error("expected the HTTPRoute with defaulted fields not to be updated")
+	}
+
+	// Changes to the fields set by the operator are reverted.
+	if err := unstructured.SetNestedStringSlice(route.Object, []string{"evil.example.com"}, "spec", "hostnames"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Update(context.TODO(), route); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileHTTPRoute(reqLogger, cr); err != nil {
+		t.Fatal("reconciling the HTTPRoute caused an unexpected error", err)
+	}
+	if err := r.client.Get(context.TODO(), name, route); err != nil {
+		t.Fatal(err)
+	}
+	if hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames"); !reflect.DeepEqual(hostnames, []string{"znc.example.com"}) {
+		t.Errorf("expected the hostnames of the HTTPRoute to be restored, got %v", hostnames)
+	}
+
+	cr.Spec.Web.Ingress = nil
+	if err := r.reconcileIngress(reqLogger, cr); err != nil {
+		t.Fatal("reconciling the Ingress caused an unexpected error", err)
+	}
+	if err := r.client.Get(context.TODO(), name, &networkingv1beta1.Ingress{}); !errors.IsNotFound(err) {
+		t.Error("expected Ingress to be deleted", err)
+	}
+}
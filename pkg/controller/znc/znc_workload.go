@@ -0,0 +1,285 @@
+package znc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	zncv1 "znc-operator/pkg/apis/znc/v1"
+
+	"github.com/go-logr/logr"
+	"github.com/mitchellh/hashstructure"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// checksumAnnotation holds the checksum of the ZNC configuration a pod has been created with. On the ConfigMap, it
+	// holds the checksum of the configuration ZNC has to be started with, which stays unchanged while configuration
+	// changes are applied live.
+	checksumAnnotation = "config.znc.in/checksum"
+	// pendingChecksumAnnotation marks a ConfigMap whose configuration is being applied to the running pod. It holds the
+	// checksum of the configuration, which the pod is restarted with if the update is interrupted.
+	pendingChecksumAnnotation = "config.znc.in/pending-checksum"
+	// secretChecksumAnnotation holds the checksum of the sensitive files a pod has been created with.
+	secretChecksumAnnotation = "config.znc.in/secret-checksum"
+	// specChecksumAnnotation holds the checksum of the desired spec of a workload resource. It is used to detect
+	// changes without having to compare against fields that are defaulted by the API server.
+	specChecksumAnnotation = "config.znc.in/spec-checksum"
+)
+
+// workload is implemented by the workload resources used to run ZNC pods.
+type workload interface {
+	metav1.Object
+	runtime.Object
+}
+
+// newStatefulSetForCR returns a single-replica StatefulSet running the given pod template.
+func newStatefulSetForCR(cr *zncv1.ZNC, template corev1.PodTemplateSpec) *appsv1.StatefulSet {
+	replicas := int32(1)
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Name,
+			Namespace: cr.Namespace,
+			Labels:    labelsForCR(cr),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			Selector:    &metav1.LabelSelector{MatchLabels: labelsForCR(cr)},
+			ServiceName: headlessServiceNameForCR(cr),
+			Template:    template,
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type: appsv1.RollingUpdateStatefulSetStrategyType,
+			},
+		},
+	}
+}
+
+// newDeploymentForCR returns a single-replica Deployment running the given pod template.
+// The old pod is always terminated before the new one is started, as two ZNC instances must never share the data
+// directory.
+func newDeploymentForCR(cr *zncv1.ZNC, template corev1.PodTemplateSpec) *appsv1.Deployment {
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Name,
+			Namespace: cr.Namespace,
+			Labels:    labelsForCR(cr),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labelsForCR(cr)},
+			Template: template,
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RecreateDeploymentStrategyType,
+			},
+		},
+	}
+}
+
+// newWorkloadForCR returns the workload resource running the ZNC pod of the given cr and its resolved spec, together
+// with an empty instance of the workload kind that is not in use.
+func newWorkloadForCR(cr *zncv1.ZNC, resolved *zncv1.ZNCSpec, cfgHash string, secret *generatedSecret) (desired workload, unused workload, err error) {
+	template := newPodTemplateForCR(cr, resolved, cfgHash, secret)
+	var spec interface{}
+	switch cr.Spec.GetWorkload() {
+	case zncv1.ZNCWorkloadKindDeployment:
+		deployment := newDeploymentForCR(cr, template)
+		desired, spec, unused = deployment, deployment.Spec, &appsv1.StatefulSet{}
+	case zncv1.ZNCWorkloadKindStatefulSet:
+		statefulSet := newStatefulSetForCR(cr, template)
+		desired, spec, unused = statefulSet, statefulSet.Spec, &appsv1.Deployment{}
+	default:
+		return nil, nil, fmt.Errorf("unsupported workload kind %s", cr.Spec.Workload)
+	}
+	specHash, err := hashstructure.Hash(spec, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	desired.SetAnnotations(map[string]string{
+		specChecksumAnnotation: strconv.FormatUint(specHash, 10),
+	})
+	return desired, unused, nil
+}
+
+// podTemplateForWorkload returns the pod template of the given workload resource.
+func podTemplateForWorkload(w workload) *corev1.PodTemplateSpec {
+	switch w := w.(type) {
+	case *appsv1.StatefulSet:
+		return &w.Spec.Template
+	case *appsv1.Deployment:
+		return &w.Spec.Template
+	}
+	return nil
+}
+
+// copyWorkloadSpec copies the spec of the workload src to dst, which must be of the same kind.
+func copyWorkloadSpec(dst workload, src workload) {
+	switch dst := dst.(type) {
+	case *appsv1.StatefulSet:
+		// Apart from the replicas, the template and the update strategy, the spec of a StatefulSet is immutable.
+		srcSpec := src.(*appsv1.StatefulSet).Spec
+		dst.Spec.Replicas = srcSpec.Replicas
+		dst.Spec.Template = srcSpec.Template
+		dst.Spec.UpdateStrategy = srcSpec.UpdateStrategy
+	case *appsv1.Deployment:
+		dst.Spec = src.(*appsv1.Deployment).Spec
+	}
+}
+
+// reconcileWorkload creates or updates the workload resource running the ZNC pod of the given instance and its
+// resolved spec and removes the workload resource of the other kind, if the instance has been switched between
+// StatefulSet and Deployment. Before the running pod is replaced, it is drained by prepareRestart. The returned result
+// schedules the update of the workload resource, if the grace period has not passed yet.
+func (r *ReconcileZNC) reconcileWorkload(reqLogger logr.Logger, instance *zncv1.ZNC, spec *zncv1.ZNCSpec, cfgHash string, secret *generatedSecret, operatorPassword string) (reconcile.Result, error) {
+	desired, unused, err := newWorkloadForCR(instance, spec, cfgHash, secret)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := controllerutil.SetControllerReference(instance, desired, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+	kind := string(instance.Spec.GetWorkload())
+
+	found := desired.DeepCopyObject().(workload)
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: desired.GetName(), Namespace: desired.GetNamespace()}, found)
+	if errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new "+kind, kind+".Namespace", desired.GetNamespace(), kind+".Name", desired.GetName())
+		if err := r.client.Create(context.TODO(), desired); err != nil {
+			return reconcile.Result{}, err
+		}
+		r.recordEvent(instance, corev1.EventTypeNormal, "Created", "Created %s %s with configuration checksum %s", kind, desired.GetName(), cfgHash)
+	} else if err != nil {
+		return reconcile.Result{}, err
+	} else if statefulSet, ok := found.(*appsv1.StatefulSet); ok && statefulSet.Spec.ServiceName != headlessServiceNameForCR(instance) {
+		// StatefulSets created by previous versions of the operator are governed by the regular Service. As the governing
+		// Service cannot be changed, the StatefulSet is recreated, while its pod is orphaned and adopted by the new one.
+		reqLogger.Info("Recreating StatefulSet to change its governing Service", "StatefulSet.Namespace", found.GetNamespace(), "StatefulSet.Name", found.GetName())
+		return reconcile.Result{}, r.client.Delete(context.TODO(), found, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+	} else if found.GetAnnotations()[specChecksumAnnotation] != desired.GetAnnotations()[specChecksumAnnotation] {
+		wait, err := r.prepareRestart(reqLogger, instance, operatorPassword)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if wait > 0 {
+			reqLogger.Info("Waiting for the restart grace period before updating "+kind, "Remaining", wait.String())
+			return reconcile.Result{RequeueAfter: wait}, nil
+		}
+		reqLogger.Info("Updating "+kind, kind+".Namespace", found.GetNamespace(), kind+".Name", found.GetName())
+		annotations := found.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[specChecksumAnnotation] = desired.GetAnnotations()[specChecksumAnnotation]
+		found.SetAnnotations(annotations)
+		found.SetLabels(desired.GetLabels())
+		oldAnnotations := podTemplateForWorkload(found).Annotations
+		copyWorkloadSpec(found, desired)
+		if err := r.client.Update(context.TODO(), found); err != nil {
+			return reconcile.Result{}, err
+		}
+		if oldHash := oldAnnotations[checksumAnnotation]; oldHash != cfgHash {
+			r.recordEvent(instance, corev1.EventTypeNormal, "RestartingPod", "Replacing the ZNC pod due to a configuration change (old checksum: %s, new checksum: %s)", oldHash, cfgHash)
+		} else if oldAnnotations[secretChecksumAnnotation] != secret.checksum {
+			r.recordEvent(instance, corev1.EventTypeNormal, "RestartingPod", "Replacing the ZNC pod due to a change of the generated Secret")
+		}
+	}
+
+	return reconcile.Result{}, r.deleteIfControlled(reqLogger, instance, types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, unused)
+}
+
+// deleteIfControlled deletes the named object, if it exists and is controlled by the given instance.
+func (r *ReconcileZNC) deleteIfControlled(reqLogger logr.Logger, instance *zncv1.ZNC, name types.NamespacedName, obj workload) error {
+	err := r.client.Get(context.TODO(), name, obj)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(obj, instance) {
+		return nil
+	}
+	reqLogger.Info("Deleting obsolete resource", "Kind", fmt.Sprintf("%T", obj), "Namespace", name.Namespace, "Name", name.Name)
+	return r.client.Delete(context.TODO(), obj, client.PropagationPolicy(metav1.DeletePropagationForeground))
+}
+
+// deleteLegacyPod deletes the bare pod that has been created by previous versions of the operator, which ran ZNC
+// without a workload resource. The workload resource takes over from here.
+func (r *ReconcileZNC) deleteLegacyPod(reqLogger logr.Logger, instance *zncv1.ZNC) error {
+	return r.deleteIfControlled(reqLogger, instance, types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, &corev1.Pod{})
+}
+
+// podsForCR returns all ZNC pods of the given instance, which are not controlled by the instance itself.
+func (r *ReconcileZNC) podsForCR(instance *zncv1.ZNC) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	if err := r.client.List(context.TODO(), pods, client.InNamespace(instance.Namespace), client.MatchingLabels(labelsForCR(instance))); err != nil {
+		return nil, err
+	}
+	var result []corev1.Pod
+	for _, pod := range pods.Items {
+		if !metav1.IsControlledBy(&pod, instance) {
+			result = append(result, pod)
+		}
+	}
+	return result, nil
+}
+
+// observePod records the state of the current ZNC pod of the given instance in status.
+// A pod that runs an outdated configuration and is not ready is deleted, as a StatefulSet would otherwise wait for it
+// to become ready before rolling out the (possibly fixed) configuration forever.
+func (r *ReconcileZNC) observePod(reqLogger logr.Logger, instance *zncv1.ZNC, cfgHash string, status *zncv1.ZNCStatus) error {
+	pods, err := r.podsForCR(instance)
+	if err != nil {
+		return err
+	}
+	var pod *corev1.Pod
+	for i := range pods {
+		if pod == nil || pods[i].CreationTimestamp.After(pod.CreationTimestamp.Time) {
+			pod = &pods[i]
+		}
+	}
+	if pod == nil {
+		status.PodName = ""
+		status.SetCondition(zncv1.ZNCConditionPodHealthy, corev1.ConditionFalse, "PodNotFound", "The ZNC pod has not been created yet")
+		return nil
+	}
+	status.PodName = pod.Name
+	podStatus, reason, message := podHealth(pod)
+	if oldHash := pod.Annotations[checksumAnnotation]; oldHash != cfgHash {
+		status.Phase = zncv1.ZNCPhaseRestarting
+		status.SetCondition(zncv1.ZNCConditionPodHealthy, corev1.ConditionFalse, "ConfigChanged", fmt.Sprintf("Pod %s is being replaced due to a configuration change", pod.Name))
+		if podStatus != corev1.ConditionTrue && pod.DeletionTimestamp == nil {
+			reqLogger.Info(fmt.Sprintf("Configuration updated (old checksum: %s, new checksum: %s), deleting unhealthy ZNC pod", oldHash, cfgHash))
+			if err := r.client.Delete(context.TODO(), pod); err != nil {
+				return err
+			}
+			r.recordEvent(instance, corev1.EventTypeNormal, "RestartingPod", "Deleted unhealthy ZNC pod %s due to a configuration change (old checksum: %s, new checksum: %s)", pod.Name, oldHash, cfgHash)
+		}
+		return nil
+	}
+	status.SetCondition(zncv1.ZNCConditionPodHealthy, podStatus, reason, message)
+	if reason == "PodFailed" || reason == "CrashLoopBackOff" {
+		status.Phase = zncv1.ZNCPhaseFailed
+	}
+	return nil
+}
+
+// podToRequests maps ZNC pods to reconcile requests for the ZNC instance they belong to.
+func podToRequests(obj handler.MapObject) []reconcile.Request {
+	labels := obj.Meta.GetLabels()
+	if labels["app.kubernetes.io/managed-by"] != "znc-operator" || len(labels["app.kubernetes.io/instance"]) == 0 {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Name:      labels["app.kubernetes.io/instance"],
+		Namespace: obj.Meta.GetNamespace(),
+	}}}
+}
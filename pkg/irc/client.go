@@ -0,0 +1,210 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout limits every operation whose context has no deadline.
+const DefaultTimeout = 10 * time.Second
+
+// Options control how a Client connects and logs in to ZNC.
+type Options struct {
+	// User is the ZNC user to log in as. It is also used as nick.
+	User string
+	// Network is the network of the user to attach to. If empty, the client is not attached to any network.
+	Network string
+	// Password is the plaintext password of the user.
+	Password string
+	// TLSConfig enables TLS if set.
+	TLSConfig *tls.Config
+	// Timeout overrides DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Error is returned for messages of ZNC that end the session, e.g. a refused login.
+type Error struct {
+	Message Message
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s from %s: %s", e.Message.Command, e.Message.Prefix, strings.Join(e.Message.Params, " "))
+}
+
+// Client is a connection to ZNC on which a user is logged in. Commands are sent one at a time.
+type Client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	mu      sync.Mutex
+	pending int
+}
+
+// Dial connects to the IRC listener of ZNC at the given address and logs in. It returns once ZNC has welcomed the user.
+func Dial(ctx context.Context, address string, options Options) (*Client, error) {
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if options.TLSConfig != nil {
+		conn = tls.Client(conn, options.TLSConfig)
+	}
+	client := &Client{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+	if err := client.register(ctx, options); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// register logs in with PASS user/network:password, as ZNC expects it.
+func (c *Client) register(ctx context.Context, options Options) error {
+	login := options.User
+	if len(options.Network) > 0 {
+		login += "/" + options.Network
+	}
+	stop := c.watch(ctx)
+	defer stop()
+	err := c.write(
+		Message{Command: "PASS", Params: []string{login + ":" + options.Password}},
+		Message{Command: "NICK", Params: []string{options.User}},
+		Message{Command: "USER", Params: []string{options.User, "0", "*", options.User}},
+	)
+	if err != nil {
+		return c.contextError(ctx, err)
+	}
+	for {
+		message, err := c.read()
+		if err != nil {
+			return c.contextError(ctx, err)
+		}
+		switch message.Command {
+		case "001":
+			return nil
+		case "464", "ERROR":
+			return &Error{Message: message}
+		}
+	}
+}
+
+// Command sends a command to the given module, e.g. "*status", and returns the lines the module replied with. As ZNC
+// processes the lines of a client in order, the replies are complete once ZNC answers a PING sent after the command.
+func (c *Client) Command(ctx context.Context, module string, command string) ([]string, error) {
+	if strings.ContainsAny(module+command, "\r\n") {
+		return nil, fmt.Errorf("command for %s contains a line break", module)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stop := c.watch(ctx)
+	defer stop()
+
+	c.pending++
+	token := "znc-operator-" + strconv.Itoa(c.pending)
+	err := c.write(
+		Message{Command: "PRIVMSG", Params: []string{module, command}},
+		Message{Command: "PING", Params: []string{token}},
+	)
+	if err != nil {
+		return nil, c.contextError(ctx, err)
+	}
+	var replies []string
+	for {
+		message, err := c.read()
+		if err != nil {
+			return nil, c.contextError(ctx, err)
+		}
+		switch {
+		case message.Command == "PONG" && message.Param(len(message.Params)-1) == token:
+			return replies, nil
+		case (message.Command == "PRIVMSG" || message.Command == "NOTICE") && strings.EqualFold(message.Nick(), module):
+			replies = append(replies, message.Param(1))
+		case message.Command == "ERROR":
+			return nil, &Error{Message: message}
+		}
+	}
+}
+
+// Close sends QUIT and closes the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	c.write(Message{Command: "QUIT"})
+	return c.conn.Close()
+}
+
+// watch applies the deadline of the given context, or the timeout of the client, to the connection and interrupts
+// pending reads and writes if the context is cancelled before the returned function is called.
+func (c *Client) watch(ctx context.Context) (stop func()) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.timeout)
+	}
+	c.conn.SetDeadline(deadline)
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			c.conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// contextError returns the error of the given context, if it is done, as it caused the given error.
+func (c *Client) contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// write sends the given messages.
+func (c *Client) write(messages ...Message) error {
+	var b strings.Builder
+	for _, message := range messages {
+		b.WriteString(message.String() + "\r\n")
+	}
+	_, err := c.conn.Write([]byte(b.String()))
+	return err
+}
+
+// read returns the next message received, answering PINGs on the way.
+func (c *Client) read() (Message, error) {
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return Message{}, err
+		}
+		message := ParseMessage(strings.TrimRight(line, "\r\n"))
+		if message.Command != "PING" {
+			return message, nil
+		}
+		if err := c.write(Message{Command: "PONG", Params: message.Params}); err != nil {
+			return Message{}, err
+		}
+	}
+}
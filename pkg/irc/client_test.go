@@ -0,0 +1,103 @@
+package irc_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"znc-operator/pkg/irc"
+	"znc-operator/pkg/irc/irctest"
+)
+
+func TestDial(t *testing.T) {
+	server := irctest.NewServer(map[string]string{"johndoe": "s3cret"})
+	defer server.Close()
+
+	client, err := irc.Dial(context.TODO(), server.Addr, irc.Options{User: "johndoe", Network: "libera", Password: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Command(context.TODO(), "*status", "Version"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Error(err)
+	}
+	expected := []irctest.Request{{User: "johndoe", Network: "libera", Module: "*status", Command: "Version"}}
+	if actual := server.Requests(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected requests %v, got %v", expected, actual)
+	}
+
+	_, err = irc.Dial(context.TODO(), server.Addr, irc.Options{User: "johndoe", Password: "wrong"})
+	if ircErr, ok := err.(*irc.Error); !ok || ircErr.Message.Command != "464" {
+		t.Errorf("expected the login to be refused, got %v", err)
+	}
+}
+
+func TestClientCommand(t *testing.T) {
+	server := irctest.NewServer(map[string]string{"johndoe": "s3cret"})
+	defer server.Close()
+	server.Handle("*controlpanel", func(request irctest.Request) []string {
+		return []string{"Nick = " + request.User, "Done"}
+	})
+
+	client, err := irc.Dial(context.TODO(), server.Addr, irc.Options{User: "johndoe", Password: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		replies, err := client.Command(context.TODO(), "*controlpanel", "Get Nick")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []string{"Nick = johndoe", "Done"}; !reflect.DeepEqual(replies, expected) {
+			t.Errorf("expected replies %v, got %v", expected, replies)
+		}
+	}
+
+	replies, err := client.Command(context.TODO(), "*missing", "Help")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 0 {
+		t.Errorf("expected the replies of *status to be ignored, got %v", replies)
+	}
+
+	if _, err := client.Command(context.TODO(), "*controlpanel", "Set Nick johndoe x\r\nQUIT"); err == nil {
+		t.Error("expected commands with line breaks to be rejected")
+	}
+}
+
+func TestClientCommandCancellation(t *testing.T) {
+	server := irctest.NewServer(map[string]string{"johndoe": "s3cret"})
+	defer server.Close()
+	release := make(chan struct{})
+	defer close(release)
+	server.Handle("*status", func(request irctest.Request) []string {
+		<-release
+		return nil
+	})
+
+	client, err := irc.Dial(context.TODO(), server.Addr, irc.Options{User: "johndoe", Password: "s3cret", Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := client.Command(ctx, "*status", "ListNetworks"); err != context.Canceled {
+		t.Errorf("expected the command to be cancelled, got %v", err)
+	}
+
+	start := time.Now()
+	if _, err := client.Command(context.TODO(), "*status", "ListNetworks"); err == nil {
+		t.Error("expected the command to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the command to time out after the configured timeout, took %v", elapsed)
+	}
+}
@@ -0,0 +1,194 @@
+// Package irctest provides a fake ZNC instance for testing code that talks to ZNC through package irc.
+package irctest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"znc-operator/pkg/irc"
+)
+
+// serverName is the prefix of the messages the server sends itself.
+const serverName = "irc.znc.in"
+
+// Request is a command a client sent to a module.
+type Request struct {
+	User    string
+	Network string
+	Module  string
+	Command string
+}
+
+// Handler answers the commands sent to a module with the returned lines.
+type Handler func(request Request) []string
+
+// Server is a fake ZNC instance listening on a random port of the loopback interface. It accepts the users it has been
+// created with, optionally attached to any network, answers PINGs, and passes the PRIVMSGs sent to modules to the
+// handlers registered for them.
+type Server struct {
+	// Addr is the address of the IRC listener, e.g. "127.0.0.1:40123".
+	Addr string
+
+	listener  net.Listener
+	passwords map[string]string
+	wg        sync.WaitGroup
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	requests []Request
+	conns    map[net.Conn]bool
+}
+
+// NewServer starts a server that accepts the given users, keyed by name, with the given plaintext passwords.
+func NewServer(passwords map[string]string) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("irctest: failed to listen: %v", err))
+	}
+	s := &Server{
+		Addr:      listener.Addr().String(),
+		listener:  listener,
+		passwords: passwords,
+		handlers:  map[string]Handler{},
+		conns:     map[net.Conn]bool{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Handle registers the handler for the given module, e.g. "*status". Commands sent to modules without a handler are
+// answered like ZNC answers them for modules that are not loaded.
+func (s *Server) Handle(module string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[strings.ToLower(module)] = handler
+}
+
+// Requests returns the commands received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Close stops the server and closes all connections.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// session is the state of a single client connection.
+type session struct {
+	conn     net.Conn
+	pass     string
+	nick     string
+	user     string
+	network  string
+	loggedIn bool
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	c := &session{conn: conn}
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		message := irc.ParseMessage(strings.TrimRight(line, "\r\n"))
+		switch message.Command {
+		case "PASS":
+			c.pass = message.Param(0)
+		case "NICK":
+			c.nick = message.Param(0)
+		case "USER":
+			if !s.login(c) {
+				return
+			}
+		case "PING":
+			c.send(irc.Message{Prefix: serverName, Command: "PONG", Params: append([]string{serverName}, message.Params...)})
+		case "PRIVMSG":
+			if c.loggedIn {
+				s.handleCommand(c, message.Param(0), message.Param(1))
+			}
+		case "QUIT":
+			c.send(irc.Message{Command: "ERROR", Params: []string{"Closing link"}})
+			return
+		}
+	}
+}
+
+// login checks the credentials of the PASS line, which has the form user[/network]:password, and welcomes the user.
+func (s *Server) login(c *session) bool {
+	fields := strings.SplitN(c.pass, ":", 2)
+	login := strings.SplitN(fields[0], "/", 2)
+	c.user = login[0]
+	if len(login) > 1 {
+		c.network = login[1]
+	}
+	password, ok := s.passwords[c.user]
+	if len(fields) != 2 || !ok || fields[1] != password {
+		c.send(irc.Message{Prefix: serverName, Command: "464", Params: []string{c.nick, "Invalid Password"}})
+		c.send(irc.Message{Command: "ERROR", Params: []string{"Closing link"}})
+		return false
+	}
+	c.loggedIn = true
+	c.send(irc.Message{Prefix: serverName, Command: "001", Params: []string{c.nick, "Welcome to ZNC"}})
+	return true
+}
+
+// handleCommand passes a command to the handler of the given module and sends its replies.
+func (s *Server) handleCommand(c *session, module string, command string) {
+	request := Request{User: c.user, Network: c.network, Module: module, Command: command}
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	handler, ok := s.handlers[strings.ToLower(module)]
+	s.mu.Unlock()
+	if !strings.HasPrefix(module, "*") {
+		return
+	}
+	replies := []string{fmt.Sprintf("No such module [%s]", strings.TrimPrefix(module, "*"))}
+	if ok {
+		replies = handler(request)
+	} else {
+		module = "*status"
+	}
+	for _, reply := range replies {
+		c.send(irc.Message{Prefix: module + "!znc@znc.in", Command: "PRIVMSG", Params: []string{c.nick, reply}})
+	}
+}
+
+func (c *session) send(message irc.Message) {
+	c.conn.Write([]byte(message.String() + "\r\n"))
+}
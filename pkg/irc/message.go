@@ -0,0 +1,77 @@
+// Package irc implements the minimal subset of the IRC client protocol the operator needs to talk to the modules of a
+// running ZNC instance, e.g. *status and *controlpanel.
+package irc
+
+import (
+	"strings"
+)
+
+// Message is a single line of the IRC protocol, e.g. ":*status!znc@znc.in PRIVMSG johndoe :Connected!".
+type Message struct {
+	Prefix  string
+	Command string
+	Params  []string
+}
+
+// ParseMessage splits a line without its line ending into a Message. The trailing parameter, which is introduced by a
+// colon, may contain spaces. Commands are uppercased.
+func ParseMessage(line string) Message {
+	var message Message
+	if strings.HasPrefix(line, ":") {
+		fields := strings.SplitN(line[1:], " ", 2)
+		message.Prefix, line = fields[0], ""
+		if len(fields) > 1 {
+			line = fields[1]
+		}
+	}
+	var params []string
+	for len(line) > 0 {
+		if strings.HasPrefix(line, ":") {
+			params = append(params, line[1:])
+			break
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields[0]) > 0 {
+			params = append(params, fields[0])
+		}
+		line = ""
+		if len(fields) > 1 {
+			line = fields[1]
+		}
+	}
+	if len(params) > 0 {
+		message.Command, message.Params = strings.ToUpper(params[0]), params[1:]
+	}
+	return message
+}
+
+// Nick returns the nick of the sender of the message, i.e. the prefix up to the first "!".
+func (m Message) Nick() string {
+	return strings.SplitN(m.Prefix, "!", 2)[0]
+}
+
+// Param returns the parameter at the given index, or an empty string if the message has fewer parameters.
+func (m Message) Param(index int) string {
+	if index < 0 || index >= len(m.Params) {
+		return ""
+	}
+	return m.Params[index]
+}
+
+// String formats the message as a line without its line ending. The last parameter is always written as trailing
+// parameter.
+func (m Message) String() string {
+	var b strings.Builder
+	if len(m.Prefix) > 0 {
+		b.WriteString(":" + m.Prefix + " ")
+	}
+	b.WriteString(m.Command)
+	for i, param := range m.Params {
+		b.WriteString(" ")
+		if i == len(m.Params)-1 {
+			b.WriteString(":")
+		}
+		b.WriteString(param)
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected Message
+	}{
+		{line: "PING :irc.znc.in", expected: Message{Command: "PING", Params: []string{"irc.znc.in"}}},
+		{line: "ping token", expected: Message{Command: "PING", Params: []string{"token"}}},
+		{
+			line:     ":*status!znc@znc.in PRIVMSG johndoe :You are currently disconnected from IRC.",
+			expected: Message{Prefix: "*status!znc@znc.in", Command: "PRIVMSG", Params: []string{"johndoe", "You are currently disconnected from IRC."}},
+		},
+		{
+			line:     ":irc.znc.in 001  johndoe  :Welcome :-)",
+			expected: Message{Prefix: "irc.znc.in", Command: "001", Params: []string{"johndoe", "Welcome :-)"}},
+		},
+		{line: ":irc.znc.in", expected: Message{Prefix: "irc.znc.in"}},
+		{line: "", expected: Message{}},
+	}
+	for _, test := range tests {
+		if actual := ParseMessage(test.line); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("expected %q to be parsed as %#v, got %#v", test.line, test.expected, actual)
+		}
+	}
+}
+
+func TestMessageString(t *testing.T) {
+	tests := []struct {
+		message  Message
+		expected string
+	}{
+		{message: Message{Command: "QUIT"}, expected: "QUIT"},
+		{message: Message{Command: "PRIVMSG", Params: []string{"*status", "ListNetworks"}}, expected: "PRIVMSG *status :ListNetworks"},
+		{message: Message{Command: "PRIVMSG", Params: []string{"*status", ""}}, expected: "PRIVMSG *status :"},
+		{message: Message{Prefix: "irc.znc.in", Command: "001", Params: []string{"johndoe", "Welcome to ZNC"}}, expected: ":irc.znc.in 001 johndoe :Welcome to ZNC"},
+	}
+	for _, test := range tests {
+		if actual := test.message.String(); actual != test.expected {
+			t.Errorf("expected %#v to be formatted as %q, got %q", test.message, test.expected, actual)
+		}
+		if parsed := ParseMessage(test.message.String()); parsed.Command != test.message.Command || parsed.Param(len(parsed.Params)-1) != test.message.Param(len(test.message.Params)-1) {
+			t.Errorf("expected %q to be parsed back into %#v, got %#v", test.expected, test.message, parsed)
+		}
+	}
+}
+
+func TestMessageNick(t *testing.T) {
+	for prefix, expected := range map[string]string{"*status!znc@znc.in": "*status", "irc.znc.in": "irc.znc.in", "": ""} {
+		if actual := (Message{Prefix: prefix}).Nick(); actual != expected {
+			t.Errorf("expected nick %q for prefix %q, got %q", expected, prefix, actual)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package irc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseTable parses the first table found in the replies of a ZNC module, e.g.
+//
+//	+---------+-------+
+//	| Network | OnIRC |
+//	+---------+-------+
+//	| libera  | Yes   |
+//	+---------+-------+
+//
+// into one map per row, keyed by the column headers. Lines before and after the table are ignored. If the replies
+// contain no table, e.g. because it would have no rows, nil is returned.
+func ParseTable(replies []string) ([]map[string]string, error) {
+	var header []string
+	var rows []map[string]string
+	started := false
+	for _, line := range replies {
+		switch {
+		case strings.HasPrefix(line, "+-"):
+			started = true
+		case started && strings.HasPrefix(line, "|"):
+			cells := tableCells(line)
+			if header == nil {
+				header = cells
+				continue
+			}
+			if len(cells) != len(header) {
+				return nil, fmt.Errorf("table row %q has %d columns instead of %d", line, len(cells), len(header))
+			}
+			row := make(map[string]string, len(header))
+			for i, name := range header {
+				row[name] = cells[i]
+			}
+			rows = append(rows, row)
+		case started:
+			return rows, nil
+		}
+	}
+	return rows, nil
+}
+
+// tableCells returns the trimmed cells of a table row.
+func tableCells(line string) []string {
+	fields := strings.Split(strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|"), "|")
+	cells := make([]string, len(fields))
+	for i, field := range fields {
+		cells[i] = strings.TrimSpace(field)
+	}
+	return cells
+}
@@ -0,0 +1,42 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	replies := []string{
+		"+---------+-------+--------------+",
+		"| Network | OnIRC | IRC Server   |",
+		"+---------+-------+--------------+",
+		"| libera  | Yes   | irc.libera.c |",
+		"| oftc    | No    |              |",
+		"+---------+-------+--------------+",
+		"Total: 2",
+	}
+	rows, err := ParseTable(replies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]string{
+		{"Network": "libera", "OnIRC": "Yes", "IRC Server": "irc.libera.c"},
+		{"Network": "oftc", "OnIRC": "No", "IRC Server": ""},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+
+	rows, err = ParseTable([]string{"You have no networks"})
+	if err != nil || rows != nil {
+		t.Errorf("expected no rows, got %v, %v", rows, err)
+	}
+
+	if _, err := ParseTable(replies[:3]); err != nil {
+		t.Errorf("expected a table without rows to be accepted, got %v", err)
+	}
+
+	if _, err := ParseTable([]string{"+---+---+", "| a | b |", "+---+---+", "| 1 |"}); err == nil {
+		t.Error("expected an error for a row with missing columns")
+	}
+}
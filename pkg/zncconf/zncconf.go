@@ -0,0 +1,206 @@
+// Package zncconf provides a document model of the znc.conf configuration file format together with a deterministic
+// serializer.
+package zncconf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// indentation is written once per nesting level in front of every line of a nested block.
+const indentation = "\t"
+
+// Setting is a single "Key = Value" line.
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// Block is a section of a znc.conf file, e.g. "<User johndoe>". It contains settings and nested blocks, which are
+// written in the order they have been added. The root block of a file has neither a type nor a name.
+type Block struct {
+	Type     string
+	Name     string
+	Settings []Setting
+	Blocks   []*Block
+}
+
+// NewBlock returns an empty block of the given type and name.
+func NewBlock(blockType string, name string) *Block {
+	return &Block{Type: blockType, Name: name}
+}
+
+// Add appends a setting. The value is formatted using its default format, i.e. booleans are written as true or false.
+func (b *Block) Add(key string, value interface{}) *Block {
+	b.Settings = append(b.Settings, Setting{Key: key, Value: fmt.Sprint(value)})
+	return b
+}
+
+// AddAll appends a setting for each of the given values.
+func (b *Block) AddAll(key string, values []string) *Block {
+	for _, value := range values {
+		b.Add(key, value)
+	}
+	return b
+}
+
+// AddNonEmpty appends a setting, unless the value is empty.
+func (b *Block) AddNonEmpty(key string, value string) *Block {
+	if len(value) > 0 {
+		b.Add(key, value)
+	}
+	return b
+}
+
+// AddBlock appends and returns a new nested block of the given type and name.
+func (b *Block) AddBlock(blockType string, name string) *Block {
+	block := NewBlock(blockType, name)
+	b.Blocks = append(b.Blocks, block)
+	return block
+}
+
+// Marshal returns the znc.conf representation of the given root block.
+func Marshal(root *Block) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := root.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes the znc.conf representation of the block, which is regarded as the root block, to w.
+// Values that cannot be represented in znc.conf, e.g. because they span multiple lines or would be trimmed by ZNC,
+// cause an error.
+func (b *Block) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	if err := b.write(&buf, 0); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+func (b *Block) write(buf *bytes.Buffer, depth int) error {
+	indent := strings.Repeat(indentation, depth)
+	for _, setting := range b.Settings {
+		if err := validateKey(setting.Key); err != nil {
+			return err
+		}
+		if err := validateValue(setting.Value); err != nil {
+			return fmt.Errorf("invalid value of %s: %v", setting.Key, err)
+		}
+		fmt.Fprintf(buf, "%s%s = %s\n", indent, setting.Key, setting.Value)
+	}
+	for _, block := range b.Blocks {
+		if err := validateKey(block.Type); err != nil {
+			return err
+		}
+		if err := validateName(block.Name); err != nil {
+			return fmt.Errorf("invalid name of %s: %v", block.Type, err)
+		}
+		if depth == 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "%s<%s %s>\n", indent, block.Type, block.Name)
+		if err := block.write(buf, depth+1); err != nil {
+			return fmt.Errorf("%s %s: %v", block.Type, block.Name, err)
+		}
+		fmt.Fprintf(buf, "%s</%s>\n", indent, block.Type)
+	}
+	return nil
+}
+
+// validateKey validates setting keys and block types, which must be single words of printable ASCII characters that
+// cannot be mistaken for comments or section delimiters.
+func validateKey(key string) error {
+	if len(key) == 0 {
+		return fmt.Errorf("empty key")
+	}
+	for _, c := range key {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune("=<>/#", c) {
+			return fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+// validateValue validates setting values, which must not be empty, must fit on a single line and must not start or
+// end with whitespace, as ZNC trims values.
+func validateValue(value string) error {
+	if len(value) == 0 {
+		return fmt.Errorf("empty value")
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("%q must not contain line breaks", value)
+	}
+	if strings.TrimSpace(value) != value {
+		return fmt.Errorf("%q must not start or end with whitespace", value)
+	}
+	return nil
+}
+
+// validateName validates block names, which must be valid values that do not contain the closing '>'.
+func validateName(name string) error {
+	if strings.Contains(name, ">") {
+		return fmt.Errorf("%q must not contain '>'", name)
+	}
+	return validateValue(name)
+}
+
+// Parse reads a znc.conf file and returns its root block. Like ZNC, it ignores blank lines, lines starting with '#' or
+// "//" and comments enclosed in "/*" and "*/", which must start and end at the beginning and end of a line.
+// Block types are matched case-insensitively when closing a block.
+func Parse(r io.Reader) (*Block, error) {
+	root := NewBlock("", "")
+	stack := []*Block{root}
+	scanner := bufio.NewScanner(r)
+	commented := false
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		current := stack[len(stack)-1]
+		switch {
+		case commented:
+			commented = !strings.HasSuffix(line, "*/")
+		case strings.HasPrefix(line, "/*"):
+			commented = !strings.HasSuffix(line, "*/")
+		case len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//"):
+		case strings.HasPrefix(line, "</"):
+			if !strings.HasSuffix(line, ">") {
+				return nil, fmt.Errorf("line %d: malformed closing tag %q", lineNumber, line)
+			}
+			blockType := strings.TrimSpace(line[2 : len(line)-1])
+			if len(stack) == 1 || !strings.EqualFold(blockType, current.Type) {
+				return nil, fmt.Errorf("line %d: unexpected closing tag %q", lineNumber, line)
+			}
+			stack = stack[:len(stack)-1]
+		case strings.HasPrefix(line, "<"):
+			if !strings.HasSuffix(line, ">") {
+				return nil, fmt.Errorf("line %d: malformed opening tag %q", lineNumber, line)
+			}
+			fields := strings.SplitN(strings.TrimSpace(line[1:len(line)-1]), " ", 2)
+			if len(fields) != 2 || len(strings.TrimSpace(fields[1])) == 0 {
+				return nil, fmt.Errorf("line %d: block %q has no name", lineNumber, line)
+			}
+			stack = append(stack, current.AddBlock(fields[0], strings.TrimSpace(fields[1])))
+		default:
+			fields := strings.SplitN(line, "=", 2)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: malformed setting %q", lineNumber, line)
+			}
+			key, value := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+			if len(key) == 0 || len(value) == 0 {
+				return nil, fmt.Errorf("line %d: setting %q has no key or value", lineNumber, line)
+			}
+			current.Settings = append(current.Settings, Setting{Key: key, Value: value})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("block <%s %s> is not closed", stack[len(stack)-1].Type, stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
@@ -0,0 +1,168 @@
+package zncconf
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// fuzzFragments are the building blocks of the keys, names and values of fuzzBlocks, chosen to resemble attempts to
+// break out of a setting or section.
+var fuzzFragments = []string{
+	"\n", "\r", "\t", " ", "\v", "\u00a0", "\x00", "<", ">", "/", "*", "#", "=", " = ", "/*", "*/", "//",
+	"<User evil>", "</User>", "Admin = true", "Admin", "a", "0", "-", "é",
+}
+
+// fuzzKeys are keys that are always valid, so that blocks are not rejected by their keys most of the time.
+var fuzzKeys = []string{"Admin", "LoadModule", "Nick", "QuitMsg"}
+
+// fuzzString returns a short string that is likely to contain characters with a special meaning in znc.conf.
+func fuzzString(rand *rand.Rand) string {
+	var b strings.Builder
+	for i := rand.Intn(4); i >= 0; i-- {
+		b.WriteString(fuzzFragments[rand.Intn(len(fuzzFragments))])
+	}
+	return b.String()
+}
+
+// fuzzKey returns a valid key most of the time and an arbitrary string otherwise.
+func fuzzKey(rand *rand.Rand) string {
+	if rand.Intn(4) > 0 {
+		return fuzzKeys[rand.Intn(len(fuzzKeys))]
+	}
+	return fuzzString(rand)
+}
+
+// fuzzBlock is an arbitrary root block.
+type fuzzBlock struct {
+	*Block
+}
+
+// Generate implements quick.Generator.
+func (fuzzBlock) Generate(rand *rand.Rand, size int) reflect.Value {
+	var generate func(block *Block, depth int) *Block
+	generate = func(block *Block, depth int) *Block {
+		for i := rand.Intn(4); i > 0; i-- {
+			block.Add(fuzzKey(rand), fuzzString(rand))
+		}
+		for i := rand.Intn(3); depth > 0 && i > 0; i-- {
+			generate(block.AddBlock(fuzzKey(rand), fuzzString(rand)), depth-1)
+		}
+		return block
+	}
+	return reflect.ValueOf(fuzzBlock{generate(NewBlock("", ""), 2)})
+}
+
+func TestMarshal(t *testing.T) {
+	root := NewBlock("", "")
+	root.Add("Version", "1.7.5")
+	user := root.AddBlock("User", "johndoe")
+	user.Add("Admin", true).Add("Buffer", 50).AddNonEmpty("QuitMsg", "")
+	user.AddBlock("Network", "libera").AddAll("Server", []string{"irc.libera.chat +6697", "irc.eu.libera.chat 6667"})
+
+	data, err := Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Version = 1.7.5\n" +
+		"\n" +
+		"<User johndoe>\n" +
+		"\tAdmin = true\n" +
+		"\tBuffer = 50\n" +
+		"\t<Network libera>\n" +
+		"\t\tServer = irc.libera.chat +6697\n" +
+		"\t\tServer = irc.eu.libera.chat 6667\n" +
+		"\t</Network>\n" +
+		"</User>\n"
+	if string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, string(data))
+	}
+}
+
+func TestMarshalRejectsUnrepresentableValues(t *testing.T) {
+	for _, root := range []*Block{
+		NewBlock("", "").Add("QuitMsg", "bye\nLoadModule = shell"),
+		NewBlock("", "").Add("QuitMsg", "bye\r"),
+		NewBlock("", "").Add("QuitMsg", " bye"),
+		NewBlock("", "").Add("QuitMsg", ""),
+		NewBlock("", "").Add("Quit Msg", "bye"),
+		NewBlock("", "").Add("", "bye"),
+		NewBlock("", "").Add("#Admin", "true"),
+		NewBlock("", "").Add("Quit\vMsg", "bye"),
+		{Blocks: []*Block{NewBlock("User", "john>doe")}},
+		{Blocks: []*Block{NewBlock("User", "")}},
+		{Blocks: []*Block{{Type: "User", Name: "johndoe", Settings: []Setting{{Key: "Nick", Value: "john\ndoe"}}}}},
+	} {
+		if data, err := Marshal(root); err == nil {
+			t.Errorf("expected an error, got\n%s", string(data))
+		}
+	}
+}
+
+func TestMarshalIsInjectionSafe(t *testing.T) {
+	// Every block that can be marshaled must be parsed back exactly, i.e. no key, value or name can add, remove or
+	// change settings or sections.
+	property := func(root fuzzBlock) bool {
+		data, err := Marshal(root.Block)
+		if err != nil {
+			// Rejecting a block is always safe.
+			return true
+		}
+		parsed, err := Parse(bytes.NewReader(data))
+		return err == nil && reflect.DeepEqual(parsed, root.Block)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	root := NewBlock("", "")
+	root.Add("Version", "1.7.5").Add("Motd", "Hello = World")
+	user := root.AddBlock("User", "johndoe")
+	user.Add("Admin", true)
+	user.AddBlock("Network", "libera").Add("Server", "irc.libera.chat +6697").AddBlock("Chan", "#znc").Add("Key", "a b")
+	data, err := Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, root) {
+		t.Errorf("expected %+v, got %+v", root, parsed)
+	}
+}
+
+func TestParseIgnoresComments(t *testing.T) {
+	parsed, err := Parse(strings.NewReader("// header\n# Motd = hidden\n/*\nMotd = hidden\n*/\n  /* Motd = hidden */\n\tMotd  =  shown \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Setting{{Key: "Motd", Value: "shown"}}
+	if !reflect.DeepEqual(parsed.Settings, expected) {
+		t.Errorf("expected %v, got %v", expected, parsed.Settings)
+	}
+}
+
+func TestParseRejectsMalformedFiles(t *testing.T) {
+	for _, data := range []string{
+		"Motd\n",
+		"Motd =\n",
+		"= Hello\n",
+		"<User johndoe\n</User>\n",
+		"<User>\n</User>\n",
+		"<User johndoe>\n",
+		"<User johndoe>\n</Network>\n",
+		"</User>\n",
+	} {
+		if parsed, err := Parse(strings.NewReader(data)); err == nil {
+			t.Errorf("expected an error for %q, got %+v", data, parsed)
+		}
+	}
+}